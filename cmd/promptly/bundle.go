@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rahulguha/promptly/internal/bundle"
+	"github.com/rahulguha/promptly/internal/storage"
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd writes a user database's library to a bundle
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a user database to a bundle",
+	Long: `Export the profiles, personas, templates and prompts of a user database
+to a bundle file that can be imported into another database.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath, _ := cmd.Flags().GetString("db")
+		out, _ := cmd.Flags().GetString("out")
+		format, _ := cmd.Flags().GetString("format")
+		if format == "" {
+			// Go by the output file's extension, else JSON
+			format = strings.TrimPrefix(filepath.Ext(out), ".")
+			if !bundle.ValidFormat(format) {
+				format = bundle.FormatJSON
+			}
+		}
+		if !bundle.ValidFormat(format) {
+			return fmt.Errorf("--format must be json or zip")
+		}
+
+		store, err := sqlite.NewSQLiteStorage(dbPath)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		b, err := bundle.Export(store)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if out != "" {
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := bundle.Write(w, b, format); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Exported %d profiles, %d personas, %d template versions and %d prompts\n",
+			len(b.Profiles), len(b.Personas), len(b.Templates), len(b.Prompts))
+		return nil
+	},
+}
+
+// importCmd reads a bundle into a user database
+var importCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Import a bundle into a user database",
+	Long: `Import a bundle written by export into a user database. --strategy says
+what to do with items that already exist: skip them, overwrite them, or
+duplicate them under new IDs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath, _ := cmd.Flags().GetString("db")
+		strategy, _ := cmd.Flags().GetString("strategy")
+		if !bundle.ValidStrategy(strategy) {
+			return fmt.Errorf("--strategy must be skip, overwrite or duplicate")
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := bundle.Read(data)
+		if err != nil {
+			return err
+		}
+
+		store, err := sqlite.NewSQLiteStorage(dbPath)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		var result *bundle.Result
+		err = store.WithTx(func(tx storage.Storage) error {
+			var err error
+			result, err = bundle.Import(tx.(*sqlite.SQLiteStorage), b, strategy)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd, importCmd)
+
+	for _, cmd := range []*cobra.Command{exportCmd, importCmd} {
+		cmd.Flags().String("db", "", "Path of the user database")
+		cmd.MarkFlagRequired("db")
+	}
+	exportCmd.Flags().StringP("out", "o", "", "File to write the bundle to (default stdout)")
+	exportCmd.Flags().String("format", "", "Bundle format, json or zip (default from --out, else json)")
+	importCmd.Flags().String("strategy", bundle.StrategySkip, "What to do with existing items: skip, overwrite or duplicate")
+}
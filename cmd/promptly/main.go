@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/rahulguha/promptly/internal/api"
 	"github.com/rahulguha/promptly/internal/config"
+	"github.com/rahulguha/promptly/internal/intent"
 	"github.com/rahulguha/promptly/internal/routes"
 	"github.com/rahulguha/promptly/internal/storage"
 	"github.com/rahulguha/promptly/internal/tracking"
@@ -89,7 +95,10 @@ func startServer() {
 	}
 
 	// Initialize the DB manager
-	dbManager := storage.NewDBManager()
+	dbManager, err := storage.NewDBManager(cfg.DataDir, cfg.SQLite, cfg.MaxOpenDBs, cfg.DBIdleTTL)
+	if err != nil {
+		log.Fatalf("Failed to initialize DB manager: %v", err)
+	}
 
 	// Initialize DynamoDB tracker
 	tracker, err := tracking.NewDynamoDBTracker(cfg.DynamoDBRegion, cfg.DynamoDBTableName, cfg.DynamoDBActivityTableName)
@@ -105,6 +114,20 @@ func startServer() {
 		DBManager:         dbManager,
 		Cfg:               cfg,
 		UserTrackingHandler: userTrackingHandler,
+		Intents:           intent.NewMaster(cfg.IntentMasterFile),
+	}
+
+	// Load the built-in intents before serving, then keep them in step with
+	// the intent master file
+	if err := dbManager.SeedIntents(handler.Intents); err != nil {
+		log.Printf("WARNING: Failed to load intents from %s: %v", cfg.IntentMasterFile, err)
+		log.Printf("WARNING: User databases get no built-in intents until it loads; retrying every minute")
+	}
+	go dbManager.SeedIntentsEvery(handler.Intents, time.Minute)
+
+	// Purge items left in the trash past their retention
+	if cfg.TrashRetention > 0 {
+		go dbManager.PurgeTrashEvery(cfg.TrashRetention, time.Hour)
 	}
 
 	// Setup Gin router
@@ -112,10 +135,26 @@ func startServer() {
 	routes.RegisterRoutes(r, handler)
 
 	// Start server
-	fmt.Printf("Starting Promptly server on port %s\n", cfg.Port)
-	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go func() {
+		fmt.Printf("Starting Promptly server on port %s\n", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// On interrupt, let requests in flight finish, then close every database
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	fmt.Println("Shutting down Promptly server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
 	}
+	dbManager.Close()
 }
 
 func main() {
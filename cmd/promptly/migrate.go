@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rahulguha/promptly/internal/config"
+	"github.com/rahulguha/promptly/internal/storage"
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd brings every user database up to date
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate every user database to the current schema",
+	Long: `Apply pending schema migrations to every user database under the data
+root and report what changed. Databases are also migrated when the server
+first opens them; this upgrades them all at once. Legacy databases named by
+user ID and email are moved into place first. --to migrates down to an
+earlier version instead.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Open the databases the way the server would
+		cfg, err := config.LoadStorage()
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		dataDir := cfg.DataDir
+		if cmd.Flags().Changed("data") {
+			dataDir, _ = cmd.Flags().GetString("data")
+		}
+		target, _ := cmd.Flags().GetInt("to")
+		if target < 0 {
+			target = sqlite.LatestVersion()
+		}
+
+		registry, err := storage.OpenRegistry(dataDir, cfg.SQLite)
+		if err != nil {
+			return err
+		}
+		moved, err := registry.RelocateLegacy()
+		registry.Close()
+		if err != nil {
+			return err
+		}
+		if moved > 0 {
+			fmt.Printf("Moved %d legacy databases into place\n", moved)
+		}
+
+		paths, err := storage.UserFiles(dataDir)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			fmt.Printf("No databases in %s\n", dataDir)
+			return nil
+		}
+
+		failed := 0
+		for _, path := range paths {
+			from, err := migrateFile(path, cfg.SQLite, target)
+			switch {
+			case err != nil:
+				failed++
+				fmt.Printf("%s: failed at version %d: %v\n", path, from, err)
+			case from == target:
+				fmt.Printf("%s: up to date at version %d\n", path, target)
+			default:
+				fmt.Printf("%s: migrated from version %d to %d\n", path, from, target)
+			}
+		}
+
+		fmt.Printf("%d databases, %d failed\n", len(paths), failed)
+		if failed > 0 {
+			return fmt.Errorf("%d databases failed to migrate", failed)
+		}
+		return nil
+	},
+}
+
+// migrateFile migrates one database file, returning the version it was at.
+func migrateFile(path string, opts sqlite.Options, target int) (int, error) {
+	db, err := sqlite.Open(path, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	from, err := sqlite.MigrateTo(db, target)
+	if err != nil {
+		// Report where the database was left
+		if version, verr := sqlite.SchemaVersion(db); verr == nil {
+			from = version
+		}
+	}
+	return from, err
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().String("data", "", "Data root holding the user databases (default DATA_DIR)")
+	migrateCmd.Flags().Int("to", -1, "Schema version to migrate to (default the latest)")
+}
@@ -0,0 +1,118 @@
+// Package bundle exports a user's library to a portable file and imports it
+// back, into the same database or another one.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// Kind identifies a promptly bundle.
+const Kind = "promptly-bundle"
+
+// Version is the bundle format version written by Write. Read accepts it
+// and every earlier version.
+const Version = 1
+
+// Bundle file formats
+const (
+	FormatJSON = "json"
+	FormatZIP  = "zip"
+)
+
+// zipEntry is the name of the bundle's JSON inside a ZIP bundle.
+const zipEntry = "bundle.json"
+
+// maxUnpackedSize is the most JSON a ZIP bundle may unpack to, so a small
+// archive can't expand to fill memory. A var so tests can lower it.
+var maxUnpackedSize int64 = 128 << 20
+
+// ErrTooLarge is returned for a ZIP bundle that unpacks to more than
+// maxUnpackedSize.
+var ErrTooLarge = fmt.Errorf("bundle unpacks to more than %d MB", maxUnpackedSize>>20)
+
+// Bundle is a user's library with the format version it was written in.
+type Bundle struct {
+	Kind       string    `json:"kind"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	models.Library
+}
+
+// New returns a bundle of lib in the current format.
+func New(lib models.Library) *Bundle {
+	return &Bundle{Kind: Kind, Version: Version, ExportedAt: time.Now().UTC(), Library: lib}
+}
+
+// ValidFormat reports whether format is a bundle file format.
+func ValidFormat(format string) bool {
+	return format == FormatJSON || format == FormatZIP
+}
+
+// Write writes a bundle in a file format: indented JSON, or a ZIP archive
+// holding that JSON.
+func Write(w io.Writer, b *Bundle, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(b)
+	case FormatZIP:
+		zw := zip.NewWriter(w)
+		f, err := zw.Create(zipEntry)
+		if err != nil {
+			return err
+		}
+		if err := Write(f, b, FormatJSON); err != nil {
+			return err
+		}
+		return zw.Close()
+	default:
+		return fmt.Errorf("unknown bundle format %q", format)
+	}
+}
+
+// Read reads a bundle written by Write in either format, telling them
+// apart by their content.
+func Read(data []byte) (*Bundle, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle archive: %w", err)
+		}
+		f, err := zr.Open(zipEntry)
+		if err != nil {
+			return nil, fmt.Errorf("bundle archive has no %s", zipEntry)
+		}
+		defer f.Close()
+		// The size in the archive can't be trusted, so reading stops past
+		// the limit too
+		if info, err := f.Stat(); err == nil && info.Size() > maxUnpackedSize {
+			return nil, ErrTooLarge
+		}
+		if data, err = io.ReadAll(io.LimitReader(f, maxUnpackedSize+1)); err != nil {
+			return nil, fmt.Errorf("invalid bundle archive: %w", err)
+		}
+		if int64(len(data)) > maxUnpackedSize {
+			return nil, ErrTooLarge
+		}
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if b.Kind != Kind {
+		return nil, fmt.Errorf("not a promptly bundle")
+	}
+	if b.Version < 1 || b.Version > Version {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return &b, nil
+}
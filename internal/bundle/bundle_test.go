@@ -0,0 +1,246 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
+)
+
+func newStore(t *testing.T) *sqlite.SQLiteStorage {
+	t.Helper()
+	store, err := sqlite.NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// seed fills a store with a profile, a persona, a template with two
+// versions and a prompt from each version.
+func seed(t *testing.T, store *sqlite.SQLiteStorage) {
+	t.Helper()
+	profile := &models.Profile{Name: "Student"}
+	if err := store.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	persona, err := store.CreatePersona(&models.Persona{UserRoleDisplay: "Student", LLMRoleDisplay: "Tutor", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	template, err := store.CreateTemplate(&models.PromptTemplate{Name: "Explain", PersonaID: persona.ID, Template: "Explain {{topic}}", Variables: []string{"topic"}, ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	next := *template
+	next.Template = "Explain {{topic}} simply"
+	if _, err := store.CreateTemplateVersion(&next); err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+	for _, version := range []int{1, 2} {
+		_, err := store.Create(&models.Prompt{Name: "Gravity", TemplateID: template.ID, TemplateVersion: version, Values: map[string]string{"topic": "gravity"}, Content: "Explain gravity", ProfileID: profile.ID})
+		if err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	source := newStore(t)
+	seed(t, source)
+
+	b, err := Export(source)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if len(b.Profiles) != 1 || len(b.Personas) != 1 || len(b.Templates) != 2 || len(b.Prompts) != 2 {
+		t.Fatalf("Unexpected export: %d profiles, %d personas, %d templates, %d prompts", len(b.Profiles), len(b.Personas), len(b.Templates), len(b.Prompts))
+	}
+	if b.Templates[0].Version != 1 || b.Templates[1].Version != 2 {
+		t.Error("Expected template versions oldest first")
+	}
+
+	for _, format := range []string{FormatJSON, FormatZIP} {
+		var buf bytes.Buffer
+		if err := Write(&buf, b, format); err != nil {
+			t.Fatalf("Failed to write %s bundle: %v", format, err)
+		}
+		read, err := Read(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to read %s bundle: %v", format, err)
+		}
+		if read.Version != Version || len(read.Templates) != 2 || read.Prompts[1].Values["topic"] != "gravity" {
+			t.Errorf("%s bundle didn't round trip: %+v", format, read)
+		}
+	}
+
+	if _, err := Read([]byte(`{"kind": "promptly-bundle", "version": 99}`)); err == nil {
+		t.Error("Expected an error for a newer bundle version")
+	}
+	if _, err := Read([]byte(`{"profiles": []}`)); err == nil {
+		t.Error("Expected an error for JSON that isn't a bundle")
+	}
+}
+
+func TestRead_TooLarge(t *testing.T) {
+	defer func(size int64) { maxUnpackedSize = size }(maxUnpackedSize)
+	maxUnpackedSize = 1 << 10
+
+	b := New(models.Library{Profiles: []*models.Profile{{ID: "p", Name: "Work", Description: strings.Repeat("a", 4<<10)}}})
+	var buf bytes.Buffer
+	if err := Write(&buf, b, FormatZIP); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+	if buf.Len() > int(maxUnpackedSize) {
+		t.Fatalf("Expected the archive to be smaller than what it unpacks to, got %d bytes", buf.Len())
+	}
+	if _, err := Read(buf.Bytes()); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	source := newStore(t)
+	seed(t, source)
+	b, err := Export(source)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	// Into an empty database everything is created, keeping IDs
+	target := newStore(t)
+	result, err := Import(target, b, StrategySkip)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Templates.Created != 2 || result.Prompts.Created != 2 || len(result.IDs) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	versions, err := target.GetTemplateVersions(b.Templates[0].ID)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Expected both template versions under their own ID: %v", err)
+	}
+	if !versions[0].CreatedAt.Equal(b.Templates[0].CreatedAt) {
+		t.Errorf("Expected created_at to be kept, got %v", versions[0].CreatedAt)
+	}
+
+	// Importing again skips everything
+	result, err = Import(target, b, StrategySkip)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Personas.Skipped != 1 || result.Templates.Skipped != 2 || result.Prompts.Created != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	// Overwriting replaces the items
+	changed := *b
+	persona := *b.Personas[0]
+	persona.LLMRoleDisplay = "Professor"
+	changed.Personas = []*models.Persona{&persona}
+	result, err = Import(target, &changed, StrategyOverwrite)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Personas.Overwritten != 1 || result.Prompts.Overwritten != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if got, _ := target.GetPersonaByID(persona.ID); got.LLMRoleDisplay != "Professor" {
+		t.Errorf("Expected the persona to be overwritten, got %q", got.LLMRoleDisplay)
+	}
+
+	// Duplicating imports copies whose references follow the new IDs
+	result, err = Import(target, b, StrategyDuplicate)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Profiles.Duplicated != 1 || result.Templates.Duplicated != 2 || result.Prompts.Duplicated != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	newTemplateID := uuid.MustParse(result.IDs[b.Templates[0].ID.String()])
+	copies, err := target.GetTemplateVersions(newTemplateID)
+	if err != nil || len(copies) != 2 {
+		t.Fatalf("Expected the duplicated template to have both versions: %v", err)
+	}
+	if copies[0].PersonaID.String() != result.IDs[persona.ID.String()] || copies[0].ProfileID != result.IDs[b.Profiles[0].ID] {
+		t.Error("Expected the duplicated template to reference the duplicated persona and profile")
+	}
+	newPrompt, err := target.GetByID(uuid.MustParse(result.IDs[b.Prompts[0].ID.String()]))
+	if err != nil || newPrompt.TemplateID != newTemplateID {
+		t.Errorf("Expected the duplicated prompt to reference the duplicated template: %v", err)
+	}
+	all, _ := target.GetAll("")
+	if len(all) != 4 {
+		t.Errorf("Expected 4 prompts after duplicating, got %d", len(all))
+	}
+
+	if _, err := Import(target, b, "merge"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
+
+func TestImport_Trashed(t *testing.T) {
+	source := newStore(t)
+	seed(t, source)
+	b, err := Export(source)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	target := newStore(t)
+	if _, err := Import(target, b, StrategySkip); err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	persona := b.Personas[0]
+	if err := target.DeletePersona(persona.ID); err != nil {
+		t.Fatalf("Failed to delete persona: %v", err)
+	}
+
+	// Items in the trash keep their IDs taken
+	result, err := Import(target, b, StrategySkip)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Personas.Skipped != 1 || result.Templates.Skipped != 2 || result.Prompts.Skipped != 2 {
+		t.Errorf("Expected trashed items to be skipped, got %+v", result)
+	}
+	if _, err := target.GetPersonaByID(persona.ID); err == nil {
+		t.Error("Expected the persona to stay in the trash")
+	}
+
+	// Overwriting them leaves them in the trash, where restoring brings
+	// back the imported versions
+	changed := *b
+	overwritten := *persona
+	overwritten.LLMRoleDisplay = "Professor"
+	changed.Personas = []*models.Persona{&overwritten}
+	result, err = Import(target, &changed, StrategyOverwrite)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Personas.Overwritten != 1 || result.Prompts.Overwritten != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if _, err := target.GetPersonaByID(persona.ID); err == nil {
+		t.Error("Expected the overwritten persona to stay in the trash")
+	}
+	trash, err := target.GetTrash("")
+	if err != nil || len(trash) != 1 {
+		t.Fatalf("Expected the trash entry to be kept: %v", err)
+	}
+	if err := target.RestoreTrash(trash[0].ID); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if got, err := target.GetPersonaByID(persona.ID); err != nil || got.LLMRoleDisplay != "Professor" {
+		t.Errorf("Expected the restored persona to be overwritten: %v", err)
+	}
+	if prompts, _ := target.GetAll(""); len(prompts) != 2 {
+		t.Errorf("Expected both prompts restored, got %d", len(prompts))
+	}
+}
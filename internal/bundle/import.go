@@ -0,0 +1,274 @@
+package bundle
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// Store is the storage a library is exported from and imported into.
+type Store interface {
+	storage.Storage
+	storage.ProfileStorage
+	storage.BundleStorage
+}
+
+// Strategies for importing an item whose ID is already taken
+const (
+	// StrategySkip keeps the existing item.
+	StrategySkip = "skip"
+	// StrategyOverwrite replaces the existing item.
+	StrategyOverwrite = "overwrite"
+	// StrategyDuplicate imports the item under a new ID, next to the
+	// existing one.
+	StrategyDuplicate = "duplicate"
+)
+
+// ValidStrategy reports whether strategy is an import strategy.
+func ValidStrategy(strategy string) bool {
+	return strategy == StrategySkip || strategy == StrategyOverwrite || strategy == StrategyDuplicate
+}
+
+// Counts tallies what importing did with one kind of item.
+type Counts struct {
+	Created     int `json:"created"`
+	Skipped     int `json:"skipped"`
+	Overwritten int `json:"overwritten"`
+	Duplicated  int `json:"duplicated"`
+}
+
+// Result reports what an import did. Template counts are of versions.
+type Result struct {
+	Profiles  Counts `json:"profiles"`
+	Personas  Counts `json:"personas"`
+	Templates Counts `json:"templates"`
+	Prompts   Counts `json:"prompts"`
+	// IDs maps the ID of every duplicated item to its new ID.
+	IDs map[string]string `json:"ids"`
+}
+
+// Export bundles a store's whole library. Tags, folders and usage stay
+// behind, and items in the trash are left out.
+func Export(store Store) (*Bundle, error) {
+	var lib models.Library
+	var err error
+
+	if lib.Profiles, err = store.GetAllProfiles(); err != nil {
+		return nil, err
+	}
+	if lib.Personas, err = store.GetAllPersonas(""); err != nil {
+		return nil, err
+	}
+	if lib.Templates, err = store.GetAllTemplates(""); err != nil {
+		return nil, err
+	}
+	if lib.Prompts, err = store.GetAll(""); err != nil {
+		return nil, err
+	}
+
+	// Keep a template's versions together, oldest first
+	sort.SliceStable(lib.Templates, func(i, j int) bool {
+		a, b := lib.Templates[i], lib.Templates[j]
+		if a.ID != b.ID {
+			return a.ID.String() < b.ID.String()
+		}
+		return a.Version < b.Version
+	})
+	for _, t := range lib.Templates {
+		t.FolderID = nil
+	}
+	for _, p := range lib.Prompts {
+		p.FolderID = nil
+	}
+
+	return New(lib), nil
+}
+
+// importer plans an import: which items to write and under which IDs.
+type importer struct {
+	store    Store
+	strategy string
+	result   *Result
+	lib      models.Library
+	// New IDs of duplicated items, by old ID
+	profiles, personas, templates map[string]string
+}
+
+// resolve decides what to do with an item whose ID may be taken, counting
+// the outcome. It returns whether to write the item and whether it gets a
+// new ID.
+func (im *importer) resolve(taken bool, counts *Counts) (write, duplicate bool) {
+	switch {
+	case !taken:
+		counts.Created++
+		return true, false
+	case im.strategy == StrategySkip:
+		counts.Skipped++
+		return false, false
+	case im.strategy == StrategyOverwrite:
+		counts.Overwritten++
+		return true, false
+	default:
+		counts.Duplicated++
+		return true, true
+	}
+}
+
+// remap returns the new ID of a duplicated item, or id itself.
+func remap(ids map[string]string, id string) string {
+	if newID, ok := ids[id]; ok {
+		return newID
+	}
+	return id
+}
+
+// Import writes a bundle's items into a store, all or nothing. Items keep
+// their IDs unless they are duplicated, and references to duplicated items
+// follow them to their new IDs. strategy decides what happens to items
+// whose ID is taken, by an item in the store or in its trash; a template's
+// ID is taken when any of its versions is, and skipping a template still
+// imports versions the store lacks. Overwritten items in the trash stay
+// there.
+func Import(store Store, b *Bundle, strategy string) (*Result, error) {
+	if !ValidStrategy(strategy) {
+		return nil, fmt.Errorf("unknown import strategy %q", strategy)
+	}
+
+	im := &importer{
+		store:     store,
+		strategy:  strategy,
+		result:    &Result{IDs: map[string]string{}},
+		profiles:  map[string]string{},
+		personas:  map[string]string{},
+		templates: map[string]string{},
+	}
+	if err := im.importProfiles(b.Profiles); err != nil {
+		return nil, err
+	}
+	if err := im.importPersonas(b.Personas); err != nil {
+		return nil, err
+	}
+	if err := im.importTemplates(b.Templates); err != nil {
+		return nil, err
+	}
+	if err := im.importPrompts(b.Prompts); err != nil {
+		return nil, err
+	}
+
+	if err := store.PutLibrary(&im.lib); err != nil {
+		return nil, err
+	}
+	return im.result, nil
+}
+
+func (im *importer) duplicated(ids map[string]string, oldID, newID string) {
+	ids[oldID] = newID
+	im.result.IDs[oldID] = newID
+}
+
+func (im *importer) importProfiles(profiles []*models.Profile) error {
+	for _, p := range profiles {
+		taken, err := im.store.IDTaken(models.LibraryProfile, p.ID, 0)
+		if err != nil {
+			return err
+		}
+		write, duplicate := im.resolve(taken, &im.result.Profiles)
+		if !write {
+			continue
+		}
+		profile := *p
+		if duplicate {
+			profile.ID = uuid.New().String()
+			im.duplicated(im.profiles, p.ID, profile.ID)
+		}
+		im.lib.Profiles = append(im.lib.Profiles, &profile)
+	}
+	return nil
+}
+
+func (im *importer) importPersonas(personas []*models.Persona) error {
+	for _, p := range personas {
+		taken, err := im.store.IDTaken(models.LibraryPersona, p.ID.String(), 0)
+		if err != nil {
+			return err
+		}
+		write, duplicate := im.resolve(taken, &im.result.Personas)
+		if !write {
+			continue
+		}
+		persona := *p
+		if duplicate {
+			persona.ID = uuid.New()
+			im.duplicated(im.personas, p.ID.String(), persona.ID.String())
+		}
+		persona.ProfileID = remap(im.profiles, persona.ProfileID)
+		im.lib.Personas = append(im.lib.Personas, &persona)
+	}
+	return nil
+}
+
+func (im *importer) importTemplates(templates []*models.PromptTemplate) error {
+	// Whether each template's ID is taken, and its new ID if duplicated
+	taken := map[uuid.UUID]bool{}
+	for _, t := range templates {
+		if _, seen := taken[t.ID]; !seen {
+			idTaken, err := im.store.IDTaken(models.LibraryTemplate, t.ID.String(), 0)
+			if err != nil {
+				return err
+			}
+			taken[t.ID] = idTaken
+			if idTaken && im.strategy == StrategyDuplicate {
+				im.duplicated(im.templates, t.ID.String(), uuid.New().String())
+			}
+		}
+	}
+
+	for _, t := range templates {
+		versionTaken := false
+		if taken[t.ID] {
+			// Other versions of a duplicated template are duplicated too
+			versionTaken = im.strategy == StrategyDuplicate
+			if !versionTaken {
+				var err error
+				if versionTaken, err = im.store.IDTaken(models.LibraryTemplate, t.ID.String(), t.Version); err != nil {
+					return err
+				}
+			}
+		}
+		write, _ := im.resolve(versionTaken, &im.result.Templates)
+		if !write {
+			continue
+		}
+		template := *t
+		template.ID = uuid.MustParse(remap(im.templates, t.ID.String()))
+		template.PersonaID = uuid.MustParse(remap(im.personas, t.PersonaID.String()))
+		template.ProfileID = remap(im.profiles, template.ProfileID)
+		im.lib.Templates = append(im.lib.Templates, &template)
+	}
+	return nil
+}
+
+func (im *importer) importPrompts(prompts []*models.Prompt) error {
+	for _, p := range prompts {
+		taken, err := im.store.IDTaken(models.LibraryPrompt, p.ID.String(), 0)
+		if err != nil {
+			return err
+		}
+		write, duplicate := im.resolve(taken, &im.result.Prompts)
+		if !write {
+			continue
+		}
+		prompt := *p
+		if duplicate {
+			prompt.ID = uuid.New()
+			im.result.IDs[p.ID.String()] = prompt.ID.String()
+		}
+		prompt.TemplateID = uuid.MustParse(remap(im.templates, p.TemplateID.String()))
+		prompt.ProfileID = remap(im.profiles, prompt.ProfileID)
+		im.lib.Prompts = append(im.lib.Prompts, &prompt)
+	}
+	return nil
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/rahulguha/promptly/internal/render"
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
 	"github.com/spf13/viper"
 )
 
@@ -20,10 +23,39 @@ type Config struct {
 	DynamoDBRegion      string
 	DynamoDBTableName   string
 	DynamoDBActivityTableName string
+	// ProfileLayout is the template used to compile profiles into prompt
+	// text. Empty means render.DefaultProfileLayout.
+	ProfileLayout       string
+	// IntentMasterFile is the JSON file built-in intents are seeded from.
+	IntentMasterFile    string
+	// SectionOrder is the order prompt sections are assembled in. Empty
+	// means render.DefaultSectionOrder.
+	SectionOrder        []string
+	// TrashRetention is how long deleted items stay in the trash before
+	// they are purged. Zero keeps them until purged by hand.
+	TrashRetention      time.Duration
+	// DataDir is the data root holding the user databases.
+	DataDir             string
+	// MaxOpenDBs is how many user databases are kept open at most.
+	MaxOpenDBs          int
+	// DBIdleTTL is how long an unused user database stays open.
+	DBIdleTTL           time.Duration
+	// SQLite is how the user databases and the registry are opened.
+	SQLite              sqlite.Options
 }
 
-// New loads configuration from environment variables and .env file.
-func New() (*Config, error) {
+// StorageConfig is the part of the configuration that says where the user
+// databases are and how they are opened, for commands that work on them
+// without running the server.
+type StorageConfig struct {
+	// DataDir is the data root holding the user databases.
+	DataDir string
+	// SQLite is how the user databases and the registry are opened.
+	SQLite sqlite.Options
+}
+
+// load reads the .env file and sets up Viper to read environment variables.
+func load() {
 	// Load .env file. This is not fatal.
 	if err := godotenv.Load(); err != nil {
 		fmt.Fprintln(os.Stderr, "No .env file found, reading from environment")
@@ -32,6 +64,46 @@ func New() (*Config, error) {
 	// Set up Viper to read environment variables
 	viper.AutomaticEnv()
 	viper.SetDefault("PORT", "8082")
+	viper.SetDefault("INTENT_MASTER_FILE", "intent_master.json")
+	viper.SetDefault("TRASH_RETENTION_DAYS", 30)
+	viper.SetDefault("DATA_DIR", "./data")
+	viper.SetDefault("DB_MAX_OPEN", 256)
+	viper.SetDefault("DB_IDLE_TTL_MINUTES", 30)
+	defaults := sqlite.DefaultOptions()
+	viper.SetDefault("SQLITE_JOURNAL_MODE", defaults.JournalMode)
+	viper.SetDefault("SQLITE_SYNCHRONOUS", defaults.Synchronous)
+	viper.SetDefault("SQLITE_BUSY_TIMEOUT_MS", defaults.BusyTimeout.Milliseconds())
+	viper.SetDefault("SQLITE_FOREIGN_KEYS", defaults.ForeignKeys)
+}
+
+// sqliteOptions reads how user databases are opened.
+func sqliteOptions() (sqlite.Options, error) {
+	opts := sqlite.Options{
+		JournalMode: viper.GetString("SQLITE_JOURNAL_MODE"),
+		Synchronous: viper.GetString("SQLITE_SYNCHRONOUS"),
+		BusyTimeout: time.Duration(viper.GetInt("SQLITE_BUSY_TIMEOUT_MS")) * time.Millisecond,
+		ForeignKeys: viper.GetBool("SQLITE_FOREIGN_KEYS"),
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid SQLite settings: %w", err)
+	}
+	return opts, nil
+}
+
+// LoadStorage loads the storage configuration from environment variables
+// and .env file. Unlike New it needs none of the server's settings.
+func LoadStorage() (*StorageConfig, error) {
+	load()
+	opts, err := sqliteOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &StorageConfig{DataDir: viper.GetString("DATA_DIR"), SQLite: opts}, nil
+}
+
+// New loads configuration from environment variables and .env file.
+func New() (*Config, error) {
+	load()
 
 	cfg := &Config{
 		CognitoDomain:       viper.GetString("COGNITO_DOMAIN"),
@@ -44,6 +116,50 @@ func New() (*Config, error) {
 		DynamoDBRegion:      viper.GetString("DYNAMODB_REGION"),
 		DynamoDBTableName:   viper.GetString("DYNAMODB_TABLE_NAME"),
 		DynamoDBActivityTableName: viper.GetString("DYNAMODB_ACTIVITY_TABLE_NAME"),
+		IntentMasterFile:    viper.GetString("INTENT_MASTER_FILE"),
+		DataDir:             viper.GetString("DATA_DIR"),
+	}
+
+	// An optional file overrides the layout used to compile profiles
+	if layoutFile := viper.GetString("PROFILE_LAYOUT_FILE"); layoutFile != "" {
+		layout, err := os.ReadFile(layoutFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PROFILE_LAYOUT_FILE: %w", err)
+		}
+		if err := render.ValidateProfileLayout(string(layout)); err != nil {
+			return nil, fmt.Errorf("invalid PROFILE_LAYOUT_FILE: %w", err)
+		}
+		cfg.ProfileLayout = string(layout)
+	}
+
+	retentionDays := viper.GetInt("TRASH_RETENTION_DAYS")
+	if retentionDays < 0 {
+		return nil, fmt.Errorf("invalid TRASH_RETENTION_DAYS: %d", retentionDays)
+	}
+	cfg.TrashRetention = time.Duration(retentionDays) * 24 * time.Hour
+
+	cfg.MaxOpenDBs = viper.GetInt("DB_MAX_OPEN")
+	if cfg.MaxOpenDBs <= 0 {
+		return nil, fmt.Errorf("invalid DB_MAX_OPEN: %d", cfg.MaxOpenDBs)
+	}
+	idleMinutes := viper.GetInt("DB_IDLE_TTL_MINUTES")
+	if idleMinutes <= 0 {
+		return nil, fmt.Errorf("invalid DB_IDLE_TTL_MINUTES: %d", idleMinutes)
+	}
+	cfg.DBIdleTTL = time.Duration(idleMinutes) * time.Minute
+
+	sqliteOpts, err := sqliteOptions()
+	if err != nil {
+		return nil, err
+	}
+	cfg.SQLite = sqliteOpts
+
+	if order := viper.GetString("PROMPT_SECTION_ORDER"); order != "" {
+		sectionOrder, err := render.ParseSectionOrder(order)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROMPT_SECTION_ORDER: %w", err)
+		}
+		cfg.SectionOrder = sectionOrder
 	}
 
 	// --- Critical Debugging Step ---
@@ -56,6 +172,15 @@ func New() (*Config, error) {
 	fmt.Printf("DYNAMODB_REGION: %s\n", cfg.DynamoDBRegion)
 	fmt.Printf("DYNAMODB_TABLE_NAME: %s\n", cfg.DynamoDBTableName)
 	fmt.Printf("DYNAMODB_ACTIVITY_TABLE_NAME: %s\n", cfg.DynamoDBActivityTableName)
+	fmt.Printf("PROFILE_LAYOUT_FILE: %s\n", viper.GetString("PROFILE_LAYOUT_FILE"))
+	fmt.Printf("INTENT_MASTER_FILE: %s\n", cfg.IntentMasterFile)
+	fmt.Printf("PROMPT_SECTION_ORDER: %s\n", viper.GetString("PROMPT_SECTION_ORDER"))
+	fmt.Printf("TRASH_RETENTION_DAYS: %d\n", retentionDays)
+	fmt.Printf("DATA_DIR: %s\n", cfg.DataDir)
+	fmt.Printf("DB_MAX_OPEN: %d\n", cfg.MaxOpenDBs)
+	fmt.Printf("DB_IDLE_TTL_MINUTES: %d\n", idleMinutes)
+	fmt.Printf("SQLITE: journal_mode=%s synchronous=%s busy_timeout=%v foreign_keys=%t\n",
+		cfg.SQLite.JournalMode, cfg.SQLite.Synchronous, cfg.SQLite.BusyTimeout, cfg.SQLite.ForeignKeys)
 	fmt.Println("--------------------------")
 
 	if cfg.CognitoDomain == "" {
@@ -0,0 +1,202 @@
+package extract
+
+// term maps a phrase found in text to the value it stands for.
+type term struct {
+	phrase string
+	value  string
+}
+
+// place is a known city with the state and country it implies.
+type place struct {
+	city, state, country string
+	aliases              []string
+}
+
+var cities = []place{
+	{city: "New York", state: "New York", country: "USA", aliases: []string{"new york city", "nyc", "new york"}},
+	{city: "Los Angeles", state: "California", country: "USA", aliases: []string{"los angeles", "la"}},
+	{city: "San Francisco", state: "California", country: "USA", aliases: []string{"san francisco", "sf"}},
+	{city: "San Diego", state: "California", country: "USA"},
+	{city: "San Jose", state: "California", country: "USA"},
+	{city: "Seattle", state: "Washington", country: "USA"},
+	{city: "Portland", state: "Oregon", country: "USA"},
+	{city: "Chicago", state: "Illinois", country: "USA"},
+	{city: "Houston", state: "Texas", country: "USA"},
+	{city: "Dallas", state: "Texas", country: "USA"},
+	{city: "Austin", state: "Texas", country: "USA"},
+	{city: "San Antonio", state: "Texas", country: "USA"},
+	{city: "Phoenix", state: "Arizona", country: "USA"},
+	{city: "Denver", state: "Colorado", country: "USA"},
+	{city: "Boston", state: "Massachusetts", country: "USA"},
+	{city: "Philadelphia", state: "Pennsylvania", country: "USA"},
+	{city: "Pittsburgh", state: "Pennsylvania", country: "USA"},
+	{city: "Atlanta", state: "Georgia", country: "USA"},
+	{city: "Miami", state: "Florida", country: "USA"},
+	{city: "Orlando", state: "Florida", country: "USA"},
+	{city: "Tampa", state: "Florida", country: "USA"},
+	{city: "Nashville", state: "Tennessee", country: "USA"},
+	{city: "Detroit", state: "Michigan", country: "USA"},
+	{city: "Minneapolis", state: "Minnesota", country: "USA"},
+	{city: "Las Vegas", state: "Nevada", country: "USA"},
+	{city: "Salt Lake City", state: "Utah", country: "USA"},
+	{city: "Charlotte", state: "North Carolina", country: "USA"},
+	{city: "Raleigh", state: "North Carolina", country: "USA"},
+	{city: "Baltimore", state: "Maryland", country: "USA"},
+	{city: "Washington", state: "District of Columbia", country: "USA", aliases: []string{"washington dc", "washington, dc", "dc"}},
+	{city: "Toronto", state: "Ontario", country: "Canada"},
+	{city: "Vancouver", state: "British Columbia", country: "Canada"},
+	{city: "Montreal", state: "Quebec", country: "Canada"},
+	{city: "London", country: "UK"},
+	{city: "Manchester", country: "UK"},
+	{city: "Edinburgh", country: "UK"},
+	{city: "Dublin", country: "Ireland"},
+	{city: "Paris", country: "France"},
+	{city: "Berlin", country: "Germany"},
+	{city: "Munich", country: "Germany"},
+	{city: "Amsterdam", country: "Netherlands"},
+	{city: "Madrid", country: "Spain"},
+	{city: "Barcelona", country: "Spain"},
+	{city: "Rome", country: "Italy"},
+	{city: "Milan", country: "Italy"},
+	{city: "Stockholm", country: "Sweden"},
+	{city: "Zurich", country: "Switzerland"},
+	{city: "Mumbai", state: "Maharashtra", country: "India", aliases: []string{"mumbai", "bombay"}},
+	{city: "Pune", state: "Maharashtra", country: "India"},
+	{city: "Delhi", state: "Delhi", country: "India", aliases: []string{"new delhi", "delhi"}},
+	{city: "Bangalore", state: "Karnataka", country: "India", aliases: []string{"bangalore", "bengaluru"}},
+	{city: "Hyderabad", state: "Telangana", country: "India"},
+	{city: "Chennai", state: "Tamil Nadu", country: "India"},
+	{city: "Kolkata", state: "West Bengal", country: "India", aliases: []string{"kolkata", "calcutta"}},
+	{city: "Tokyo", country: "Japan"},
+	{city: "Seoul", country: "South Korea"},
+	{city: "Beijing", country: "China"},
+	{city: "Shanghai", country: "China"},
+	{city: "Singapore", country: "Singapore"},
+	{city: "Sydney", state: "New South Wales", country: "Australia"},
+	{city: "Melbourne", state: "Victoria", country: "Australia"},
+	{city: "Auckland", country: "New Zealand"},
+	{city: "Dubai", country: "UAE"},
+	{city: "Lagos", country: "Nigeria"},
+	{city: "Nairobi", country: "Kenya"},
+	{city: "Cape Town", country: "South Africa"},
+	{city: "Johannesburg", country: "South Africa"},
+	{city: "Sao Paulo", country: "Brazil", aliases: []string{"sao paulo", "são paulo"}},
+	{city: "Mexico City", country: "Mexico"},
+	{city: "Buenos Aires", country: "Argentina"},
+}
+
+// usStates lists US states with their postal abbreviations.
+var usStates = []struct{ name, abbr string }{
+	{"Alabama", "AL"}, {"Alaska", "AK"}, {"Arizona", "AZ"}, {"Arkansas", "AR"},
+	{"California", "CA"}, {"Colorado", "CO"}, {"Connecticut", "CT"}, {"Delaware", "DE"},
+	{"Florida", "FL"}, {"Georgia", "GA"}, {"Hawaii", "HI"}, {"Idaho", "ID"},
+	{"Illinois", "IL"}, {"Indiana", "IN"}, {"Iowa", "IA"}, {"Kansas", "KS"},
+	{"Kentucky", "KY"}, {"Louisiana", "LA"}, {"Maine", "ME"}, {"Maryland", "MD"},
+	{"Massachusetts", "MA"}, {"Michigan", "MI"}, {"Minnesota", "MN"}, {"Mississippi", "MS"},
+	{"Missouri", "MO"}, {"Montana", "MT"}, {"Nebraska", "NE"}, {"Nevada", "NV"},
+	{"New Hampshire", "NH"}, {"New Jersey", "NJ"}, {"New Mexico", "NM"}, {"New York", "NY"},
+	{"North Carolina", "NC"}, {"North Dakota", "ND"}, {"Ohio", "OH"}, {"Oklahoma", "OK"},
+	{"Oregon", "OR"}, {"Pennsylvania", "PA"}, {"Rhode Island", "RI"}, {"South Carolina", "SC"},
+	{"South Dakota", "SD"}, {"Tennessee", "TN"}, {"Texas", "TX"}, {"Utah", "UT"},
+	{"Vermont", "VT"}, {"Virginia", "VA"}, {"Washington", "WA"}, {"West Virginia", "WV"},
+	{"Wisconsin", "WI"}, {"Wyoming", "WY"},
+}
+
+var countries = []term{
+	{"united states of america", "USA"}, {"united states", "USA"}, {"usa", "USA"},
+	{"canada", "Canada"}, {"mexico", "Mexico"}, {"brazil", "Brazil"}, {"argentina", "Argentina"},
+	{"united kingdom", "UK"}, {"uk", "UK"}, {"england", "UK"}, {"scotland", "UK"}, {"wales", "UK"}, {"britain", "UK"},
+	{"ireland", "Ireland"}, {"france", "France"}, {"germany", "Germany"}, {"spain", "Spain"}, {"portugal", "Portugal"},
+	{"italy", "Italy"}, {"netherlands", "Netherlands"}, {"belgium", "Belgium"}, {"switzerland", "Switzerland"},
+	{"austria", "Austria"}, {"sweden", "Sweden"}, {"norway", "Norway"}, {"denmark", "Denmark"}, {"finland", "Finland"},
+	{"poland", "Poland"}, {"greece", "Greece"}, {"turkey", "Turkey"}, {"russia", "Russia"}, {"ukraine", "Ukraine"},
+	{"israel", "Israel"}, {"egypt", "Egypt"}, {"nigeria", "Nigeria"}, {"kenya", "Kenya"}, {"ghana", "Ghana"},
+	{"south africa", "South Africa"}, {"uae", "UAE"}, {"united arab emirates", "UAE"}, {"saudi arabia", "Saudi Arabia"},
+	{"india", "India"}, {"pakistan", "Pakistan"}, {"bangladesh", "Bangladesh"}, {"sri lanka", "Sri Lanka"}, {"nepal", "Nepal"},
+	{"china", "China"}, {"japan", "Japan"}, {"south korea", "South Korea"}, {"korea", "South Korea"}, {"vietnam", "Vietnam"},
+	{"thailand", "Thailand"}, {"philippines", "Philippines"}, {"indonesia", "Indonesia"}, {"malaysia", "Malaysia"},
+	{"singapore", "Singapore"}, {"australia", "Australia"}, {"new zealand", "New Zealand"},
+}
+
+var occupations = []term{
+	{"software engineer", "Software Engineer"}, {"software developer", "Software Developer"},
+	{"web developer", "Web Developer"}, {"devops engineer", "DevOps Engineer"}, {"data scientist", "Data Scientist"},
+	{"data analyst", "Data Analyst"}, {"data engineer", "Data Engineer"}, {"product manager", "Product Manager"},
+	{"project manager", "Project Manager"}, {"marketing manager", "Marketing Manager"}, {"graphic designer", "Graphic Designer"},
+	{"ux designer", "UX Designer"}, {"real estate agent", "Real Estate Agent"}, {"police officer", "Police Officer"},
+	{"social worker", "Social Worker"}, {"truck driver", "Truck Driver"}, {"business owner", "Business Owner"},
+	{"stay-at-home parent", "Homemaker"}, {"stay at home parent", "Homemaker"}, {"stay-at-home mom", "Homemaker"},
+	{"stay-at-home dad", "Homemaker"}, {"content creator", "Content Creator"}, {"civil servant", "Civil Servant"},
+	{"developer", "Developer"}, {"programmer", "Programmer"}, {"engineer", "Engineer"}, {"architect", "Architect"},
+	{"student", "Student"}, {"intern", "Intern"}, {"teacher", "Teacher"}, {"professor", "Professor"}, {"tutor", "Tutor"},
+	{"nurse", "Nurse"}, {"doctor", "Doctor"}, {"physician", "Physician"}, {"surgeon", "Surgeon"}, {"dentist", "Dentist"},
+	{"pharmacist", "Pharmacist"}, {"paramedic", "Paramedic"}, {"veterinarian", "Veterinarian"}, {"psychologist", "Psychologist"},
+	{"therapist", "Therapist"}, {"lawyer", "Lawyer"}, {"attorney", "Attorney"}, {"accountant", "Accountant"},
+	{"banker", "Banker"}, {"economist", "Economist"}, {"analyst", "Analyst"}, {"consultant", "Consultant"},
+	{"designer", "Designer"}, {"manager", "Manager"}, {"marketer", "Marketer"}, {"salesperson", "Salesperson"},
+	{"writer", "Writer"}, {"author", "Author"}, {"journalist", "Journalist"}, {"editor", "Editor"}, {"translator", "Translator"},
+	{"artist", "Artist"}, {"musician", "Musician"}, {"photographer", "Photographer"}, {"actor", "Actor"},
+	{"filmmaker", "Filmmaker"}, {"chef", "Chef"}, {"cook", "Cook"}, {"baker", "Baker"}, {"barista", "Barista"},
+	{"farmer", "Farmer"}, {"electrician", "Electrician"}, {"plumber", "Plumber"}, {"carpenter", "Carpenter"},
+	{"mechanic", "Mechanic"}, {"pilot", "Pilot"}, {"firefighter", "Firefighter"}, {"soldier", "Soldier"},
+	{"scientist", "Scientist"}, {"researcher", "Researcher"}, {"librarian", "Librarian"}, {"entrepreneur", "Entrepreneur"},
+	{"founder", "Founder"}, {"freelancer", "Freelancer"}, {"investor", "Investor"}, {"coach", "Coach"}, {"athlete", "Athlete"},
+	{"receptionist", "Receptionist"}, {"administrator", "Administrator"}, {"cashier", "Cashier"}, {"driver", "Driver"},
+	{"homemaker", "Homemaker"}, {"retiree", "Retired"}, {"retired", "Retired"}, {"ceo", "CEO"}, {"cto", "CTO"},
+}
+
+// educationLevels are ordered from lowest to highest so ties go to the
+// higher level.
+var educationLevels = []string{"High School", "Undergraduate", "Bachelor's", "Graduate", "Master's", "Doctorate"}
+
+var education = []struct {
+	term
+	confidence float64
+}{
+	{term{"high school student", "High School"}, 0.9},
+	{term{"in high school", "High School"}, 0.85},
+	{term{"high school diploma", "High School"}, 0.85},
+	{term{"high schooler", "High School"}, 0.9},
+	{term{"undergraduate", "Undergraduate"}, 0.85},
+	{term{"undergrad", "Undergraduate"}, 0.85},
+	{term{"college student", "Undergraduate"}, 0.85},
+	{term{"university student", "Undergraduate"}, 0.85},
+	{term{"freshman", "Undergraduate"}, 0.6},
+	{term{"sophomore", "Undergraduate"}, 0.6},
+	{term{"bachelor's", "Bachelor's"}, 0.85},
+	{term{"bachelors", "Bachelor's"}, 0.85},
+	{term{"bachelor of", "Bachelor's"}, 0.85},
+	{term{"graduate student", "Graduate"}, 0.85},
+	{term{"grad student", "Graduate"}, 0.85},
+	{term{"grad school", "Graduate"}, 0.8},
+	{term{"graduate school", "Graduate"}, 0.8},
+	{term{"master's", "Master's"}, 0.85},
+	{term{"masters", "Master's"}, 0.8},
+	{term{"master of", "Master's"}, 0.85},
+	{term{"mba", "Master's"}, 0.85},
+	{term{"msc", "Master's"}, 0.8},
+	{term{"phd", "Doctorate"}, 0.9},
+	{term{"ph.d", "Doctorate"}, 0.9},
+	{term{"doctorate", "Doctorate"}, 0.9},
+	{term{"doctoral", "Doctorate"}, 0.85},
+}
+
+var tones = []term{
+	{"casual", "Casual"}, {"informal", "Casual"}, {"relaxed", "Casual"}, {"laid-back", "Casual"},
+	{"conversational", "Casual"}, {"friendly", "Casual"},
+	{"formal", "Formal"}, {"professional", "Formal"},
+	{"concise", "Concise"}, {"brief", "Concise"}, {"succinct", "Concise"}, {"to the point", "Concise"},
+	{"detailed", "Detailed"}, {"thorough", "Detailed"}, {"in-depth", "Detailed"},
+	{"humorous", "Humorous"}, {"funny", "Humorous"}, {"witty", "Humorous"}, {"playful", "Humorous"},
+	{"encouraging", "Encouraging"}, {"supportive", "Encouraging"}, {"gentle", "Encouraging"},
+}
+
+var genders = []term{
+	{"man", "Male"}, {"male", "Male"}, {"guy", "Male"}, {"boy", "Male"}, {"gentleman", "Male"},
+	{"father", "Male"}, {"dad", "Male"}, {"husband", "Male"},
+	{"woman", "Female"}, {"female", "Female"}, {"girl", "Female"}, {"lady", "Female"},
+	{"mother", "Female"}, {"mom", "Female"}, {"mum", "Female"}, {"wife", "Female"},
+	{"non-binary", "Non-binary"}, {"nonbinary", "Non-binary"}, {"enby", "Non-binary"},
+}
+
+var pronounGenders = map[string]string{"he": "Male", "she": "Female", "they": "Non-binary"}
@@ -0,0 +1,550 @@
+// Package extract proposes profile attributes from a free-text description.
+// It works entirely from local rules and dictionaries, so results are
+// deterministic and need no network access.
+package extract
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// Result is the attributes proposed for a description. Confidence and
+// Evidence are keyed by the attribute's JSON path (e.g. "location.city") and
+// only hold fields that were found.
+type Result struct {
+	Attributes models.Attributes  `json:"attributes"`
+	Confidence map[string]float64 `json:"confidence"`
+	Evidence   map[string]string  `json:"evidence"`
+}
+
+// Field names used as Confidence and Evidence keys
+const (
+	FieldAge        = "age"
+	FieldGender     = "gender"
+	FieldCity       = "location.city"
+	FieldState      = "location.state"
+	FieldCountry    = "location.country"
+	FieldEducation  = "education_level"
+	FieldOccupation = "occupation"
+	FieldInterests  = "interests"
+	FieldTone       = "tone_preference"
+)
+
+// Parse extracts attributes from text.
+func Parse(text string) *Result {
+	r := &Result{Confidence: map[string]float64{}, Evidence: map[string]string{}}
+	sentences := splitSentences(text)
+	for _, s := range sentences {
+		r.age(s)
+		r.gender(s)
+		r.location(s)
+		r.occupation(s)
+		r.education(s)
+		r.interests(s)
+		r.tone(s)
+	}
+	r.inferEducation(sentences)
+	return r
+}
+
+// claim reports whether a value found with confidence should replace what is
+// already recorded for field, and records the confidence and evidence if so.
+func (r *Result) claim(field string, confidence float64, evidence string) bool {
+	if current, ok := r.Confidence[field]; ok && current >= confidence {
+		return false
+	}
+	r.Confidence[field] = round(confidence)
+	r.Evidence[field] = strings.TrimSpace(evidence)
+	return true
+}
+
+func round(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
+
+var sentenceBreak = regexp.MustCompile(`[.!?;]+(?:\s+|$)|\n+`)
+
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, s := range sentenceBreak.Split(text, -1) {
+		if s = strings.TrimSpace(s); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+	return sentences
+}
+
+// --- Age ---
+
+var (
+	ageYearsOld = regexp.MustCompile(`(?i)\b(\d{1,3})\s*-?\s*(?:years?|yrs?)\s*-?\s*old\b`)
+	ageStated   = regexp.MustCompile(`(?i)\bage(?:d)?\s*(?:is|of|:)?\s*(\d{1,3})\b`)
+	ageIAm      = regexp.MustCompile(`(?i)\b(?:i am|i'm|im)\s+(\d{1,3})\b(\s*\S+)?`)
+	ageDecade   = regexp.MustCompile(`(?i)\bin my (early |mid |mid-|late )?(teens|twenties|thirties|forties|fifties|sixties|seventies|eighties|20s|30s|40s|50s|60s|70s|80s)\b`)
+)
+
+// Words that make "I'm 6 ..." a measurement rather than an age.
+var notAgeUnits = map[string]bool{
+	"feet": true, "foot": true, "ft": true, "inches": true, "cm": true, "kg": true, "lbs": true,
+	"pounds": true, "percent": true, "%": true, "minutes": true, "hours": true, "miles": true, "km": true,
+}
+
+var decades = map[string]int{
+	"teens": 10, "twenties": 20, "thirties": 30, "forties": 40, "fifties": 50, "sixties": 60,
+	"seventies": 70, "eighties": 80, "20s": 20, "30s": 30, "40s": 40, "50s": 50, "60s": 60, "70s": 70, "80s": 80,
+}
+
+func (r *Result) age(s string) {
+	setAge := func(age int, confidence float64, evidence string) {
+		if age < 1 || age > 120 {
+			return
+		}
+		if r.claim(FieldAge, confidence, evidence) {
+			r.Attributes.Age = age
+		}
+	}
+
+	if m := ageYearsOld.FindStringSubmatch(s); m != nil {
+		age, _ := strconv.Atoi(m[1])
+		setAge(age, 0.95, m[0])
+	}
+	if m := ageStated.FindStringSubmatch(s); m != nil {
+		age, _ := strconv.Atoi(m[1])
+		setAge(age, 0.9, m[0])
+	}
+	if m := ageIAm.FindStringSubmatch(s); m != nil {
+		unit := strings.ToLower(strings.TrimSpace(m[2]))
+		if !notAgeUnits[unit] && !strings.HasPrefix(unit, "year") {
+			age, _ := strconv.Atoi(m[1])
+			setAge(age, 0.75, m[0])
+		}
+	}
+	if m := ageDecade.FindStringSubmatch(s); m != nil {
+		age := decades[strings.ToLower(m[2])]
+		switch strings.TrimSpace(strings.ToLower(m[1])) {
+		case "early":
+			age += 2
+		case "late":
+			age += 8
+		default:
+			age += 5
+		}
+		if age < 13 {
+			age = 15 // "in my teens"
+		}
+		setAge(age, 0.5, m[0])
+	}
+}
+
+// --- Gender ---
+
+var (
+	genderPronouns = regexp.MustCompile(`(?i)\bpronouns\s*(?:are|:)?\s*(he|she|they)\b`)
+	genderSlash    = regexp.MustCompile(`(?i)\b(he)/him\b|\b(she)/her\b|\b(they)/them\b`)
+	genderSelf     *regexp.Regexp
+	genderAsA      *regexp.Regexp
+	genderParent   = regexp.MustCompile(`(?i)\b(mother|father|mom|dad|mum)\s+(?:of|to)\b`)
+	genderValues   = map[string]string{}
+	// Words between "I am" and a gender word that end the self-description.
+	genderBreaks = regexp.MustCompile(`(?i)\b(?:and|my|with|of|to|for|but|or|who|have|has|whose)\b`)
+)
+
+func init() {
+	words := make([]string, len(genders))
+	for i, g := range genders {
+		words[i] = regexp.QuoteMeta(g.phrase)
+		genderValues[g.phrase] = g.value
+	}
+	alt := strings.Join(words, "|")
+	genderSelf = regexp.MustCompile(`(?i)\b(?:i am|i'm|im|i identify as)\s+(?:an?\s+)?((?:[\w'-]+\s+){0,3}?)(` + alt + `)\b`)
+	genderAsA = regexp.MustCompile(`(?i)\bas an?\s+(` + alt + `)\b`)
+}
+
+func (r *Result) gender(s string) {
+	set := func(value string, confidence float64, evidence string) {
+		if value != "" && r.claim(FieldGender, confidence, evidence) {
+			r.Attributes.Gender = value
+		}
+	}
+
+	if m := genderPronouns.FindStringSubmatch(s); m != nil {
+		set(pronounGenders[strings.ToLower(m[1])], 0.95, m[0])
+	}
+	if m := genderSlash.FindStringSubmatch(s); m != nil {
+		set(pronounGenders[strings.ToLower(m[1]+m[2]+m[3])], 0.9, m[0])
+	}
+	if m := genderSelf.FindStringSubmatch(s); m != nil && !genderBreaks.MatchString(m[1]) {
+		set(genderValues[strings.ToLower(m[2])], 0.9, m[0])
+	}
+	if m := genderAsA.FindStringSubmatch(s); m != nil {
+		set(genderValues[strings.ToLower(m[1])], 0.75, m[0])
+	}
+	if m := genderParent.FindStringSubmatch(s); m != nil {
+		set(genderValues[strings.ToLower(m[1])], 0.75, m[0])
+	}
+}
+
+// --- Location ---
+
+// matcher finds a dictionary phrase as a whole word, ignoring case.
+type matcher struct {
+	re    *regexp.Regexp
+	value string
+}
+
+func newMatcher(phrase, value string) matcher {
+	return matcher{re: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(phrase) + `\b`), value: value}
+}
+
+type cityMatcher struct {
+	matcher
+	place *place
+	short bool
+}
+
+var (
+	cityMatchers    []cityMatcher
+	stateMatchers   []matcher
+	stateAbbrevs    []matcher
+	countryMatchers []matcher
+	// locationCue is text that directly precedes a place name.
+	locationCue = regexp.MustCompile(`(?i)(?:\b(?:in|from|near|at|to|of|around|outside)\s+(?:the\s+)?|,\s*)$`)
+)
+
+func init() {
+	for i := range cities {
+		p := &cities[i]
+		aliases := p.aliases
+		if len(aliases) == 0 {
+			aliases = []string{strings.ToLower(p.city)}
+		}
+		for _, alias := range aliases {
+			cityMatchers = append(cityMatchers, cityMatcher{matcher: newMatcher(alias, p.city), place: p, short: len(alias) <= 3})
+		}
+	}
+	for _, st := range usStates {
+		stateMatchers = append(stateMatchers, newMatcher(strings.ToLower(st.name), st.name))
+		// Abbreviations only count in "City, TX" form and in capitals.
+		stateAbbrevs = append(stateAbbrevs, matcher{re: regexp.MustCompile(`,\s*(` + st.abbr + `)\b`), value: st.name})
+	}
+	for _, c := range countries {
+		countryMatchers = append(countryMatchers, newMatcher(c.phrase, c.value))
+	}
+}
+
+// cued reports whether the text before index in s introduces a place.
+func cued(s string, index int) bool {
+	return locationCue.MatchString(s[:index])
+}
+
+func (r *Result) location(s string) {
+	loc := &r.Attributes.Location
+
+	// A state name inside a longer city name ("Washington DC") isn't the
+	// state.
+	var citySpans [][]int
+	insideCity := func(idx []int) bool {
+		for _, span := range citySpans {
+			if span[0] <= idx[0] && idx[1] <= span[1] && span[1]-span[0] > idx[1]-idx[0] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, cm := range cityMatchers {
+		for _, idx := range cm.re.FindAllStringIndex(s, -1) {
+			citySpans = append(citySpans, idx)
+			confidence := 0.6
+			if cued(s, idx[0]) {
+				confidence = 0.85
+			} else if cm.short {
+				continue
+			}
+			evidence := s[idx[0]:idx[1]]
+			if r.claim(FieldCity, confidence, evidence) {
+				loc.City = cm.place.city
+			}
+			// The city implies its state and country, less surely than
+			// naming them would.
+			if cm.place.state != "" && r.claim(FieldState, confidence-0.15, evidence) {
+				loc.State = cm.place.state
+			}
+			if r.claim(FieldCountry, confidence-0.15, evidence) {
+				loc.Country = cm.place.country
+			}
+		}
+	}
+
+	setState := func(name string, confidence float64, evidence string) {
+		if r.claim(FieldState, confidence, evidence) {
+			loc.State = name
+		}
+		if r.claim(FieldCountry, confidence-0.15, evidence) {
+			loc.Country = "USA"
+		}
+	}
+	for _, sm := range stateMatchers {
+		if idx := sm.re.FindStringIndex(s); idx != nil && !insideCity(idx) {
+			confidence := 0.6
+			if cued(s, idx[0]) {
+				confidence = 0.85
+			}
+			setState(sm.value, confidence, s[idx[0]:idx[1]])
+		}
+	}
+	for _, sm := range stateAbbrevs {
+		if m := sm.re.FindStringSubmatch(s); m != nil {
+			setState(sm.value, 0.85, m[1])
+		}
+	}
+
+	for _, cm := range countryMatchers {
+		if idx := cm.re.FindStringIndex(s); idx != nil {
+			confidence := 0.6
+			if cued(s, idx[0]) {
+				confidence = 0.9
+			}
+			if r.claim(FieldCountry, confidence, s[idx[0]:idx[1]]) {
+				loc.Country = cm.value
+			}
+		}
+	}
+}
+
+// --- Occupation ---
+
+var (
+	occupationMatchers []matcher
+	occupationCue      = regexp.MustCompile(`(?i)\b(?:i am|i'm|im|work as|working as|worked as|job as|career as|profession|occupation|job is)\b`)
+)
+
+func init() {
+	for _, o := range occupations {
+		occupationMatchers = append(occupationMatchers, newMatcher(o.phrase, o.value))
+	}
+}
+
+func (r *Result) occupation(s string) {
+	// Dictionary entries are longest-first for shared words, so spans
+	// already taken by e.g. "software engineer" aren't matched again as
+	// "engineer".
+	var taken [][]int
+	overlaps := func(idx []int) bool {
+		for _, t := range taken {
+			if idx[0] < t[1] && t[0] < idx[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, om := range occupationMatchers {
+		for _, idx := range om.re.FindAllStringIndex(s, -1) {
+			if overlaps(idx) {
+				continue
+			}
+			taken = append(taken, idx)
+
+			confidence := 0.5
+			start := idx[0] - 40
+			if start < 0 {
+				start = 0
+			}
+			if occupationCue.MatchString(s[start:idx[0]]) {
+				confidence = 0.85
+			}
+			if r.claim(FieldOccupation, confidence, s[idx[0]:idx[1]]) {
+				r.Attributes.Occupation = om.value
+			}
+		}
+	}
+}
+
+// --- Education ---
+
+var educationMatchers []struct {
+	matcher
+	confidence float64
+}
+
+func init() {
+	for _, e := range education {
+		educationMatchers = append(educationMatchers, struct {
+			matcher
+			confidence float64
+		}{newMatcher(e.phrase, e.value), e.confidence})
+	}
+}
+
+func educationRank(level string) int {
+	for i, l := range educationLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Result) education(s string) {
+	for _, em := range educationMatchers {
+		idx := em.re.FindStringIndex(s)
+		if idx == nil {
+			continue
+		}
+		current, found := r.Confidence[FieldEducation]
+		// Equal confidence goes to the higher level: a "PhD student" is
+		// more than a student.
+		if found && em.confidence == current && educationRank(em.value) > educationRank(r.Attributes.EducationLevel) {
+			delete(r.Confidence, FieldEducation)
+		}
+		if r.claim(FieldEducation, em.confidence, s[idx[0]:idx[1]]) {
+			r.Attributes.EducationLevel = em.value
+		}
+	}
+}
+
+// inferEducation guesses an undergraduate level for students who say what
+// they study but not where.
+func (r *Result) inferEducation(sentences []string) {
+	if r.Attributes.EducationLevel != "" || r.Attributes.Occupation != "Student" {
+		return
+	}
+	for _, s := range sentences {
+		if m := studyCue.FindString(s); m != "" {
+			if r.claim(FieldEducation, 0.6, m) {
+				r.Attributes.EducationLevel = "Undergraduate"
+			}
+			return
+		}
+	}
+}
+
+// --- Interests ---
+
+var (
+	interestCue = regexp.MustCompile(`(?i)\b(?:love|loves|loving|enjoy|enjoys|enjoying|passionate about|interested in|fascinated by|(?:a )?(?:big |huge )?fan of|hobbies are|hobbies include|hobby is|i (?:really |also )?like|i'm into|i am into)\s+`)
+	studyCue    = regexp.MustCompile(`(?i)\b(?:studying|study|studies|majoring in|major in|degree in|student of)\s+`)
+	// interestStop ends a list of interests.
+	interestStop = regexp.MustCompile(`(?i)\s(?:but|because|when|while|since|so|although|though|which|who|at|in|on|with|from|during)\s`)
+	// studyStop ends a field of study.
+	studyStop      = regexp.MustCompile(`(?i)\s(?:and|but|at|in|on|with|from|because|while)\s|,`)
+	interestSplit  = regexp.MustCompile(`(?i),|\s(?:and|or)\s|&|/`)
+	interestPrefix = regexp.MustCompile(`(?i)^(?:to|the|a|an|my|all|also|really|and)\s+`)
+	interestFiller = map[string]bool{"it": true, "them": true, "this": true, "that": true, "things": true, "stuff": true, "you": true, "everything": true}
+)
+
+func (r *Result) interests(s string) {
+	add := func(item string, confidence float64, evidence string) {
+		item = strings.TrimSpace(item)
+		for {
+			trimmed := interestPrefix.ReplaceAllString(item, "")
+			if trimmed == item {
+				break
+			}
+			item = trimmed
+		}
+		item = strings.Trim(item, " \"'()")
+		if item == "" || interestFiller[strings.ToLower(item)] || len(strings.Fields(item)) > 4 {
+			return
+		}
+		item = titleCase(item)
+		for _, existing := range r.Attributes.Interests {
+			if strings.EqualFold(existing, item) {
+				return
+			}
+		}
+		r.Attributes.Interests = append(r.Attributes.Interests, item)
+		if confidence > r.Confidence[FieldInterests] {
+			r.Confidence[FieldInterests] = round(confidence)
+		}
+		evidence = strings.TrimSpace(evidence)
+		switch prev := r.Evidence[FieldInterests]; {
+		case prev == "":
+			r.Evidence[FieldInterests] = evidence
+		case !strings.Contains(prev, evidence):
+			r.Evidence[FieldInterests] = prev + "; " + evidence
+		}
+	}
+
+	for _, idx := range studyCue.FindAllStringIndex(s, -1) {
+		rest := s[idx[1]:]
+		if stop := studyStop.FindStringIndex(rest); stop != nil {
+			rest = rest[:stop[0]]
+		}
+		add(rest, 0.6, s[idx[0]:idx[1]]+rest)
+	}
+
+	for _, idx := range interestCue.FindAllStringIndex(s, -1) {
+		// "would like" and "I'd like" are requests, not interests
+		before := strings.ToLower(s[:idx[0]])
+		if strings.HasSuffix(before, "would ") || strings.HasSuffix(before, "i'd ") {
+			continue
+		}
+		rest := s[idx[1]:]
+		if next := interestCue.FindStringIndex(rest); next != nil {
+			rest = rest[:next[0]]
+		}
+		if stop := interestStop.FindStringIndex(rest); stop != nil {
+			rest = rest[:stop[0]]
+		}
+		for _, item := range interestSplit.Split(rest, -1) {
+			add(item, 0.7, s[idx[0]:idx[1]]+rest)
+		}
+	}
+}
+
+// titleCase capitalizes each word, leaving acronyms and short joining words
+// alone.
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		lower := strings.ToLower(w)
+		if i > 0 && (lower == "of" || lower == "and" || lower == "the" || lower == "in" || lower == "for") {
+			words[i] = lower
+			continue
+		}
+		if strings.ToUpper(w) == w {
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(first)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+// --- Tone ---
+
+var (
+	toneMatchers []matcher
+	// toneCue marks a sentence as talking about how answers should sound;
+	// tone words elsewhere ("a friendly neighbor") are ignored.
+	toneCue = regexp.MustCompile(`(?i)\b(?:tone|style|prefer|preferred|answers|responses|explanations|replies|talk|speak|write|keep it|keep things)\b`)
+)
+
+func init() {
+	for _, t := range tones {
+		toneMatchers = append(toneMatchers, newMatcher(t.phrase, t.value))
+	}
+}
+
+func (r *Result) tone(s string) {
+	if !toneCue.MatchString(s) {
+		return
+	}
+	first := -1
+	var value, evidence string
+	for _, tm := range toneMatchers {
+		if idx := tm.re.FindStringIndex(s); idx != nil && (first < 0 || idx[0] < first) {
+			first, value, evidence = idx[0], tm.value, s[idx[0]:idx[1]]
+		}
+	}
+	if first >= 0 && r.claim(FieldTone, 0.8, evidence) {
+		r.Attributes.TonePreference = value
+	}
+}
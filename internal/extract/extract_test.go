@@ -0,0 +1,98 @@
+package extract
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestParse_Example(t *testing.T) {
+	result := Parse("I am a 25-year-old student in Dallas studying Computer Science and love history.")
+
+	expected := models.Attributes{
+		Age:            25,
+		Location:       models.Location{City: "Dallas", State: "Texas", Country: "USA"},
+		EducationLevel: "Undergraduate",
+		Occupation:     "Student",
+		Interests:      []string{"Computer Science", "History"},
+	}
+	if !reflect.DeepEqual(result.Attributes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Attributes)
+	}
+
+	for field, min := range map[string]float64{FieldAge: 0.9, FieldCity: 0.8, FieldOccupation: 0.8} {
+		if result.Confidence[field] < min {
+			t.Errorf("Expected %s confidence of at least %v, got %v", field, min, result.Confidence[field])
+		}
+	}
+	if result.Confidence[FieldState] >= result.Confidence[FieldCity] {
+		t.Errorf("Expected inferred state to be less certain than the city, got %v", result.Confidence)
+	}
+	if result.Evidence[FieldAge] != "25-year-old" {
+		t.Errorf("Expected age evidence '25-year-old', got %q", result.Evidence[FieldAge])
+	}
+	if _, ok := result.Confidence[FieldGender]; ok {
+		t.Errorf("Expected no gender, got %q", result.Attributes.Gender)
+	}
+}
+
+func TestParse_Fields(t *testing.T) {
+	tests := []struct {
+		text  string
+		check func(a models.Attributes) bool
+	}{
+		{"I'm 41 and work as a software engineer.", func(a models.Attributes) bool {
+			return a.Age == 41 && a.Occupation == "Software Engineer"
+		}},
+		{"I'm 6 feet tall.", func(a models.Attributes) bool { return a.Age == 0 }},
+		{"Aged 67, retired.", func(a models.Attributes) bool { return a.Age == 67 && a.Occupation == "Retired" }},
+		{"I'm in my late thirties.", func(a models.Attributes) bool { return a.Age == 38 }},
+		{"I am a single mother of two.", func(a models.Attributes) bool { return a.Gender == "Female" }},
+		{"My pronouns are they/them.", func(a models.Attributes) bool { return a.Gender == "Non-binary" }},
+		{"I am a student and my dad is a pilot.", func(a models.Attributes) bool { return a.Gender == "" }},
+		{"Living in Austin, TX.", func(a models.Attributes) bool {
+			return a.Location == models.Location{City: "Austin", State: "Texas", Country: "USA"}
+		}},
+		{"I moved to Washington DC last year.", func(a models.Attributes) bool {
+			return a.Location.City == "Washington" && a.Location.State == "District of Columbia"
+		}},
+		{"Based in Seattle, Washington.", func(a models.Attributes) bool {
+			return a.Location.City == "Seattle" && a.Location.State == "Washington"
+		}},
+		{"I'm from India.", func(a models.Attributes) bool { return a.Location.Country == "India" }},
+		{"PhD student in physics.", func(a models.Attributes) bool { return a.EducationLevel == "Doctorate" }},
+		{"I have a bachelor's in economics.", func(a models.Attributes) bool { return a.EducationLevel == "Bachelor's" }},
+		{"I enjoy hiking, cooking and chess.", func(a models.Attributes) bool {
+			return reflect.DeepEqual(a.Interests, []string{"Hiking", "Cooking", "Chess"})
+		}},
+		{"I would like short answers.", func(a models.Attributes) bool { return len(a.Interests) == 0 }},
+		{"Please keep answers casual and friendly.", func(a models.Attributes) bool { return a.TonePreference == "Casual" }},
+		{"I prefer a formal tone.", func(a models.Attributes) bool { return a.TonePreference == "Formal" }},
+		{"My neighbor is friendly.", func(a models.Attributes) bool { return a.TonePreference == "" }},
+	}
+
+	for _, tt := range tests {
+		result := Parse(tt.text)
+		if !tt.check(result.Attributes) {
+			t.Errorf("Parse(%q) gave unexpected attributes %+v", tt.text, result.Attributes)
+		}
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	result := Parse("")
+	if !reflect.DeepEqual(result.Attributes, models.Attributes{}) || len(result.Confidence) != 0 {
+		t.Errorf("Expected nothing from empty text, got %+v", result)
+	}
+}
+
+func TestParse_Deterministic(t *testing.T) {
+	text := "I'm a 30 year old nurse from Chicago who loves baking and jazz. I prefer concise answers."
+	first := Parse(text)
+	for i := 0; i < 10; i++ {
+		if next := Parse(text); !reflect.DeepEqual(first, next) {
+			t.Fatalf("Expected identical results, got %+v and %+v", first, next)
+		}
+	}
+}
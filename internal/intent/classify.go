@@ -0,0 +1,216 @@
+package intent
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// Match is how well an intent fits a piece of text. Score is between 0 and
+// 1 and blends KeywordScore, from the intent's keywords found in the text,
+// with SimilarityScore, the TF-IDF cosine similarity between the text and
+// the intent's name, description and keywords.
+type Match struct {
+	Intent          string   `json:"intent"`
+	Name            string   `json:"name"`
+	Tag             string   `json:"tag"`
+	SystemPrompt    string   `json:"system_prompt"`
+	Score           float64  `json:"score"`
+	KeywordScore    float64  `json:"keyword_score"`
+	SimilarityScore float64  `json:"similarity_score"`
+	MatchedKeywords []string `json:"matched_keywords,omitempty"`
+}
+
+// Weights of the two scores in Match.Score
+const (
+	keywordWeight    = 0.6
+	similarityWeight = 0.4
+)
+
+// Classify ranks intents by how well they fit text, best first. Intents
+// that don't match at all are left out.
+func Classify(text string, intents []*models.Intent) []Match {
+	all := tokenizeAll(text)
+	words := withoutStopWords(all)
+	if len(words) == 0 {
+		return []Match{}
+	}
+
+	documents := make([][]string, len(intents))
+	for i, intent := range intents {
+		documents[i] = tokenize(intent.Name + " " + intent.Description + " " + strings.Join(intent.Keywords, " "))
+	}
+	idf := inverseDocumentFrequencies(documents)
+	query := weigh(words, idf)
+
+	matches := []Match{}
+	for i, intent := range intents {
+		matched := matchKeywords(all, intent.Keywords)
+		// Each keyword found closes half the remaining distance to 1
+		keywordScore := 1 - math.Pow(0.5, float64(len(matched)))
+		similarity := cosine(query, weigh(documents[i], idf))
+
+		score := keywordWeight*keywordScore + similarityWeight*similarity
+		if score <= 0 {
+			continue
+		}
+		matches = append(matches, Match{
+			Intent:          intent.Intent,
+			Name:            intent.Name,
+			Tag:             intent.Tag,
+			SystemPrompt:    intent.SystemPrompt,
+			Score:           round3(score),
+			KeywordScore:    round3(keywordScore),
+			SimilarityScore: round3(similarity),
+			MatchedKeywords: matched,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches
+}
+
+func round3(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+// matchKeywords returns the keywords, possibly several words long, that
+// appear in words.
+func matchKeywords(words []string, keywords []string) []string {
+	var matched []string
+	for _, keyword := range keywords {
+		phrase := tokenizeAll(keyword)
+		if len(phrase) == 0 {
+			continue
+		}
+		for i := 0; i+len(phrase) <= len(words); i++ {
+			if equalWords(words[i:i+len(phrase)], phrase) {
+				matched = append(matched, keyword)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func equalWords(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// inverseDocumentFrequencies uses smoothed IDF so terms found in every
+// document still count a little.
+func inverseDocumentFrequencies(documents [][]string) map[string]float64 {
+	df := make(map[string]int)
+	for _, document := range documents {
+		seen := make(map[string]bool)
+		for _, word := range document {
+			if !seen[word] {
+				seen[word] = true
+				df[word]++
+			}
+		}
+	}
+
+	n := float64(len(documents))
+	idf := make(map[string]float64, len(df))
+	for word, count := range df {
+		idf[word] = math.Log((1+n)/(1+float64(count))) + 1
+	}
+	return idf
+}
+
+// weigh builds a TF-IDF vector. Words no intent uses carry no weight.
+func weigh(words []string, idf map[string]float64) map[string]float64 {
+	vector := make(map[string]float64)
+	for _, word := range words {
+		if weight, ok := idf[word]; ok && !stopWords[word] {
+			vector[word] += weight
+		}
+	}
+	return vector
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for word, weight := range a {
+		dot += weight * b[word]
+		normA += weight * weight
+	}
+	for _, weight := range b {
+		normB += weight * weight
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// tokenize splits text into stemmed, lowercase words without stop words.
+func tokenize(text string) []string {
+	return withoutStopWords(tokenizeAll(text))
+}
+
+func withoutStopWords(words []string) []string {
+	var kept []string
+	for _, word := range words {
+		if !stopWords[word] {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
+
+// tokenizeAll splits text into stemmed, lowercase words, keeping stop words
+// so multi-word keywords like "how to" can match.
+func tokenizeAll(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.Trim(field, "'")
+		field = strings.TrimSuffix(field, "'s")
+		if field != "" {
+			words = append(words, stem(field))
+		}
+	}
+	return words
+}
+
+// stem strips common English suffixes so "exams" matches "exam" and
+// "debugging" matches "debug". It only needs to be consistent, not correct.
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 5 && strings.HasSuffix(word, "ing"):
+		word = word[:len(word)-3]
+	case len(word) > 4 && strings.HasSuffix(word, "ed"):
+		word = word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	default:
+		return word
+	}
+	// "debugging" -> "debugg" -> "debug"
+	if n := len(word); n > 2 && word[n-1] == word[n-2] && !strings.ContainsRune("aeiouls", rune(word[n-1])) {
+		word = word[:n-1]
+	}
+	return word
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "but": true, "of": true, "to": true,
+	"in": true, "on": true, "at": true, "for": true, "with": true, "by": true, "from": true, "about": true,
+	"is": true, "are": true, "was": true, "be": true, "it": true, "this": true, "that": true, "my": true,
+	"me": true, "i": true, "you": true, "your": true, "we": true, "our": true, "can": true, "do": true,
+	"not": true, "please": true, "help": true, "want": true, "need": true, "some": true, "into": true,
+}
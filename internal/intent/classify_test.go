@@ -0,0 +1,88 @@
+package intent
+
+import (
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func shippedIntents(t *testing.T) []*models.Intent {
+	intents, _, err := NewMaster("../intent_master.json").Load()
+	if err != nil {
+		t.Fatalf("Failed to load the shipped intents file: %v", err)
+	}
+	list := make([]*models.Intent, len(intents))
+	for i := range intents {
+		list[i] = &intents[i]
+	}
+	return list
+}
+
+func TestClassify(t *testing.T) {
+	intents := shippedIntents(t)
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"Help me study for my chemistry exams and review the homework", "study"},
+		{"Compare the iPhone versus the Pixel, which is better?", "compare"},
+		{"My laptop shows an error when booting, how can I fix this issue?", "troubleshoot"},
+		{"Write a short poem and a story about the sea", "creative"},
+		{"How to bake bread, step by step instructions please", "how_to"},
+		{"Explain like I'm five what a black hole is", "explain_simple"},
+		{"What are my rights if a landlord breaks the contract? Should I get a lawyer?", "legal_info"},
+	}
+	for _, tt := range tests {
+		matches := Classify(tt.text, intents)
+		if len(matches) == 0 {
+			t.Errorf("Classify(%q): expected matches, got none", tt.text)
+			continue
+		}
+		if matches[0].Intent != tt.expected {
+			t.Errorf("Classify(%q): expected %s first, got %s (%+v)", tt.text, tt.expected, matches[0].Intent, matches[:2])
+		}
+	}
+}
+
+func TestClassify_Ranking(t *testing.T) {
+	intents := shippedIntents(t)
+	matches := Classify("debugging a broken build error", intents)
+
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Score > matches[i-1].Score {
+			t.Fatalf("Expected matches sorted by score, got %+v", matches)
+		}
+	}
+	top := matches[0]
+	if top.Intent != "troubleshoot" || top.SystemPrompt == "" {
+		t.Fatalf("Expected troubleshoot with its system prompt, got %+v", top)
+	}
+	if len(top.MatchedKeywords) != 3 {
+		t.Errorf("Expected debug, broken and error to match, got %v", top.MatchedKeywords)
+	}
+	if top.Score <= 0 || top.Score > 1 {
+		t.Errorf("Expected a score in (0, 1], got %v", top.Score)
+	}
+}
+
+func TestClassify_NoMatch(t *testing.T) {
+	intents := shippedIntents(t)
+	for _, text := range []string{"", "   ", "the and of", "zxqv"} {
+		if matches := Classify(text, intents); len(matches) != 0 {
+			t.Errorf("Classify(%q): expected no matches, got %+v", text, matches)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"exams": "exam", "debugging": "debug", "studies": "study", "fixed": "fix",
+		"class": "class", "planning": "plan", "explain": "explain",
+	}
+	for word, expected := range tests {
+		if got := stem(word); got != expected {
+			t.Errorf("stem(%q): expected %q, got %q", word, expected, got)
+		}
+	}
+}
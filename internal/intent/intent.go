@@ -0,0 +1,115 @@
+// Package intent loads the intent master file and validates intents.
+package intent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// Master is the intent master file. It is parsed on first use and re-read
+// only when the file changes.
+type Master struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	intents []models.Intent
+	version string
+}
+
+// NewMaster returns the master file at path. The file isn't read until it
+// is needed.
+func NewMaster(path string) *Master {
+	return &Master{path: path}
+}
+
+// Load returns the intents in the master file and a version that changes
+// whenever the file's contents do. The returned slice is shared and must not
+// be modified.
+func (m *Master) Load() ([]models.Intent, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := os.Stat(m.path)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read intents file: %w", err)
+	}
+	if m.intents != nil && info.ModTime().Equal(m.modTime) && info.Size() == m.size {
+		return m.intents, m.version, nil
+	}
+
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read intents file: %w", err)
+	}
+	var intents []models.Intent
+	if err := json.Unmarshal(data, &intents); err != nil {
+		return nil, "", fmt.Errorf("could not parse intents file: %w", err)
+	}
+	for i := range intents {
+		if err := Validate(&intents[i]); err != nil {
+			return nil, "", fmt.Errorf("invalid intent in intents file: %w", err)
+		}
+		intents[i].Custom = false
+		intents[i].Overridden = false
+	}
+
+	sum := sha256.Sum256(data)
+	m.intents = intents
+	m.version = hex.EncodeToString(sum[:])
+	m.modTime = info.ModTime()
+	m.size = info.Size()
+	return m.intents, m.version, nil
+}
+
+// Find returns the intent with the given name from the master file, or nil
+// if there is none.
+func (m *Master) Find(name string) (*models.Intent, error) {
+	intents, _, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range intents {
+		if intents[i].Intent == name {
+			intent := intents[i]
+			return &intent, nil
+		}
+	}
+	return nil, nil
+}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Validate checks that an intent has a usable name and display name, and
+// tidies its keywords.
+func Validate(intent *models.Intent) error {
+	if !namePattern.MatchString(intent.Intent) {
+		return fmt.Errorf("intent %q must be lowercase letters, digits and underscores, starting with a letter", intent.Intent)
+	}
+	if strings.TrimSpace(intent.Name) == "" {
+		return fmt.Errorf("intent %q has no name", intent.Intent)
+	}
+
+	keywords := intent.Keywords[:0]
+	seen := make(map[string]bool)
+	for _, keyword := range intent.Keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" || seen[keyword] {
+			continue
+		}
+		seen[keyword] = true
+		keywords = append(keywords, keyword)
+	}
+	intent.Keywords = keywords
+	return nil
+}
@@ -0,0 +1,109 @@
+package intent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestMaster_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intent_master.json")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write intents file: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write(`[{"intent": "study", "name": "Study", "keywords": ["Exam", "exam", " homework "]}]`, start)
+
+	master := NewMaster(path)
+	intents, version, err := master.Load()
+	if err != nil {
+		t.Fatalf("Failed to load intents: %v", err)
+	}
+	if len(intents) != 1 || intents[0].Intent != "study" {
+		t.Fatalf("Expected the study intent, got %+v", intents)
+	}
+	if len(intents[0].Keywords) != 2 || intents[0].Keywords[0] != "exam" || intents[0].Keywords[1] != "homework" {
+		t.Errorf("Expected tidied keywords, got %v", intents[0].Keywords)
+	}
+
+	// An unchanged file comes from the cache
+	again, sameVersion, err := master.Load()
+	if err != nil {
+		t.Fatalf("Failed to load intents: %v", err)
+	}
+	if &again[0] != &intents[0] || sameVersion != version {
+		t.Error("Expected cached intents for an unchanged file")
+	}
+
+	write(`[{"intent": "study", "name": "Study"}, {"intent": "research", "name": "Research"}]`, start.Add(time.Minute))
+	intents, newVersion, err := master.Load()
+	if err != nil {
+		t.Fatalf("Failed to reload intents: %v", err)
+	}
+	if len(intents) != 2 {
+		t.Errorf("Expected 2 intents after the file changed, got %d", len(intents))
+	}
+	if newVersion == version {
+		t.Error("Expected a new version after the file changed")
+	}
+
+	found, err := master.Find("research")
+	if err != nil || found == nil || found.Name != "Research" {
+		t.Errorf("Expected to find research, got %+v, %v", found, err)
+	}
+	if found, _ := master.Find("missing"); found != nil {
+		t.Errorf("Expected no intent, got %+v", found)
+	}
+}
+
+func TestMaster_LoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, _, err := NewMaster(filepath.Join(dir, "missing.json")).Load(); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`[{"intent": "Not Valid", "name": "x"}]`), 0644)
+	if _, _, err := NewMaster(bad).Load(); err == nil {
+		t.Error("Expected an error for an invalid intent")
+	}
+}
+
+func TestMaster_LoadShippedFile(t *testing.T) {
+	intents, _, err := NewMaster("../intent_master.json").Load()
+	if err != nil {
+		t.Fatalf("Failed to load the shipped intents file: %v", err)
+	}
+	if len(intents) == 0 {
+		t.Error("Expected intents in the shipped file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		intent models.Intent
+		valid  bool
+	}{
+		{models.Intent{Intent: "study", Name: "Study"}, true},
+		{models.Intent{Intent: "deep_dive2", Name: "Deep Dive"}, true},
+		{models.Intent{Intent: "", Name: "Empty"}, false},
+		{models.Intent{Intent: "Study", Name: "Study"}, false},
+		{models.Intent{Intent: "2fast", Name: "Fast"}, false},
+		{models.Intent{Intent: "study", Name: "  "}, false},
+	}
+	for _, tt := range tests {
+		err := Validate(&tt.intent)
+		if (err == nil) != tt.valid {
+			t.Errorf("Validate(%q, %q): expected valid=%v, got %v", tt.intent.Intent, tt.intent.Name, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned for an item that doesn't exist or is in the
+// trash.
+var ErrNotFound = errors.New("not found")
+
+// TemplateVersionRef identifies one version of a template.
+type TemplateVersionRef struct {
+	ID      uuid.UUID `json:"id"`
+	Version int       `json:"version"`
+}
+
+// Dependents lists what deleting a persona or template version takes along
+// with it: a persona's templates, with every version, and the prompts
+// generated from them.
+type Dependents struct {
+	TemplateCount int                  `json:"template_count"`
+	PromptCount   int                  `json:"prompt_count"`
+	Templates     []TemplateVersionRef `json:"templates"`
+	PromptIDs     []uuid.UUID          `json:"prompt_ids"`
+}
+
+// Empty reports whether there are no dependents.
+func (d *Dependents) Empty() bool {
+	return d.TemplateCount == 0 && d.PromptCount == 0
+}
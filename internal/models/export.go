@@ -0,0 +1,18 @@
+package models
+
+// Kinds of item in a library
+const (
+	LibraryProfile  = "profile"
+	LibraryPersona  = "persona"
+	LibraryTemplate = "template"
+	LibraryPrompt   = "prompt"
+)
+
+// Library holds a user's profiles, personas, every version of their
+// templates and their prompts, as exported and imported in bundles.
+type Library struct {
+	Profiles  []*Profile        `json:"profiles"`
+	Personas  []*Persona        `json:"personas"`
+	Templates []*PromptTemplate `json:"templates"`
+	Prompts   []*Prompt         `json:"prompts"`
+}
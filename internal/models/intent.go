@@ -1,6 +1,8 @@
 package models
 
-// Intent represents a single intent from the intent master file.
+// Intent represents what a user wants from an answer. Built-in intents are
+// seeded from the intent master file; users can override their
+// SystemPrompt and Keywords or add custom intents of their own.
 type Intent struct {
 	Intent       string   `json:"intent"`
 	Name         string   `json:"name"`
@@ -8,4 +10,8 @@ type Intent struct {
 	SystemPrompt string   `json:"system_prompt"`
 	Keywords     []string `json:"keywords"`
 	Tag          string   `json:"tag"`
+	// Custom is true for intents the user added.
+	Custom bool `json:"custom"`
+	// Overridden is true for built-in intents the user has changed.
+	Overridden bool `json:"overridden"`
 }
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tag labels prompts and templates. A prompt or template can have any
+// number of tags, and tag names are unique within a profile, ignoring case.
+type Tag struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	ProfileID string    `json:"profile_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Folder holds prompts and templates. Folders nest; a nil ParentID is a
+// top-level folder.
+type Folder struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	ProfileID string     `json:"profile_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ErrTagExists is returned when a tag name is already used in its profile.
+var ErrTagExists = errors.New("tag already exists")
+
+// ErrFolderCycle is returned when a folder would be moved into itself or
+// one of its subfolders.
+var ErrFolderCycle = errors.New("folder cannot be moved into itself or its subfolders")
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fields lists can be sorted by. Not every list supports every field.
+const (
+	SortName      = "name"
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortVersion   = "version"
+	// Usage sorts, for prompts and templates
+	SortMostUsed = "most_used" // Renders and copies together
+	SortRecent   = "recent"    // Last used
+	SortStarred  = "starred"   // When starred; unstarred items sort as never
+)
+
+// ListOptions filters, sorts and pages a list. The zero value lists
+// everything oldest first, as lists always have.
+type ListOptions struct {
+	// ProfileID scopes the list the same way GetAll and friends do.
+	ProfileID string
+	// TemplateID only applies to prompts and PersonaID only to templates.
+	TemplateID uuid.UUID
+	PersonaID  uuid.UUID
+	// TagID and FolderID only apply to prompts and templates. FolderID
+	// matches items directly in the folder, not in its subfolders.
+	TagID    uuid.UUID
+	FolderID uuid.UUID
+	// CreatedAfter and CreatedBefore bound the creation time, inclusive of
+	// CreatedAfter. Zero times are unbounded.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	Sort string // Defaults to SortCreatedAt
+	Desc bool
+
+	// Cursor continues a list from where the previous page ended.
+	Cursor string
+	// Limit caps the page size. Zero means no limit.
+	Limit int
+}
+
+// PageInfo describes a page of a list.
+type PageInfo struct {
+	// Total counts every item matching the filters, across all pages.
+	Total int
+	// NextCursor fetches the following page; it is empty on the last page.
+	NextCursor string
+}
+
+// ErrInvalidCursor is returned for cursors not produced by a list with the
+// same sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of the last item of a page: its sort key and the
+// ID, and version for templates, that break ties between equal keys.
+type Cursor struct {
+	Sort    string `json:"s"`
+	Desc    bool   `json:"d,omitempty"`
+	Key     string `json:"k"`
+	ID      string `json:"i"`
+	Version int    `json:"v,omitempty"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor for a list sorted as opts is.
+func DecodeCursor(s string, opts ListOptions) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != opts.SortField() || c.Desc != opts.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// SortField returns the field the list is sorted by.
+func (o ListOptions) SortField() string {
+	if o.Sort == "" {
+		return SortCreatedAt
+	}
+	return o.Sort
+}
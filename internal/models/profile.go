@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type Profile struct {
 	ID          string      `json:"id"`
@@ -30,3 +33,7 @@ type Location struct {
 	Country    string `json:"country,omitempty"`
 	PostalCode string `json:"postal_code,omitempty"`
 }
+
+// ErrProfileInUse is returned when deleting a profile that still has
+// personas, templates or prompts, in the library or the trash.
+var ErrProfileInUse = errors.New("profile still has personas, templates or prompts; delete and purge them first")
@@ -1,26 +1,74 @@
-
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Persona struct {
 	ID              uuid.UUID `json:"persona_id"`
 	UserRoleDisplay string    `json:"user_role_display"`
 	LLMRoleDisplay  string    `json:"llm_role_display"`
 	ProfileID       string    `json:"profile_id,omitempty"`
+	// MetaRoleTemplate generates the meta role of this persona's templates.
+	// Empty means the built-in default.
+	MetaRoleTemplate string    `json:"meta_role_template,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 type PromptTemplate struct {
-	ID              uuid.UUID `json:"id"`
-	Name            string    `json:"name"`
-	PersonaID       uuid.UUID `json:"persona_id"`
-	Version         int       `json:"version"`
-	MetaRole        string    `json:"meta_role"`
-	Task            string    `json:"task"`
-	AnswerGuideline string    `json:"answer_guideline"`
-	Template        string    `json:"template"`
-	Variables       []string  `json:"variables"`
-	ProfileID       string    `json:"profile_id,omitempty"`
+	ID              uuid.UUID      `json:"id"`
+	Name            string         `json:"name"`
+	PersonaID       uuid.UUID      `json:"persona_id"`
+	Version         int            `json:"version"`
+	MetaRole        string         `json:"meta_role"`
+	Task            string         `json:"task"`
+	AnswerGuideline string         `json:"answer_guideline"`
+	Template        string         `json:"template"`
+	Variables       []string       `json:"variables"`
+	VariableSchema  []VariableSpec `json:"variable_schema,omitempty"`
+	ProfileID       string         `json:"profile_id,omitempty"`
+	// ManualMetaRole keeps MetaRole exactly as supplied instead of
+	// generating it from the persona.
+	ManualMetaRole bool `json:"manual_meta_role"`
+	// Intent names the intent whose system prompt is added to prompts
+	// generated from this template.
+	Intent string `json:"intent,omitempty"`
+	// FolderID is the folder holding every version of the template, nil
+	// when it isn't in one.
+	FolderID *uuid.UUID `json:"folder_id,omitempty"`
+	// Usage is only filled in by lists.
+	Usage     *Usage    `json:"usage,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VariableType is the kind of value a template variable accepts.
+type VariableType string
+
+const (
+	VariableTypeString    VariableType = "string"
+	VariableTypeNumber    VariableType = "number"
+	VariableTypeEnum      VariableType = "enum"
+	VariableTypeMultiline VariableType = "multiline"
+	VariableTypeBoolean   VariableType = "boolean"
+	VariableTypeDate      VariableType = "date"
+)
+
+// VariableSpec describes how a single template variable is filled in.
+// Variables without a spec are treated as required strings.
+type VariableSpec struct {
+	Name        string       `json:"name"`
+	Type        VariableType `json:"type,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Default     string       `json:"default,omitempty"`
+	Required    bool         `json:"required"`
+	Options     []string     `json:"options,omitempty"` // Allowed values for enum variables
+	Pattern     string       `json:"pattern,omitempty"` // Regular expression the value must match
+	MinLength   int          `json:"min_length,omitempty"`
+	MaxLength   int          `json:"max_length,omitempty"`
 }
 
 type Prompt struct {
@@ -31,4 +79,12 @@ type Prompt struct {
 	Values          map[string]string `json:"variable_values"`
 	Content         string            `json:"content"`
 	ProfileID       string            `json:"profile_id,omitempty"`
+	// Intent overrides the template's intent for this prompt.
+	Intent string `json:"intent,omitempty"`
+	// FolderID is the folder holding the prompt, nil when it isn't in one.
+	FolderID *uuid.UUID `json:"folder_id,omitempty"`
+	// Usage is only filled in by lists.
+	Usage     *Usage    `json:"usage,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,21 @@
+package models
+
+// Kinds of item a search can find
+const (
+	SearchTypePrompt   = "prompt"
+	SearchTypeTemplate = "template"
+	SearchTypePersona  = "persona"
+)
+
+// SearchResult is an item matching a full-text search. Name and Snippet
+// are HTML-escaped, with the matched terms wrapped in <mark> and </mark>.
+type SearchResult struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Version int    `json:"version,omitempty"`
+	Name    string `json:"name"`
+	Snippet string `json:"snippet"`
+	// Score is higher for better matches. It is only meaningful for
+	// comparing results of the same search.
+	Score float64 `json:"score"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of item that can be in the trash
+const (
+	TrashPersona  = "persona"
+	TrashTemplate = "template"
+	TrashPrompt   = "prompt"
+)
+
+// TrashItem is a deleted persona, template version or prompt. Deleting an
+// item also deletes what depends on it: a persona's templates and a
+// template version's prompts. They stay with the item in the trash and are
+// restored or purged with it.
+type TrashItem struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	ItemID    uuid.UUID `json:"item_id"`
+	Version   int       `json:"version,omitempty"` // Template version, for templates
+	Name      string    `json:"name"`
+	ProfileID string    `json:"profile_id,omitempty"`
+	// Cascaded counts the items deleted along with this one.
+	Cascaded  int       `json:"cascaded"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ErrRestoreConflict is returned when restoring an item whose persona or
+// template is still in the trash.
+var ErrRestoreConflict = errors.New("restore its persona or template first")
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Kinds of item whose use is recorded
+const (
+	UsagePrompt   = "prompt"
+	UsageTemplate = "template"
+)
+
+// Ways an item is used
+const (
+	UsageRender = "render"
+	UsageCopy   = "copy"
+)
+
+// Usage is how much a prompt or template has been used, and whether the
+// user starred it. A template's usage covers all its versions.
+type Usage struct {
+	RenderCount int        `json:"render_count"`
+	CopyCount   int        `json:"copy_count"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	Starred     bool       `json:"starred"`
+	StarredAt   *time.Time `json:"starred_at,omitempty"`
+}
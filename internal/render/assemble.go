@@ -0,0 +1,176 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// Section names, as used in section orders
+const (
+	SectionMetaRole        = "meta_role"
+	SectionIntent          = "intent"
+	SectionProfile         = "profile"
+	SectionTask            = "task"
+	SectionAnswerGuideline = "answer_guideline"
+)
+
+// SectionHeaders introduce each section in assembled prompts.
+var SectionHeaders = map[string]string{
+	SectionMetaRole:        "[Meta Role]",
+	SectionIntent:          "[Intent]",
+	SectionProfile:         "[User Profile]",
+	SectionTask:            "[Task]",
+	SectionAnswerGuideline: "[Answer Guideline]",
+}
+
+// DefaultSectionOrder puts who the model is and what the user wants ahead of
+// the task itself.
+var DefaultSectionOrder = []string{
+	SectionMetaRole,
+	SectionIntent,
+	SectionProfile,
+	SectionTask,
+	SectionAnswerGuideline,
+}
+
+// Sections is prompt text keyed by section name.
+type Sections map[string]string
+
+// ParseSectionOrder parses a comma-separated section order. Every section
+// must appear exactly once so assembly never silently drops one.
+func ParseSectionOrder(s string) ([]string, error) {
+	seen := make(map[string]bool)
+	var order []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := SectionHeaders[name]; !ok {
+			return nil, fmt.Errorf("unknown section %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("section %q appears more than once", name)
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+	for _, name := range DefaultSectionOrder {
+		if !seen[name] {
+			return nil, fmt.Errorf("section %q is missing", name)
+		}
+	}
+	return order, nil
+}
+
+// Assemble joins the non-empty sections under their headers in the given
+// order, or DefaultSectionOrder when order is empty.
+func Assemble(sections Sections, order []string) string {
+	if len(order) == 0 {
+		order = DefaultSectionOrder
+	}
+	var parts []string
+	for _, name := range order {
+		if text := sections[name]; text != "" {
+			parts = append(parts, SectionHeaders[name]+"\n"+text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// TemplateSections returns the sections a template is written in.
+func TemplateSections(template *models.PromptTemplate) Sections {
+	return Sections{
+		SectionMetaRole:        template.MetaRole,
+		SectionTask:            template.Task,
+		SectionAnswerGuideline: template.AnswerGuideline,
+	}
+}
+
+// hasSections reports whether a template is written in sections rather
+// than only as its assembled text.
+func hasSections(template *models.PromptTemplate) bool {
+	return template.MetaRole != "" || template.Task != "" || template.AnswerGuideline != ""
+}
+
+// TemplateSource returns the part of a template written in the template
+// language: its task, answer guideline and a hand-written meta role, or its
+// text when it has no sections. A generated meta role is left out. It is
+// plain text built from persona and profile fields, whose braces aren't
+// template syntax.
+func TemplateSource(template *models.PromptTemplate) string {
+	if !hasSections(template) {
+		return template.Template
+	}
+	text := template.Task + "\n" + template.AnswerGuideline
+	if template.ManualMetaRole {
+		text += "\n" + template.MetaRole
+	}
+	return text
+}
+
+// RenderSections renders each section of a stored template. Values are
+// validated against the template's variable schema, with defaults filled in
+// first, and it fails with a *ValidationError for missing, unknown or
+// invalid ones. Variables that are only tested by {{#if}} are optional
+// unless the schema says otherwise.
+func RenderSections(template *models.PromptTemplate, values map[string]string) (Sections, error) {
+	t, err := Parse(TemplateSource(template))
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := ApplySchema(templateSchema(t, template.VariableSchema), values)
+	if err != nil {
+		return nil, err
+	}
+
+	return executeSections(template, t, resolved)
+}
+
+// RerenderSections renders each section of a stored template with values
+// that were saved against another version of it. Values the template no
+// longer references are left out and returned as unused rather than
+// rejected; variables that now need a value are still reported through a
+// *ValidationError.
+func RerenderSections(template *models.PromptTemplate, values map[string]string) (Sections, []string, error) {
+	t, err := Parse(TemplateSource(template))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolved, unused, err := rerenderValues(t, template, values)
+	if err != nil {
+		return nil, unused, err
+	}
+
+	sections, err := executeSections(template, t, resolved)
+	return sections, unused, err
+}
+
+// executeSections renders a template's sections with resolved values. A
+// template with no sections of its own, which only has its assembled text,
+// is rendered whole as the task. A generated meta role is used as it is.
+func executeSections(template *models.PromptTemplate, t *Template, resolved map[string]string) (Sections, error) {
+	sections := TemplateSections(template)
+	if !hasSections(template) {
+		content, err := t.Execute(resolved)
+		if err != nil {
+			return nil, err
+		}
+		return Sections{SectionTask: content}, nil
+	}
+
+	for name, text := range sections {
+		if text == "" || (name == SectionMetaRole && !template.ManualMetaRole) {
+			continue
+		}
+		st, err := Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		if sections[name], err = st.Execute(resolved); err != nil {
+			return nil, err
+		}
+	}
+	return sections, nil
+}
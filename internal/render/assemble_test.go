@@ -0,0 +1,146 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestParseSectionOrder(t *testing.T) {
+	order, err := ParseSectionOrder(" task, meta_role,profile ,intent,answer_guideline")
+	if err != nil {
+		t.Fatalf("Failed to parse order: %v", err)
+	}
+	if len(order) != 5 || order[0] != SectionTask || order[2] != SectionProfile {
+		t.Errorf("Unexpected order %v", order)
+	}
+
+	for _, bad := range []string{
+		"",
+		"meta_role,intent,profile,task",
+		"meta_role,intent,profile,task,answer_guideline,task",
+		"meta_role,intent,profile,task,guideline",
+	} {
+		if _, err := ParseSectionOrder(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	sections := Sections{
+		SectionMetaRole: "You are a teacher.",
+		SectionIntent:   "Be patient.",
+		SectionTask:     "Explain recursion.",
+	}
+
+	expected := "[Meta Role]\nYou are a teacher.\n\n[Intent]\nBe patient.\n\n[Task]\nExplain recursion."
+	if out := Assemble(sections, nil); out != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	order := []string{SectionTask, SectionIntent, SectionMetaRole, SectionProfile, SectionAnswerGuideline}
+	expected = "[Task]\nExplain recursion.\n\n[Intent]\nBe patient.\n\n[Meta Role]\nYou are a teacher."
+	if out := Assemble(sections, order); out != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestRenderSections(t *testing.T) {
+	template := &models.PromptTemplate{
+		MetaRole:        "You are a teacher.",
+		Task:            "Explain {{topic}}.",
+		AnswerGuideline: "{{#if formal}}Be formal.{{/if}}",
+	}
+	template.Template = Assemble(TemplateSections(template), nil)
+
+	sections, err := RenderSections(template, map[string]string{"topic": "recursion"})
+	if err != nil {
+		t.Fatalf("Failed to render sections: %v", err)
+	}
+	if sections[SectionTask] != "Explain recursion." || sections[SectionAnswerGuideline] != "" {
+		t.Errorf("Unexpected sections %v", sections)
+	}
+
+	if _, err := RenderSections(template, map[string]string{}); err == nil {
+		t.Error("Expected an error for a missing variable")
+	}
+
+	sections, unused, err := RerenderSections(template, map[string]string{"topic": "loops", "old": "x"})
+	if err != nil {
+		t.Fatalf("Failed to rerender sections: %v", err)
+	}
+	if sections[SectionTask] != "Explain loops." || len(unused) != 1 || unused[0] != "old" {
+		t.Errorf("Unexpected rerender result %v, %v", sections, unused)
+	}
+}
+
+func TestRenderSections_WholeTemplate(t *testing.T) {
+	template := &models.PromptTemplate{Template: "Hello {{name}}"}
+
+	sections, err := RenderSections(template, map[string]string{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("Failed to render sections: %v", err)
+	}
+	if len(sections) != 1 || sections[SectionTask] != "Hello Ada" {
+		t.Errorf("Expected the whole template as the task, got %v", sections)
+	}
+}
+
+func TestRenderSections_GeneratedMetaRole(t *testing.T) {
+	persona := &models.Persona{UserRoleDisplay: "{{#if x}}", LLMRoleDisplay: "{{y}} expert"}
+	metaRole, err := BuildMetaRole(MetaRoleContext{Persona: persona})
+	if err != nil {
+		t.Fatalf("Failed to build meta role: %v", err)
+	}
+	template := &models.PromptTemplate{MetaRole: metaRole, Task: "Explain {{topic}}."}
+	template.Template = Assemble(TemplateSections(template), nil)
+
+	// Braces in a generated meta role are text, not variables or blocks
+	if vars := ExtractVariables(TemplateSource(template)); len(vars) != 1 || vars[0] != "topic" {
+		t.Errorf("Expected only topic as a variable, got %v", vars)
+	}
+	sections, err := RenderSections(template, map[string]string{"topic": "recursion"})
+	if err != nil {
+		t.Fatalf("Failed to render sections: %v", err)
+	}
+	if sections[SectionMetaRole] != metaRole || !strings.Contains(metaRole, "You are a {{y}} expert.") {
+		t.Errorf("Expected the meta role rendered literally, got %q", sections[SectionMetaRole])
+	}
+
+	// A hand-written meta role is a template like the other sections
+	template.MetaRole = "You are a {{role}}."
+	template.ManualMetaRole = true
+	sections, err = RenderSections(template, map[string]string{"topic": "recursion", "role": "tutor"})
+	if err != nil {
+		t.Fatalf("Failed to render sections: %v", err)
+	}
+	if sections[SectionMetaRole] != "You are a tutor." {
+		t.Errorf("Unexpected meta role %q", sections[SectionMetaRole])
+	}
+}
+
+func TestRenderSections_ListVariables(t *testing.T) {
+	template := &models.PromptTemplate{Task: "{{#each steps}}- {{this}}\n{{/each}}"}
+	values := map[string]string{"steps": "plan\nbuild"}
+
+	// A list takes one item per line without being declared multiline
+	sections, err := RenderSections(template, values)
+	if err != nil {
+		t.Fatalf("Failed to render sections: %v", err)
+	}
+	if sections[SectionTask] != "- plan\n- build\n" {
+		t.Errorf("Unexpected task %q", sections[SectionTask])
+	}
+
+	// unless it is declared a single-line string
+	template.VariableSchema = []models.VariableSpec{{Name: "steps", Type: models.VariableTypeString}}
+	if _, err := RenderSections(template, values); err == nil {
+		t.Error("Expected an error for a multi-line value of a string variable")
+	}
+	values["steps"] = `["plan", "build"]`
+	if _, err := RenderSections(template, values); err != nil {
+		t.Errorf("Expected a JSON list to be allowed, got %v", err)
+	}
+}
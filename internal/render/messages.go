@@ -0,0 +1,160 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format is how a prompt is output.
+type Format string
+
+// Output formats. FormatText is the assembled prompt as a single string; the
+// others split it into chat messages shaped for each provider's API.
+const (
+	FormatText      Format = "text"
+	FormatOpenAI    Format = "openai"
+	FormatAnthropic Format = "anthropic"
+	FormatGemini    Format = "gemini"
+)
+
+// ParseFormat parses a format name. An empty name means FormatText.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatOpenAI, FormatAnthropic, FormatGemini:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q: must be text, openai, anthropic or gemini", s)
+	}
+}
+
+// systemSections go into the system message; the rest make up the user's.
+var systemSections = map[string]bool{
+	SectionMetaRole: true,
+	SectionIntent:   true,
+	SectionProfile:  true,
+}
+
+// OpenAIMessage is a chat completions message.
+type OpenAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OpenAIMessages is the message list of an OpenAI chat completions request.
+type OpenAIMessages struct {
+	Messages []OpenAIMessage `json:"messages"`
+}
+
+// AnthropicMessage is a Messages API message.
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// AnthropicMessages is the system prompt and messages of an Anthropic
+// Messages API request, which takes the system prompt separately.
+type AnthropicMessages struct {
+	System   string             `json:"system,omitempty"`
+	Messages []AnthropicMessage `json:"messages"`
+}
+
+// GeminiPart is a piece of Gemini content.
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+// GeminiContent is a turn of a Gemini conversation.
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiMessages is the system instruction and contents of a Gemini
+// generateContent request.
+type GeminiMessages struct {
+	SystemInstruction *GeminiContent  `json:"system_instruction,omitempty"`
+	Contents          []GeminiContent `json:"contents"`
+}
+
+// Messages splits sections into a system message, from the meta role,
+// intent and profile, and a user message, from the task and answer
+// guideline, each assembled in order and shaped for format. It returns nil
+// for FormatText.
+func Messages(sections Sections, order []string, format Format) (interface{}, error) {
+	if len(order) == 0 {
+		order = DefaultSectionOrder
+	}
+	var systemOrder, userOrder []string
+	for _, name := range order {
+		if systemSections[name] {
+			systemOrder = append(systemOrder, name)
+		} else {
+			userOrder = append(userOrder, name)
+		}
+	}
+	system := Assemble(sections, systemOrder)
+	user := Assemble(sections, userOrder)
+
+	switch format {
+	case FormatText:
+		return nil, nil
+	case FormatOpenAI:
+		messages := OpenAIMessages{Messages: []OpenAIMessage{}}
+		if system != "" {
+			messages.Messages = append(messages.Messages, OpenAIMessage{Role: "system", Content: system})
+		}
+		messages.Messages = append(messages.Messages, OpenAIMessage{Role: "user", Content: user})
+		return messages, nil
+	case FormatAnthropic:
+		return AnthropicMessages{
+			System:   system,
+			Messages: []AnthropicMessage{{Role: "user", Content: user}},
+		}, nil
+	case FormatGemini:
+		messages := GeminiMessages{
+			Contents: []GeminiContent{{Role: "user", Parts: []GeminiPart{{Text: user}}}},
+		}
+		if system != "" {
+			messages.SystemInstruction = &GeminiContent{Parts: []GeminiPart{{Text: system}}}
+		}
+		return messages, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// SplitSections recovers the sections of assembled prompt content. Content
+// that doesn't start with a section header, such as a prompt written by
+// hand, is all task.
+func SplitSections(content string) Sections {
+	names := make(map[string]string, len(SectionHeaders))
+	for name, header := range SectionHeaders {
+		names[header] = name
+	}
+
+	lines := strings.Split(content, "\n")
+	if _, ok := names[lines[0]]; !ok {
+		return Sections{SectionTask: content}
+	}
+
+	sections := Sections{}
+	var current string
+	var text []string
+	flush := func() {
+		sections[current] = strings.TrimRight(strings.Join(text, "\n"), "\n")
+	}
+	for i, line := range lines {
+		if name, ok := names[line]; ok && (i == 0 || lines[i-1] == "") {
+			if current != "" {
+				flush()
+			}
+			current, text = name, nil
+			continue
+		}
+		text = append(text, line)
+	}
+	flush()
+	return sections
+}
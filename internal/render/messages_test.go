@@ -0,0 +1,107 @@
+package render
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for in, expected := range map[string]Format{
+		"":          FormatText,
+		"text":      FormatText,
+		"OpenAI":    FormatOpenAI,
+		"anthropic": FormatAnthropic,
+		" gemini ":  FormatGemini,
+	} {
+		format, err := ParseFormat(in)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", in, err)
+		} else if format != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, in, format)
+		}
+	}
+
+	if _, err := ParseFormat("claude"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestMessages(t *testing.T) {
+	sections := Sections{
+		SectionMetaRole:        "You are a teacher.",
+		SectionProfile:         "Age: 34",
+		SectionTask:            "Explain recursion.",
+		SectionAnswerGuideline: "Use examples.",
+	}
+	system := "[Meta Role]\nYou are a teacher.\n\n[User Profile]\nAge: 34"
+	user := "[Task]\nExplain recursion.\n\n[Answer Guideline]\nUse examples."
+
+	tests := []struct {
+		format   Format
+		expected string
+	}{
+		{FormatOpenAI, `{"messages":[{"role":"system","content":` + quote(system) + `},{"role":"user","content":` + quote(user) + `}]}`},
+		{FormatAnthropic, `{"system":` + quote(system) + `,"messages":[{"role":"user","content":` + quote(user) + `}]}`},
+		{FormatGemini, `{"system_instruction":{"parts":[{"text":` + quote(system) + `}]},"contents":[{"role":"user","parts":[{"text":` + quote(user) + `}]}]}`},
+	}
+	for _, tt := range tests {
+		messages, err := Messages(sections, nil, tt.format)
+		if err != nil {
+			t.Fatalf("Failed to build %s messages: %v", tt.format, err)
+		}
+		data, _ := json.Marshal(messages)
+		if string(data) != tt.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.format, tt.expected, data)
+		}
+	}
+
+	// The section order applies within each message
+	order := []string{SectionTask, SectionAnswerGuideline, SectionProfile, SectionIntent, SectionMetaRole}
+	messages, _ := Messages(sections, order, FormatAnthropic)
+	if got := messages.(AnthropicMessages).System; got != "[User Profile]\nAge: 34\n\n[Meta Role]\nYou are a teacher." {
+		t.Errorf("Unexpected system prompt %q", got)
+	}
+
+	// Without system sections there is no system message
+	messages, _ = Messages(Sections{SectionTask: "Explain recursion."}, nil, FormatOpenAI)
+	if got := messages.(OpenAIMessages).Messages; len(got) != 1 || got[0].Role != "user" {
+		t.Errorf("Expected only a user message, got %v", got)
+	}
+	messages, _ = Messages(Sections{SectionTask: "Explain recursion."}, nil, FormatGemini)
+	if messages.(GeminiMessages).SystemInstruction != nil {
+		t.Error("Expected no system instruction")
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	sections := Sections{
+		SectionMetaRole: "You are a teacher.\n\nBe kind.",
+		SectionIntent:   "Be patient.",
+		SectionTask:     "Explain [Task] headers.",
+	}
+	split := SplitSections(Assemble(sections, nil))
+	for name, text := range sections {
+		if split[name] != text {
+			t.Errorf("Expected %s to be %q, got %q", name, text, split[name])
+		}
+	}
+	if len(split) != len(sections) {
+		t.Errorf("Expected %d sections, got %v", len(sections), split)
+	}
+
+	// Trailing blank lines left by older prompts are dropped
+	split = SplitSections("[Meta Role]\nYou are a teacher.\n\n[Task]\nExplain recursion.\n\n")
+	if split[SectionTask] != "Explain recursion." {
+		t.Errorf("Unexpected task %q", split[SectionTask])
+	}
+
+	split = SplitSections("Just a hand-written prompt.\n\n[Task]\nNot a section.")
+	if len(split) != 1 || split[SectionTask] != "Just a hand-written prompt.\n\n[Task]\nNot a section." {
+		t.Errorf("Expected hand-written content to be the task, got %v", split)
+	}
+}
+
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
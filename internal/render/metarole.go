@@ -0,0 +1,63 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// DefaultMetaRoleTemplate is used for personas that don't define their own
+// meta-role template.
+const DefaultMetaRoleTemplate = `
+I am a {{user_role}}.
+You are a {{llm_role}}.
+Please respond clearly, in a way that fits my background as a {{user_role}},
+while staying in your role as a {{llm_role}}.
+`
+
+// MetaRoleVariables lists the variables a meta-role template may use: the
+// persona's roles and everything in ProfileVariables.
+var MetaRoleVariables = append([]string{"user_role", "llm_role"}, ProfileVariables...)
+
+// MetaRoleContext is what a meta role is generated from. Profile and Intent
+// are optional.
+type MetaRoleContext struct {
+	Persona *models.Persona
+	Profile *models.Profile
+	Intent  *models.Intent
+}
+
+// values returns a value for every name in MetaRoleVariables, empty when the
+// context doesn't provide one.
+func (mc MetaRoleContext) values() map[string]string {
+	values := profileValues(mc.Profile, mc.Intent)
+	values["user_role"] = ""
+	values["llm_role"] = ""
+	if mc.Persona != nil {
+		values["user_role"] = mc.Persona.UserRoleDisplay
+		values["llm_role"] = mc.Persona.LLMRoleDisplay
+	}
+	return values
+}
+
+// ValidateMetaRoleTemplate checks that text is a valid template that only
+// uses MetaRoleVariables.
+func ValidateMetaRoleTemplate(text string) error {
+	_, err := parseWithVariables(text, "meta role template", MetaRoleVariables)
+	return err
+}
+
+// BuildMetaRole generates a meta role from the persona's meta-role template,
+// or from DefaultMetaRoleTemplate when it has none.
+func BuildMetaRole(mc MetaRoleContext) (string, error) {
+	text := DefaultMetaRoleTemplate
+	if mc.Persona != nil && strings.TrimSpace(mc.Persona.MetaRoleTemplate) != "" {
+		text = mc.Persona.MetaRoleTemplate
+	}
+
+	t, err := parseWithVariables(text, "meta role template", MetaRoleVariables)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(mc.values())
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestBuildMetaRole_Default(t *testing.T) {
+	out, err := BuildMetaRole(MetaRoleContext{
+		Persona: &models.Persona{UserRoleDisplay: "Developer", LLMRoleDisplay: "Code Reviewer"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build meta role: %v", err)
+	}
+
+	if !strings.Contains(out, "I am a Developer.") || !strings.Contains(out, "You are a Code Reviewer.") {
+		t.Errorf("Unexpected default meta role: %q", out)
+	}
+}
+
+func TestBuildMetaRole_PersonaTemplate(t *testing.T) {
+	out, err := BuildMetaRole(MetaRoleContext{
+		Persona: &models.Persona{
+			UserRoleDisplay:  "Student",
+			LLMRoleDisplay:   "Tutor",
+			MetaRoleTemplate: "Act as a {{llm_role}} for a {{user_role}}{{#if occupation}} who works as a {{occupation | lower}}{{/if}}. Interests: {{interests}}. {{intent_system_prompt}}",
+		},
+		Profile: &models.Profile{
+			Name: "Me",
+			Attributes: &models.Attributes{
+				Occupation: "Nurse",
+				Interests:  []string{"biology", "chess"},
+			},
+		},
+		Intent: &models.Intent{Intent: "study", SystemPrompt: "Be patient."},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build meta role: %v", err)
+	}
+
+	expected := "Act as a Tutor for a Student who works as a nurse. Interests: biology, chess. Be patient."
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestBuildMetaRole_MissingContext(t *testing.T) {
+	out, err := BuildMetaRole(MetaRoleContext{
+		Persona: &models.Persona{
+			UserRoleDisplay:  "Student",
+			MetaRoleTemplate: "{{user_role}}{{#if occupation}} ({{occupation}}){{/if}}",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build meta role: %v", err)
+	}
+
+	if out != "Student" {
+		t.Errorf("Expected profile placeholders to be empty without a profile, got %q", out)
+	}
+}
+
+func TestValidateMetaRoleTemplate(t *testing.T) {
+	if err := ValidateMetaRoleTemplate(""); err != nil {
+		t.Errorf("Expected an empty template to be valid: %v", err)
+	}
+	if err := ValidateMetaRoleTemplate("I am a {{user_role}} in {{country}}"); err != nil {
+		t.Errorf("Expected template to be valid: %v", err)
+	}
+	if err := ValidateMetaRoleTemplate("I am a {{job}}"); err == nil {
+		t.Error("Expected error for unknown variable")
+	}
+	if err := ValidateMetaRoleTemplate("{{#if user_role}}unclosed"); err == nil {
+		t.Error("Expected error for malformed template")
+	}
+}
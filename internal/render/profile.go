@@ -0,0 +1,150 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// DefaultProfileLayout is the layout CompileProfile uses unless another is
+// configured. Lines for attributes the profile doesn't set are left out. The
+// intent's system prompt isn't repeated here since prompts carry it in a
+// section of its own.
+const DefaultProfileLayout = `{{#if profile_name}}Profile: {{profile_name}}{{#if profile_description}} ({{profile_description}}){{/if}}
+{{/if}}
+{{#if age}}Age: {{age}}
+{{/if}}
+{{#if gender}}Gender: {{gender}}
+{{/if}}
+{{#if location}}Location: {{location}}
+{{/if}}
+{{#if education_level}}Education: {{education_level}}
+{{/if}}
+{{#if occupation}}Occupation: {{occupation}}
+{{/if}}
+{{#if expertise_level}}Expertise level: {{expertise_level}}
+{{/if}}
+{{#if interests}}Interests: {{interests}}
+{{/if}}
+{{#if preferred_languages}}Preferred languages: {{preferred_languages}}
+{{/if}}
+{{#if tone_preference}}Preferred tone: {{tone_preference}}
+{{/if}}`
+
+// ProfileVariables lists the variables a profile layout may use. List
+// attributes such as interests are joined with ", ", and location joins the
+// set parts of city, state and country.
+var ProfileVariables = []string{
+	"profile_name",
+	"profile_description",
+	"gender",
+	"age",
+	"location",
+	"city",
+	"state",
+	"country",
+	"education_level",
+	"occupation",
+	"interests",
+	"expertise_level",
+	"tone_preference",
+	"preferred_languages",
+	"intent",
+	"intent_name",
+	"intent_system_prompt",
+}
+
+// profileValues returns a value for every name in ProfileVariables, empty
+// when profile or intent doesn't provide one. Either may be nil.
+func profileValues(profile *models.Profile, intent *models.Intent) map[string]string {
+	values := make(map[string]string, len(ProfileVariables))
+	for _, name := range ProfileVariables {
+		values[name] = ""
+	}
+
+	if profile != nil {
+		values["profile_name"] = profile.Name
+		values["profile_description"] = profile.Description
+		if a := profile.Attributes; a != nil {
+			values["gender"] = a.Gender
+			if a.Age > 0 {
+				values["age"] = strconv.Itoa(a.Age)
+			}
+			values["city"] = a.Location.City
+			values["state"] = a.Location.State
+			values["country"] = a.Location.Country
+			var location []string
+			for _, part := range []string{a.Location.City, a.Location.State, a.Location.Country} {
+				if part != "" {
+					location = append(location, part)
+				}
+			}
+			values["location"] = strings.Join(location, ", ")
+			values["education_level"] = a.EducationLevel
+			values["occupation"] = a.Occupation
+			values["interests"] = strings.Join(a.Interests, ", ")
+			values["expertise_level"] = a.ExpertiseLevel
+			values["tone_preference"] = a.TonePreference
+			values["preferred_languages"] = strings.Join(a.PreferredLanguages, ", ")
+			values["intent"] = a.Intent
+		}
+	}
+
+	if intent != nil {
+		values["intent"] = intent.Intent
+		values["intent_name"] = intent.Name
+		values["intent_system_prompt"] = intent.SystemPrompt
+	}
+
+	return values
+}
+
+// parseWithVariables parses text and checks it only uses the given variables.
+// what names the kind of template in error messages.
+func parseWithVariables(text, what string, allowed []string) (*Template, error) {
+	t, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		known[name] = true
+	}
+	for _, v := range t.Variables() {
+		if !known[v] {
+			return nil, fmt.Errorf("%s uses unknown variable %q", what, v)
+		}
+	}
+	return t, nil
+}
+
+// ValidateProfileLayout checks that layout is a valid template that only
+// uses ProfileVariables.
+func ValidateProfileLayout(layout string) error {
+	_, err := parseWithVariables(layout, "profile layout", ProfileVariables)
+	return err
+}
+
+// CompileProfile turns a profile, and optionally its intent, into system
+// prompt text using layout, or DefaultProfileLayout when layout is empty.
+// The result is trimmed and is empty when the profile sets nothing the
+// layout shows.
+func CompileProfile(profile *models.Profile, intent *models.Intent, layout string) (string, error) {
+	if strings.TrimSpace(layout) == "" {
+		layout = DefaultProfileLayout
+	}
+
+	t, err := parseWithVariables(layout, "profile layout", ProfileVariables)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := t.Execute(profileValues(profile, intent))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
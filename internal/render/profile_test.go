@@ -0,0 +1,113 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestCompileProfile_DefaultLayout(t *testing.T) {
+	profile := &models.Profile{
+		Name: "Work",
+		Attributes: &models.Attributes{
+			Age:                34,
+			Location:           models.Location{City: "Pune", Country: "India"},
+			Occupation:         "Nurse",
+			PreferredLanguages: []string{"English", "Marathi"},
+		},
+	}
+	intent := &models.Intent{Intent: "study", SystemPrompt: "You are a patient tutor."}
+
+	out, err := CompileProfile(profile, intent, "")
+	if err != nil {
+		t.Fatalf("Failed to compile profile: %v", err)
+	}
+
+	expected := "Profile: Work\nAge: 34\nLocation: Pune, India\nOccupation: Nurse\nPreferred languages: English, Marathi"
+	if out != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestCompileProfile_CustomLayout(t *testing.T) {
+	profile := &models.Profile{
+		Name:       "Home",
+		Attributes: &models.Attributes{TonePreference: "casual"},
+	}
+
+	out, err := CompileProfile(profile, nil, `{"profile": "{{profile_name | json}}", "tone": "{{tone_preference | default "neutral"}}", "occupation": "{{occupation | default "unknown"}}"}`)
+	if err != nil {
+		t.Fatalf("Failed to compile profile: %v", err)
+	}
+
+	expected := `{"profile": "Home", "tone": "casual", "occupation": "unknown"}`
+	if out != expected {
+		t.Errorf("Expected %s, got %s", expected, out)
+	}
+}
+
+func TestCompileProfile_Empty(t *testing.T) {
+	out, err := CompileProfile(&models.Profile{}, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to compile profile: %v", err)
+	}
+
+	if out != "" {
+		t.Errorf("Expected empty output for an empty profile, got %q", out)
+	}
+}
+
+func TestValidateProfileLayout(t *testing.T) {
+	if err := ValidateProfileLayout(DefaultProfileLayout); err != nil {
+		t.Errorf("Expected default layout to be valid: %v", err)
+	}
+	if err := ValidateProfileLayout("{{user_role}}"); err == nil {
+		t.Error("Expected error for a variable profiles don't provide")
+	}
+}
+
+func TestCompileProfile_IntentVariables(t *testing.T) {
+	profile := &models.Profile{Name: "School", Attributes: &models.Attributes{Intent: "study"}}
+	intent := &models.Intent{Intent: "study", Name: "Study", SystemPrompt: "You are a patient tutor."}
+
+	out, err := CompileProfile(profile, intent, "{{intent}}/{{intent_name}}: {{intent_system_prompt}}")
+	if err != nil {
+		t.Fatalf("Failed to compile profile: %v", err)
+	}
+	if out != "study/Study: You are a patient tutor." {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestCompileProfile_ValuesAreLiteral(t *testing.T) {
+	profile := &models.Profile{
+		Name:        "Work",
+		Description: "{{x}}",
+		Attributes:  &models.Attributes{Occupation: "{{#if y}}"},
+	}
+
+	out, err := CompileProfile(profile, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to compile profile: %v", err)
+	}
+	expected := "Profile: Work ({{x}})\nOccupation: {{#if y}}"
+	if out != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	// Nor are they read as template syntax once in a generated meta role
+	persona := &models.Persona{MetaRoleTemplate: "Help a {{occupation}} with {{profile_description}}."}
+	metaRole, err := BuildMetaRole(MetaRoleContext{Persona: persona, Profile: profile})
+	if err != nil {
+		t.Fatalf("Failed to build meta role: %v", err)
+	}
+	template := &models.PromptTemplate{MetaRole: metaRole, Task: "Explain recursion."}
+	template.Template = Assemble(TemplateSections(template), nil)
+	sections, err := RenderSections(template, map[string]string{})
+	if err != nil {
+		t.Fatalf("Failed to render sections: %v", err)
+	}
+	if sections[SectionMetaRole] != "Help a {{#if y}} with {{x}}." {
+		t.Errorf("Expected the profile values rendered literally, got %q", sections[SectionMetaRole])
+	}
+}
@@ -0,0 +1,116 @@
+// Package render turns stored prompt templates into final prompt text.
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{([a-zA-Z0-9_]+)\}\}`)
+
+// ExtractVariables returns the unique variable names referenced in text,
+// including those used by {{#if}} and {{#each}}, in order of first
+// appearance. If text doesn't parse, plain {{var}} placeholders are still
+// reported so callers can show them.
+func ExtractVariables(text string) []string {
+	if t, err := Parse(text); err == nil {
+		return t.Variables()
+	}
+
+	seen := make(map[string]bool)
+	vars := []string{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			vars = append(vars, match[1])
+		}
+	}
+	return vars
+}
+
+// ValidationError reports variable values that don't line up with the
+// variables a template references.
+type ValidationError struct {
+	Missing []string     `json:"missing_variables,omitempty"`
+	Unknown []string     `json:"unknown_variables,omitempty"`
+	Invalid []FieldError `json:"invalid_variables,omitempty"`
+}
+
+func (e *ValidationError) empty() bool {
+	return len(e.Missing) == 0 && len(e.Unknown) == 0 && len(e.Invalid) == 0
+}
+
+func (e *ValidationError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing variables: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unknown) > 0 {
+		parts = append(parts, "unknown variables: "+strings.Join(e.Unknown, ", "))
+	}
+	for _, f := range e.Invalid {
+		parts = append(parts, fmt.Sprintf("%s %s", f.Variable, f.Message))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// rerenderValues drops the values t no longer references, returning them as
+// unused, and applies the template's schema to the rest.
+func rerenderValues(t *Template, template *models.PromptTemplate, values map[string]string) (map[string]string, []string, error) {
+	specs := templateSchema(t, template.VariableSchema)
+	known := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		known[spec.Name] = true
+	}
+
+	kept := make(map[string]string, len(values))
+	for name, value := range values {
+		if known[name] {
+			kept[name] = value
+		}
+	}
+	unused := unknownVariables(known, values)
+
+	resolved, err := ApplySchema(specs, kept)
+	return resolved, unused, err
+}
+
+// templateSchema resolves the schema for every variable t references.
+// Lists {{#each}} loops over take one item per line unless their type says
+// otherwise.
+func templateSchema(t *Template, schema []models.VariableSpec) []models.VariableSpec {
+	vars, conditionOnly, lists := t.analyze()
+
+	explicit := make(map[string]models.VariableSpec, len(schema))
+	for _, spec := range schema {
+		explicit[spec.Name] = spec
+	}
+
+	specs := ResolveSchema(vars, schema)
+	for i := range specs {
+		spec, ok := explicit[specs[i].Name]
+		if conditionOnly[specs[i].Name] && !ok {
+			specs[i].Required = false
+		}
+		if lists[specs[i].Name] && spec.Type == "" {
+			specs[i].Type = models.VariableTypeMultiline
+		}
+	}
+	return specs
+}
+
+// unknownVariables returns the sorted names in values that aren't in known.
+func unknownVariables(known map[string]bool, values map[string]string) []string {
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,115 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestExtractVariables(t *testing.T) {
+	vars := ExtractVariables("Review {{language}} code for {{ focus }}. Only {{language}}, {{not a var}}.")
+
+	expected := []string{"language", "focus"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
+
+func TestExtractVariables_Blocks(t *testing.T) {
+	vars := ExtractVariables("{{#if audience}}For {{audience}}: {{/if}}{{#each steps}}{{@index}}. {{this | trim}}{{/each}} {{topic | upper}}")
+
+	expected := []string{"audience", "steps", "topic"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
+
+func TestExtractVariables_InvalidTemplate(t *testing.T) {
+	vars := ExtractVariables("{{#if flag}}Use {{language}}")
+
+	expected := []string{"language"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected fallback to plain placeholders %v, got %v", expected, vars)
+	}
+}
+
+func TestRenderSections_ValidationError(t *testing.T) {
+	template := &models.PromptTemplate{Template: "Review this {{language}} code for {{focus}}"}
+	_, err := RenderSections(template, map[string]string{
+		"language": "Go",
+		"tone":     "friendly",
+		"audience": "juniors",
+	})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	if !reflect.DeepEqual(verr.Missing, []string{"focus"}) {
+		t.Errorf("Expected missing [focus], got %v", verr.Missing)
+	}
+
+	if !reflect.DeepEqual(verr.Unknown, []string{"audience", "tone"}) {
+		t.Errorf("Expected unknown [audience tone], got %v", verr.Unknown)
+	}
+}
+
+func TestRenderSections_ValueContainingPlaceholder(t *testing.T) {
+	template := &models.PromptTemplate{Template: "{{a}} and {{b}}"}
+	sections, err := RenderSections(template, map[string]string{"a": "{{b}}", "b": "x"})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+
+	if sections[SectionTask] != "{{b}} and x" {
+		t.Errorf("Values must not be re-expanded, got %q", sections[SectionTask])
+	}
+}
+
+func TestRerenderSections(t *testing.T) {
+	template := &models.PromptTemplate{
+		Template: "Review {{language}} code in a {{tone}} tone",
+		VariableSchema: []models.VariableSpec{
+			{Name: "tone", Default: "neutral"},
+		},
+	}
+
+	sections, unused, err := RerenderSections(template, map[string]string{"language": "Go", "focus": "performance"})
+	if err != nil {
+		t.Fatalf("Failed to rerender: %v", err)
+	}
+
+	if sections[SectionTask] != "Review Go code in a neutral tone" {
+		t.Errorf("Unexpected output: %q", sections[SectionTask])
+	}
+
+	if !reflect.DeepEqual(unused, []string{"focus"}) {
+		t.Errorf("Expected unused [focus], got %v", unused)
+	}
+}
+
+func TestRerenderSections_Missing(t *testing.T) {
+	template := &models.PromptTemplate{Template: "Review {{language}} code for {{audience}}"}
+
+	_, unused, err := RerenderSections(template, map[string]string{"language": "Go", "focus": "performance"})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	if !reflect.DeepEqual(verr.Missing, []string{"audience"}) {
+		t.Errorf("Expected missing [audience], got %v", verr.Missing)
+	}
+
+	if len(verr.Unknown) != 0 {
+		t.Errorf("Unused values must not be reported as unknown, got %v", verr.Unknown)
+	}
+
+	if !reflect.DeepEqual(unused, []string{"focus"}) {
+		t.Errorf("Expected unused [focus], got %v", unused)
+	}
+}
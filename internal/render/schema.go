@@ -0,0 +1,199 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// DateLayout is the format date variables must be supplied in.
+const DateLayout = "2006-01-02"
+
+// FieldError describes a variable value that failed validation.
+type FieldError struct {
+	Variable string `json:"variable"`
+	Message  string `json:"message"`
+}
+
+// ResolveSchema returns a spec for every variable in vars, in order. Variables
+// with no entry in schema are required strings.
+func ResolveSchema(vars []string, schema []models.VariableSpec) []models.VariableSpec {
+	byName := make(map[string]models.VariableSpec, len(schema))
+	for _, spec := range schema {
+		byName[spec.Name] = spec
+	}
+
+	specs := make([]models.VariableSpec, 0, len(vars))
+	for _, v := range vars {
+		spec, ok := byName[v]
+		if !ok {
+			spec = models.VariableSpec{Name: v, Required: true}
+		}
+		if spec.Type == "" {
+			spec.Type = models.VariableTypeString
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// ValidateSchema checks that a template's variable schema is well formed and
+// only describes variables the template actually uses.
+func ValidateSchema(t *Template, schema []models.VariableSpec) error {
+	vars, _, lists := t.analyze()
+	used := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		used[v] = true
+	}
+
+	seen := make(map[string]bool, len(schema))
+	for _, spec := range schema {
+		if spec.Name == "" {
+			return fmt.Errorf("variable_schema entry is missing a name")
+		}
+		if !used[spec.Name] {
+			return fmt.Errorf("variable_schema describes %q, which the template doesn't use", spec.Name)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("variable_schema describes %q more than once", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		switch spec.Type {
+		case "", models.VariableTypeString, models.VariableTypeNumber, models.VariableTypeMultiline,
+			models.VariableTypeBoolean, models.VariableTypeDate:
+		case models.VariableTypeEnum:
+			if len(spec.Options) == 0 {
+				return fmt.Errorf("enum variable %q needs at least one option", spec.Name)
+			}
+		default:
+			return fmt.Errorf("variable %q has unknown type %q", spec.Name, spec.Type)
+		}
+
+		if spec.Pattern != "" {
+			if _, err := regexp.Compile(spec.Pattern); err != nil {
+				return fmt.Errorf("variable %q has an invalid pattern: %w", spec.Name, err)
+			}
+		}
+		if spec.MinLength < 0 || spec.MaxLength < 0 {
+			return fmt.Errorf("variable %q has a negative length limit", spec.Name)
+		}
+		if spec.MaxLength > 0 && spec.MinLength > spec.MaxLength {
+			return fmt.Errorf("variable %q has min_length greater than max_length", spec.Name)
+		}
+
+		if spec.Default != "" {
+			// A list's default is one item per line unless typed otherwise
+			if lists[spec.Name] && spec.Type == "" {
+				spec.Type = models.VariableTypeMultiline
+			}
+			if _, msg := checkValue(spec, spec.Default); msg != "" {
+				return fmt.Errorf("default for variable %q is invalid: %s", spec.Name, msg)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplySchema validates values against specs and returns the values to render
+// with, with defaults filled in and booleans normalized. Values for variables
+// that have no spec are reported as unknown.
+func ApplySchema(specs []models.VariableSpec, values map[string]string) (map[string]string, error) {
+	known := make(map[string]bool, len(specs))
+	resolved := make(map[string]string, len(specs))
+	verr := &ValidationError{}
+
+	for _, spec := range specs {
+		known[spec.Name] = true
+
+		value, ok := values[spec.Name]
+		if !ok || strings.TrimSpace(value) == "" {
+			switch {
+			case spec.Default != "":
+				value = spec.Default
+			case spec.Required:
+				verr.Missing = append(verr.Missing, spec.Name)
+				continue
+			default:
+				resolved[spec.Name] = ""
+				continue
+			}
+		}
+
+		normalized, msg := checkValue(spec, value)
+		if msg != "" {
+			verr.Invalid = append(verr.Invalid, FieldError{Variable: spec.Name, Message: msg})
+			continue
+		}
+		resolved[spec.Name] = normalized
+	}
+
+	verr.Unknown = unknownVariables(known, values)
+
+	if verr.empty() {
+		return resolved, nil
+	}
+	return nil, verr
+}
+
+// checkValue validates a single non-empty value. It returns the normalized
+// value, or a message explaining why the value was rejected.
+func checkValue(spec models.VariableSpec, value string) (string, string) {
+	switch spec.Type {
+	case "", models.VariableTypeString:
+		if strings.ContainsAny(value, "\r\n") {
+			return "", "must be a single line"
+		}
+	case models.VariableTypeNumber:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return "", "must be a number"
+		}
+		value = strings.TrimSpace(value)
+	case models.VariableTypeBoolean:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", "must be true or false"
+		}
+		value = strconv.FormatBool(b)
+	case models.VariableTypeDate:
+		if _, err := time.Parse(DateLayout, strings.TrimSpace(value)); err != nil {
+			return "", "must be a date formatted as YYYY-MM-DD"
+		}
+		value = strings.TrimSpace(value)
+	case models.VariableTypeEnum:
+		found := false
+		for _, option := range spec.Options {
+			if value == option {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", "must be one of: " + strings.Join(spec.Options, ", ")
+		}
+	}
+
+	length := utf8.RuneCountInString(value)
+	if spec.MinLength > 0 && length < spec.MinLength {
+		return "", fmt.Sprintf("must be at least %d characters", spec.MinLength)
+	}
+	if spec.MaxLength > 0 && length > spec.MaxLength {
+		return "", fmt.Sprintf("must be at most %d characters", spec.MaxLength)
+	}
+	if spec.Pattern != "" {
+		re, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return "", "has an invalid pattern"
+		}
+		if !re.MatchString(value) {
+			return "", "must match pattern " + spec.Pattern
+		}
+	}
+
+	return value, ""
+}
@@ -0,0 +1,147 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestResolveSchema(t *testing.T) {
+	specs := ResolveSchema([]string{"language", "focus"}, []models.VariableSpec{
+		{Name: "focus", Type: models.VariableTypeEnum, Options: []string{"performance", "style"}},
+	})
+
+	if len(specs) != 2 {
+		t.Fatalf("Expected 2 specs, got %d", len(specs))
+	}
+
+	if specs[0].Name != "language" || specs[0].Type != models.VariableTypeString || !specs[0].Required {
+		t.Errorf("Expected language to default to a required string, got %+v", specs[0])
+	}
+
+	if specs[1].Type != models.VariableTypeEnum || specs[1].Required {
+		t.Errorf("Expected focus to keep its spec, got %+v", specs[1])
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	tmpl, err := Parse("{{count}} {{level}}{{#each items}}{{this}}{{/each}}")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		schema  []models.VariableSpec
+		wantErr bool
+	}{
+		{"valid", []models.VariableSpec{{Name: "count", Type: models.VariableTypeNumber, Default: "3"}}, false},
+		{"unused variable", []models.VariableSpec{{Name: "other"}}, true},
+		{"duplicate", []models.VariableSpec{{Name: "count"}, {Name: "count"}}, true},
+		{"unknown type", []models.VariableSpec{{Name: "count", Type: "integer"}}, true},
+		{"enum without options", []models.VariableSpec{{Name: "level", Type: models.VariableTypeEnum}}, true},
+		{"bad pattern", []models.VariableSpec{{Name: "level", Pattern: "("}}, true},
+		{"min over max", []models.VariableSpec{{Name: "level", MinLength: 5, MaxLength: 2}}, true},
+		{"invalid default", []models.VariableSpec{{Name: "count", Type: models.VariableTypeNumber, Default: "many"}}, true},
+		{"list default", []models.VariableSpec{{Name: "items", Default: "a\nb"}}, false},
+		{"single line default", []models.VariableSpec{{Name: "level", Default: "a\nb"}}, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateSchema(tmpl, tt.schema)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestApplySchema(t *testing.T) {
+	specs := []models.VariableSpec{
+		{Name: "topic", Type: models.VariableTypeString, Required: true},
+		{Name: "tone", Type: models.VariableTypeEnum, Options: []string{"formal", "casual"}, Default: "formal"},
+		{Name: "notes", Type: models.VariableTypeMultiline},
+		{Name: "detailed", Type: models.VariableTypeBoolean, Required: true},
+		{Name: "due", Type: models.VariableTypeDate},
+	}
+
+	resolved, err := ApplySchema(specs, map[string]string{
+		"topic":    "Go interfaces",
+		"notes":    "line one\nline two",
+		"detailed": "1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply schema: %v", err)
+	}
+
+	expected := map[string]string{
+		"topic":    "Go interfaces",
+		"tone":     "formal",
+		"notes":    "line one\nline two",
+		"detailed": "true",
+		"due":      "",
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("Expected %v, got %v", expected, resolved)
+	}
+}
+
+func TestApplySchema_Errors(t *testing.T) {
+	specs := []models.VariableSpec{
+		{Name: "topic", Type: models.VariableTypeString, Required: true, MaxLength: 10},
+		{Name: "count", Type: models.VariableTypeNumber},
+		{Name: "code", Pattern: `^[A-Z]{3}$`},
+		{Name: "due", Type: models.VariableTypeDate, Required: true},
+	}
+
+	_, err := ApplySchema(specs, map[string]string{
+		"topic": "a topic that is far too long",
+		"count": "lots",
+		"code":  "abc",
+		"extra": "x",
+	})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	if !reflect.DeepEqual(verr.Missing, []string{"due"}) {
+		t.Errorf("Expected missing [due], got %v", verr.Missing)
+	}
+
+	if !reflect.DeepEqual(verr.Unknown, []string{"extra"}) {
+		t.Errorf("Expected unknown [extra], got %v", verr.Unknown)
+	}
+
+	invalid := make([]string, 0, len(verr.Invalid))
+	for _, f := range verr.Invalid {
+		invalid = append(invalid, f.Variable)
+	}
+	if !reflect.DeepEqual(invalid, []string{"topic", "count", "code"}) {
+		t.Errorf("Expected invalid [topic count code], got %v", invalid)
+	}
+}
+
+func TestRenderSections_Schema(t *testing.T) {
+	template := &models.PromptTemplate{
+		Template: "Explain {{topic}} in a {{tone}} tone.",
+		VariableSchema: []models.VariableSpec{
+			{Name: "tone", Type: models.VariableTypeEnum, Options: []string{"formal", "casual"}, Default: "casual"},
+		},
+	}
+
+	sections, err := RenderSections(template, map[string]string{"topic": "recursion"})
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+
+	if sections[SectionTask] != "Explain recursion in a casual tone." {
+		t.Errorf("Unexpected output: %q", sections[SectionTask])
+	}
+
+	if _, err := RenderSections(template, map[string]string{"topic": ""}); err == nil {
+		t.Error("Expected an error for an empty required variable")
+	}
+}
@@ -0,0 +1,686 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The template dialect is a small, logic-less language:
+//
+//	{{name}}                      substitute a variable
+//	{{name | trim | upper}}       pipe a value through filters
+//	{{#if name}}...{{else}}...{{/if}}
+//	{{#if !name}}...{{/if}}       a value is false when empty, "false" or "0"
+//	{{#each name}}...{{/each}}    repeat over a list value; inside the block
+//	                              {{this}} is the item, {{@index}} its
+//	                              position, and @first/@last can be tested
+//
+// List values are either a JSON array of strings or one item per line.
+// Templates can only read the values they are given and apply the built-in
+// filters, output is capped at maxOutputBytes, and loops at maxLoopSteps
+// iterations in all.
+
+// maxOutputBytes bounds how much text a single render may produce.
+const maxOutputBytes = 1 << 20
+
+// maxLoopSteps bounds how many {{#each}} iterations a single render may run,
+// counting those of nested loops, which produce nothing when their bodies
+// are empty.
+const maxLoopSteps = 100000
+
+var (
+	identPattern   = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+	specialOperand = map[string]bool{"this": true, "@index": true, "@first": true, "@last": true}
+)
+
+// filterArity is the number of arguments each built-in filter takes.
+var filterArity = map[string]int{
+	"upper":    0,
+	"lower":    0,
+	"trim":     0,
+	"title":    0,
+	"json":     0,
+	"truncate": 1,
+	"default":  1,
+}
+
+// Template is a parsed prompt template.
+type Template struct {
+	nodes []node
+}
+
+type node interface{}
+
+type textNode string
+
+type exprNode struct {
+	operand string
+	filters []filterCall
+}
+
+type filterCall struct {
+	name string
+	arg  string
+}
+
+type ifNode struct {
+	cond   string
+	negate bool
+	then   []node
+	els    []node
+}
+
+type eachNode struct {
+	list string
+	body []node
+}
+
+// SyntaxError reports a malformed template.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("template syntax error on line %d: %s", e.Line, e.Msg)
+}
+
+// Parse parses text written in the template dialect.
+func Parse(text string) (*Template, error) {
+	tokens := trimStandalone(lex(text))
+	p := &parser{tokens: tokens}
+	nodes, err := p.parseUntil(0)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{nodes: nodes}, nil
+}
+
+// Variables returns the names of the values the template reads, in order of
+// first appearance.
+func (t *Template) Variables() []string {
+	vars, _, _ := t.analyze()
+	return vars
+}
+
+// analyze returns the referenced variables, the set of those that are only
+// ever tested by {{#if}}, which makes them safe to leave empty, and the set
+// of those {{#each}} loops over.
+func (t *Template) analyze() ([]string, map[string]bool, map[string]bool) {
+	var vars []string
+	seen := make(map[string]bool)
+	conditionOnly := make(map[string]bool)
+	lists := make(map[string]bool)
+
+	add := func(name string, condition bool) {
+		if specialOperand[name] {
+			return
+		}
+		if !seen[name] {
+			seen[name] = true
+			vars = append(vars, name)
+			conditionOnly[name] = condition
+		} else if !condition {
+			conditionOnly[name] = false
+		}
+	}
+
+	var walk func(nodes []node)
+	walk = func(nodes []node) {
+		for _, n := range nodes {
+			switch n := n.(type) {
+			case *exprNode:
+				add(n.operand, false)
+			case *ifNode:
+				add(n.cond, true)
+				walk(n.then)
+				walk(n.els)
+			case *eachNode:
+				add(n.list, false)
+				if !specialOperand[n.list] {
+					lists[n.list] = true
+				}
+				walk(n.body)
+			}
+		}
+	}
+	walk(t.nodes)
+
+	for name, only := range conditionOnly {
+		if !only {
+			delete(conditionOnly, name)
+		}
+	}
+	return vars, conditionOnly, lists
+}
+
+// Execute renders the template. Variables without a value render as empty.
+func (t *Template) Execute(values map[string]string) (string, error) {
+	ex := &executor{values: values}
+	if err := ex.run(t.nodes); err != nil {
+		return "", err
+	}
+	return ex.out.String(), nil
+}
+
+// Lexing
+
+type tokenKind int
+
+const (
+	tokText tokenKind = iota
+	tokExpr
+	tokIf
+	tokEach
+	tokElse
+	tokEndIf
+	tokEndEach
+)
+
+type token struct {
+	kind tokenKind
+	text string // raw text for tokText, tag body otherwise
+	line int
+}
+
+func (t token) block() bool {
+	return t.kind != tokText && t.kind != tokExpr
+}
+
+func lex(text string) []token {
+	var tokens []token
+	line := 1
+	for len(text) > 0 {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			tokens = append(tokens, token{kind: tokText, text: text, line: line})
+			break
+		}
+		end := strings.Index(text[start+2:], "}}")
+		if end < 0 {
+			tokens = append(tokens, token{kind: tokText, text: text, line: line})
+			break
+		}
+		end += start + 2
+
+		if start > 0 {
+			tokens = append(tokens, token{kind: tokText, text: text[:start], line: line})
+			line += strings.Count(text[:start], "\n")
+		}
+
+		raw := text[start : end+2]
+		body := strings.TrimSpace(text[start+2 : end])
+		tokens = append(tokens, classify(raw, body, line))
+		line += strings.Count(raw, "\n")
+		text = text[end+2:]
+	}
+	return tokens
+}
+
+func classify(raw, body string, line int) token {
+	switch {
+	case strings.HasPrefix(body, "#if ") || body == "#if":
+		return token{kind: tokIf, text: strings.TrimSpace(strings.TrimPrefix(body, "#if")), line: line}
+	case strings.HasPrefix(body, "#each ") || body == "#each":
+		return token{kind: tokEach, text: strings.TrimSpace(strings.TrimPrefix(body, "#each")), line: line}
+	case body == "else":
+		return token{kind: tokElse, line: line}
+	case body == "/if":
+		return token{kind: tokEndIf, line: line}
+	case body == "/each":
+		return token{kind: tokEndEach, line: line}
+	case strings.HasPrefix(body, "#") || strings.HasPrefix(body, "/"):
+		// An unknown block tag; the parser reports it.
+		return token{kind: tokExpr, text: body, line: line}
+	}
+	operand := strings.TrimSpace(splitPipes(body)[0])
+	if !identPattern.MatchString(operand) && !specialOperand[operand] {
+		// Not something the dialect understands; keep it as literal text.
+		return token{kind: tokText, text: raw, line: line}
+	}
+	return token{kind: tokExpr, text: body, line: line}
+}
+
+// trimStandalone removes the surrounding whitespace and line break of block
+// tags that sit alone on a line, so they don't leave blank lines behind.
+func trimStandalone(tokens []token) []token {
+	// Decide against the original text first, then cut, so adjacent
+	// standalone tags don't affect each other.
+	start := make([]int, len(tokens))
+	end := make([]int, len(tokens))
+	for i, tok := range tokens {
+		end[i] = len(tok.text)
+	}
+
+	for i, tok := range tokens {
+		if !tok.block() {
+			continue
+		}
+
+		prevOK, prevCut := i == 0, 0
+		if i > 0 && tokens[i-1].kind == tokText {
+			text := tokens[i-1].text
+			lastNL := strings.LastIndex(text, "\n")
+			if strings.TrimSpace(text[lastNL+1:]) == "" && (lastNL >= 0 || i == 1) {
+				prevOK, prevCut = true, lastNL+1
+			}
+		}
+
+		nextOK, nextCut := i == len(tokens)-1, 0
+		if i < len(tokens)-1 && tokens[i+1].kind == tokText {
+			text := tokens[i+1].text
+			head, nl := text, strings.Index(text, "\n")
+			if nl >= 0 {
+				head = text[:nl]
+			}
+			if strings.TrimSpace(head) == "" && (nl >= 0 || i+1 == len(tokens)-1) {
+				nextOK, nextCut = true, len(head)
+				if nl >= 0 {
+					nextCut = nl + 1
+				}
+			}
+		}
+
+		if !prevOK || !nextOK {
+			continue
+		}
+		if i > 0 && tokens[i-1].kind == tokText {
+			end[i-1] = prevCut
+		}
+		if i < len(tokens)-1 && tokens[i+1].kind == tokText {
+			start[i+1] = nextCut
+		}
+	}
+
+	for i := range tokens {
+		if tokens[i].kind != tokText {
+			continue
+		}
+		if start[i] >= end[i] {
+			tokens[i].text = ""
+		} else {
+			tokens[i].text = tokens[i].text[start[i]:end[i]]
+		}
+	}
+	return tokens
+}
+
+// Parsing
+
+type parser struct {
+	tokens []token
+	pos    int
+	inEach int
+}
+
+// parseUntil parses nodes up to the next {{else}} or closing tag, or the end
+// of the template. depth is 0 at the top level, where those tags are errors.
+func (p *parser) parseUntil(depth int) ([]node, error) {
+	var nodes []node
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		switch tok.kind {
+		case tokText:
+			p.pos++
+			if tok.text != "" {
+				nodes = append(nodes, textNode(tok.text))
+			}
+
+		case tokExpr:
+			p.pos++
+			if strings.HasPrefix(tok.text, "#") || strings.HasPrefix(tok.text, "/") {
+				return nil, &SyntaxError{tok.line, fmt.Sprintf("unknown tag {{%s}}", tok.text)}
+			}
+			expr, err := parseExpr(tok.text)
+			if err != nil {
+				return nil, &SyntaxError{tok.line, err.Error()}
+			}
+			if err := p.checkOperand(expr.operand, tok.line); err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, expr)
+
+		case tokIf:
+			p.pos++
+			n, err := p.parseIf(tok)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+
+		case tokEach:
+			p.pos++
+			n, err := p.parseEach(tok)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+
+		case tokElse, tokEndIf, tokEndEach:
+			if depth == 0 {
+				return nil, &SyntaxError{tok.line, fmt.Sprintf("unexpected %s", tagName(tok.kind))}
+			}
+			return nodes, nil
+		}
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseIf(open token) (node, error) {
+	cond := open.text
+	negate := strings.HasPrefix(cond, "!")
+	cond = strings.TrimSpace(strings.TrimPrefix(cond, "!"))
+	if !identPattern.MatchString(cond) && !specialOperand[cond] {
+		return nil, &SyntaxError{open.line, fmt.Sprintf("{{#if}} needs a variable name, got %q", open.text)}
+	}
+	if err := p.checkOperand(cond, open.line); err != nil {
+		return nil, err
+	}
+
+	n := &ifNode{cond: cond, negate: negate}
+	var err error
+	if n.then, err = p.parseUntil(1); err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, &SyntaxError{open.line, "unclosed {{#if}}"}
+	}
+
+	if p.tokens[p.pos].kind == tokElse {
+		p.pos++
+		if n.els, err = p.parseUntil(1); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) {
+			return nil, &SyntaxError{open.line, "unclosed {{#if}}"}
+		}
+	}
+
+	if closing := p.tokens[p.pos]; closing.kind != tokEndIf {
+		return nil, &SyntaxError{closing.line, fmt.Sprintf("expected {{/if}}, got %s", tagName(closing.kind))}
+	}
+	p.pos++
+	return n, nil
+}
+
+func (p *parser) parseEach(open token) (node, error) {
+	if !identPattern.MatchString(open.text) {
+		return nil, &SyntaxError{open.line, fmt.Sprintf("{{#each}} needs a variable name, got %q", open.text)}
+	}
+
+	n := &eachNode{list: open.text}
+	var err error
+	p.inEach++
+	n.body, err = p.parseUntil(1)
+	p.inEach--
+	if err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, &SyntaxError{open.line, "unclosed {{#each}}"}
+	}
+	if closing := p.tokens[p.pos]; closing.kind != tokEndEach {
+		return nil, &SyntaxError{closing.line, fmt.Sprintf("expected {{/each}}, got %s", tagName(closing.kind))}
+	}
+	p.pos++
+	return n, nil
+}
+
+func (p *parser) checkOperand(operand string, line int) error {
+	if specialOperand[operand] && p.inEach == 0 {
+		return &SyntaxError{line, fmt.Sprintf("%s can only be used inside {{#each}}", operand)}
+	}
+	return nil
+}
+
+func tagName(kind tokenKind) string {
+	switch kind {
+	case tokIf:
+		return "{{#if}}"
+	case tokEach:
+		return "{{#each}}"
+	case tokElse:
+		return "{{else}}"
+	case tokEndIf:
+		return "{{/if}}"
+	case tokEndEach:
+		return "{{/each}}"
+	}
+	return "tag"
+}
+
+// parseExpr parses "operand | filter arg | filter".
+func parseExpr(body string) (*exprNode, error) {
+	parts := splitPipes(body)
+	operand := strings.TrimSpace(parts[0])
+	if !identPattern.MatchString(operand) && !specialOperand[operand] {
+		return nil, fmt.Errorf("invalid variable name %q", operand)
+	}
+
+	expr := &exprNode{operand: operand}
+	for _, part := range parts[1:] {
+		call, err := parseFilter(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		expr.filters = append(expr.filters, call)
+	}
+	return expr, nil
+}
+
+// splitPipes splits on "|" outside of double-quoted strings.
+func splitPipes(s string) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuote {
+				i++
+			}
+		case '"':
+			inQuote = !inQuote
+		case '|':
+			if !inQuote {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseFilter(s string) (filterCall, error) {
+	name, arg, hasArg := strings.Cut(s, " ")
+	arity, ok := filterArity[name]
+	if !ok {
+		return filterCall{}, fmt.Errorf("unknown filter %q", name)
+	}
+
+	arg = strings.TrimSpace(arg)
+	if hasArg && arg == "" {
+		hasArg = false
+	}
+	if arity == 0 && hasArg {
+		return filterCall{}, fmt.Errorf("filter %q takes no argument", name)
+	}
+	if arity == 1 && !hasArg {
+		return filterCall{}, fmt.Errorf("filter %q needs an argument", name)
+	}
+
+	if strings.HasPrefix(arg, `"`) {
+		unquoted, err := strconv.Unquote(arg)
+		if err != nil {
+			return filterCall{}, fmt.Errorf("invalid argument for filter %q: %s", name, arg)
+		}
+		arg = unquoted
+	}
+	if name == "truncate" {
+		if n, err := strconv.Atoi(arg); err != nil || n < 0 {
+			return filterCall{}, fmt.Errorf("truncate needs a non-negative length, got %q", arg)
+		}
+	}
+	return filterCall{name: name, arg: arg}, nil
+}
+
+// Execution
+
+type eachFrame struct {
+	item  string
+	index int
+	count int
+}
+
+type executor struct {
+	values map[string]string
+	frames []eachFrame
+	steps  int
+	out    strings.Builder
+}
+
+func (ex *executor) write(s string) error {
+	if ex.out.Len()+len(s) > maxOutputBytes {
+		return fmt.Errorf("rendered prompt exceeds %d bytes", maxOutputBytes)
+	}
+	ex.out.WriteString(s)
+	return nil
+}
+
+func (ex *executor) run(nodes []node) error {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case textNode:
+			if err := ex.write(string(n)); err != nil {
+				return err
+			}
+
+		case *exprNode:
+			value := ex.lookup(n.operand)
+			for _, f := range n.filters {
+				value = applyFilter(f, value)
+			}
+			if err := ex.write(value); err != nil {
+				return err
+			}
+
+		case *ifNode:
+			branch := n.els
+			if truthy(ex.lookup(n.cond)) != n.negate {
+				branch = n.then
+			}
+			if err := ex.run(branch); err != nil {
+				return err
+			}
+
+		case *eachNode:
+			items := ListItems(ex.values[n.list])
+			for i, item := range items {
+				if ex.steps++; ex.steps > maxLoopSteps {
+					return fmt.Errorf("template loops run more than %d times", maxLoopSteps)
+				}
+				ex.frames = append(ex.frames, eachFrame{item: item, index: i, count: len(items)})
+				err := ex.run(n.body)
+				ex.frames = ex.frames[:len(ex.frames)-1]
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (ex *executor) lookup(operand string) string {
+	if specialOperand[operand] {
+		frame := ex.frames[len(ex.frames)-1]
+		switch operand {
+		case "this":
+			return frame.item
+		case "@index":
+			return strconv.Itoa(frame.index)
+		case "@first":
+			return strconv.FormatBool(frame.index == 0)
+		case "@last":
+			return strconv.FormatBool(frame.index == frame.count-1)
+		}
+	}
+	return ex.values[operand]
+}
+
+func truthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "0", "[]":
+		return false
+	}
+	return true
+}
+
+// ListItems splits a list value into its items. A value that is a JSON array
+// of strings is decoded; anything else is treated as one item per line, with
+// blank lines ignored.
+func ListItems(value string) []string {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "[") {
+		var items []string
+		if err := json.Unmarshal([]byte(trimmed), &items); err == nil {
+			return items
+		}
+	}
+
+	var items []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
+
+func applyFilter(f filterCall, value string) string {
+	switch f.name {
+	case "upper":
+		return strings.ToUpper(value)
+	case "lower":
+		return strings.ToLower(value)
+	case "trim":
+		return strings.TrimSpace(value)
+	case "title":
+		return titleCase(value)
+	case "json":
+		encoded, _ := json.Marshal(value)
+		return string(encoded[1 : len(encoded)-1])
+	case "truncate":
+		n, _ := strconv.Atoi(f.arg)
+		if utf8.RuneCountInString(value) <= n {
+			return value
+		}
+		return string([]rune(value)[:n])
+	case "default":
+		if strings.TrimSpace(value) == "" {
+			return f.arg
+		}
+	}
+	return value
+}
+
+func titleCase(s string) string {
+	runes := []rune(s)
+	atWordStart := true
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			atWordStart = true
+			continue
+		}
+		if atWordStart {
+			runes[i] = unicode.ToUpper(r)
+		}
+		atWordStart = false
+	}
+	return string(runes)
+}
@@ -0,0 +1,179 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		values   map[string]string
+		expected string
+	}{
+		{
+			name:     "if true",
+			template: "Hello{{#if name}}, {{name}}{{/if}}!",
+			values:   map[string]string{"name": "Ada"},
+			expected: "Hello, Ada!",
+		},
+		{
+			name:     "if false with else",
+			template: "{{#if formal}}Dear reader{{else}}Hey{{/if}}",
+			values:   map[string]string{"formal": "false"},
+			expected: "Hey",
+		},
+		{
+			name:     "negated if",
+			template: "{{#if !context}}No context given.{{/if}}",
+			values:   map[string]string{"context": ""},
+			expected: "No context given.",
+		},
+		{
+			name:     "each over lines",
+			template: "{{#each steps}}{{@index}}:{{this}}{{#if !@last}}, {{/if}}{{/each}}",
+			values:   map[string]string{"steps": "plan\n\nbuild\nship"},
+			expected: "0:plan, 1:build, 2:ship",
+		},
+		{
+			name:     "each over JSON array",
+			template: "{{#each tags}}[{{this | upper}}]{{/each}}",
+			values:   map[string]string{"tags": `["go", "sql"]`},
+			expected: "[GO][SQL]",
+		},
+		{
+			name:     "filters",
+			template: `{{a | trim | title}}/{{b | truncate 5}}/{{c | json}}/{{d | default "n/a"}}/{{e | lower}}`,
+			values:   map[string]string{"a": "  hello world ", "b": "abcdefgh", "c": "say \"hi\"\n", "d": "", "e": "LOUD"},
+			expected: `Hello World/abcde/say \"hi\"\n/n/a/loud`,
+		},
+		{
+			name:     "standalone block lines",
+			template: "Intro\n{{#if extra}}\nExtra line\n{{/if}}\nOutro",
+			values:   map[string]string{"extra": "yes"},
+			expected: "Intro\nExtra line\nOutro",
+		},
+		{
+			name:     "standalone nested blocks",
+			template: "{{#each items}}\n{{#if this}}\n- {{this}}\n{{/if}}\n{{/each}}\n",
+			values:   map[string]string{"items": "a\nb"},
+			expected: "- a\n- b\n",
+		},
+		{
+			name:     "unrecognized tags stay literal",
+			template: `Return {{"key": 1}} and {{not a var}}`,
+			values:   map[string]string{},
+			expected: `Return {{"key": 1}} and {{not a var}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template)
+		if err != nil {
+			t.Errorf("%s: failed to parse: %v", tt.name, err)
+			continue
+		}
+		out, err := tmpl.Execute(tt.values)
+		if err != nil {
+			t.Errorf("%s: failed to execute: %v", tt.name, err)
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []string{
+		"{{#if name}}unclosed",
+		"{{#each items}}{{/if}}",
+		"stray {{/each}}",
+		"{{else}}",
+		"{{this}} outside each",
+		"{{name | shout}}",
+		"{{name | truncate}}",
+		"{{name | truncate many}}",
+		"{{#unless name}}x{{/unless}}",
+		"{{#if a b}}x{{/if}}",
+	}
+
+	for _, text := range tests {
+		_, err := Parse(text)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%q: expected a SyntaxError, got %v", text, err)
+		}
+	}
+}
+
+func TestParse_ErrorLine(t *testing.T) {
+	_, err := Parse("line one\nline two {{#each items}}\nno end")
+
+	var serr *SyntaxError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Expected a SyntaxError, got %v", err)
+	}
+	if serr.Line != 2 {
+		t.Errorf("Expected error on line 2, got %d", serr.Line)
+	}
+}
+
+func TestExecute_OutputLimit(t *testing.T) {
+	tmpl, err := Parse("{{#each a}}{{#each a}}{{big}}{{/each}}{{/each}}")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	items := strings.Repeat("x\n", 100)
+	_, err = tmpl.Execute(map[string]string{"a": items, "big": strings.Repeat("y", 200)})
+	if err == nil || !strings.Contains(err.Error(), "bytes") {
+		t.Errorf("Expected output limit error, got %v", err)
+	}
+}
+
+func TestExecute_LoopLimit(t *testing.T) {
+	// Loops with empty bodies write nothing, so only the step limit stops them
+	tmpl, err := Parse("{{#each a}}{{#each a}}{{#each a}}{{#each a}}{{/each}}{{/each}}{{/each}}{{/each}}")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	_, err = tmpl.Execute(map[string]string{"a": strings.Repeat("x\n", 100)})
+	if err == nil || !strings.Contains(err.Error(), "loops") {
+		t.Errorf("Expected loop limit error, got %v", err)
+	}
+
+	if _, err := tmpl.Execute(map[string]string{"a": strings.Repeat("x\n", 10)}); err != nil {
+		t.Errorf("Expected 10,000 iterations to be allowed, got %v", err)
+	}
+}
+
+func TestListItems(t *testing.T) {
+	if got := ListItems(`["a", "b, c"]`); !reflect.DeepEqual(got, []string{"a", "b, c"}) {
+		t.Errorf("Unexpected JSON list items: %v", got)
+	}
+	if got := ListItems(" a \n\n b\n"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Unexpected line list items: %v", got)
+	}
+	if got := ListItems("[not json"); !reflect.DeepEqual(got, []string{"[not json"}) {
+		t.Errorf("Unexpected fallback list items: %v", got)
+	}
+}
+
+func TestRenderSections_ConditionOnlyVariablesAreOptional(t *testing.T) {
+	template := &models.PromptTemplate{Template: "Explain {{topic}}{{#if brief}} briefly{{/if}}."}
+
+	sections, err := RenderSections(template, map[string]string{"topic": "closures"})
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if sections[SectionTask] != "Explain closures." {
+		t.Errorf("Unexpected output: %q", sections[SectionTask])
+	}
+}
@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// ActiveProfile is the profile used when a request doesn't name one.
+// ProfileID is empty when none is set; Profile is nil for the default
+// profile.
+type ActiveProfile struct {
+	ProfileID string          `json:"profile_id"`
+	Profile   *models.Profile `json:"profile"`
+}
+
+// SetActiveProfileRequest selects the active profile; an empty ProfileID
+// clears it.
+type SetActiveProfileRequest struct {
+	ProfileID string `json:"profile_id"`
+}
+
+// errProfilesUnsupported is returned for storage that doesn't keep
+// profiles.
+var errProfilesUnsupported = errors.New("profiles are not supported by this storage")
+
+// requestProfileStore returns the request's store as profile storage. When
+// there is none it writes the error response and returns false.
+func requestProfileStore(c *gin.Context) (storage.ProfileStorage, bool) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return nil, false
+	}
+	profileStore, ok := store.(storage.ProfileStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errProfilesUnsupported.Error()})
+		return nil, false
+	}
+	return profileStore, true
+}
+
+// activeProfileID returns the user's active profile, or "" when none is set
+// or the store doesn't track one.
+func activeProfileID(store interface{}) string {
+	profileStore, ok := store.(storage.ProfileStorage)
+	if !ok {
+		return ""
+	}
+	id, err := profileStore.GetActiveProfileID()
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// queryProfileID returns the profile a list request is scoped to: the
+// profile_id query parameter when given, else the active profile. An empty
+// profile_id parameter lists every profile.
+func queryProfileID(c *gin.Context, store interface{}) string {
+	if id, ok := c.GetQuery("profile_id"); ok {
+		return id
+	}
+	return activeProfileID(store)
+}
+
+// defaultProfileID returns the profile a new item belongs to when the
+// request gives id: id itself, else the active profile, else DefaultProfileID.
+func defaultProfileID(store interface{}, id string) string {
+	if id != "" {
+		return id
+	}
+	if id = activeProfileID(store); id != "" {
+		return id
+	}
+	return DefaultProfileID
+}
+
+// GetActiveProfile handles GET /profiles/active
+func (h *ProfileHandler) GetActiveProfile(c *gin.Context) {
+	profileStore, ok := requestProfileStore(c)
+	if !ok {
+		return
+	}
+
+	id, err := profileStore.GetActiveProfileID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ActiveProfile{ProfileID: id, Profile: lookupProfile(profileStore, id)})
+}
+
+// SetActiveProfile handles PUT /profiles/active
+func (h *ProfileHandler) SetActiveProfile(c *gin.Context) {
+	profileStore, ok := requestProfileStore(c)
+	if !ok {
+		return
+	}
+
+	var req SetActiveProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile := lookupProfile(profileStore, req.ProfileID)
+	if profile == nil && req.ProfileID != "" && req.ProfileID != DefaultProfileID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
+
+	if err := profileStore.SetActiveProfileID(req.ProfileID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ActiveProfile{ProfileID: req.ProfileID, Profile: profile})
+}
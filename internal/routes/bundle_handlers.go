@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rahulguha/promptly/internal/bundle"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// maxBundleSize is the largest bundle an import request can upload.
+const maxBundleSize = 32 << 20
+
+// errBundlesUnsupported is returned for storage that can't export or
+// import bundles.
+var errBundlesUnsupported = errors.New("bundles are not supported by this storage")
+
+// Export handles GET /export. The format parameter picks a json (the
+// default) or zip bundle.
+func (h *Handler) Export(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", bundle.FormatJSON)
+	if !bundle.ValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or zip"})
+		return
+	}
+
+	bundleStore, ok := store.(bundle.Store)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errBundlesUnsupported.Error()})
+		return
+	}
+
+	b, err := bundle.Export(bundleStore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.Write(&buf, b, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "application/json"
+	if format == bundle.FormatZIP {
+		contentType = "application/zip"
+	}
+	filename := "promptly-export-" + time.Now().UTC().Format("20060102") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// Import handles POST /import. The body is a bundle in either format, and
+// the strategy parameter says what to do with items that already exist:
+// skip (the default), overwrite or duplicate.
+func (h *Handler) Import(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+
+	if _, ok := store.(bundle.Store); !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errBundlesUnsupported.Error()})
+		return
+	}
+
+	strategy := c.DefaultQuery("strategy", bundle.StrategySkip)
+	if !bundle.ValidStrategy(strategy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "strategy must be skip, overwrite or duplicate"})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBundleSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(data) > maxBundleSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Bundles can be at most " + strconv.Itoa(maxBundleSize>>20) + " MB"})
+		return
+	}
+
+	b, err := bundle.Read(data)
+	if errors.Is(err, bundle.ErrTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A bundle is imported whole or not at all
+	var result *bundle.Result
+	err = store.(storage.Storage).WithTx(func(tx storage.Storage) error {
+		bundleStore, ok := tx.(bundle.Store)
+		if !ok {
+			return errBundlesUnsupported
+		}
+		var err error
+		result, err = bundle.Import(bundleStore, b, strategy)
+		return err
+	})
+	if errors.Is(err, errBundlesUnsupported) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/bundle"
+)
+
+func TestImport(t *testing.T) {
+	source := newSQLiteStore(t)
+	persona := createPersona(t, source, "Developer")
+	b, err := bundle.Export(source)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	var data bytes.Buffer
+	if err := bundle.Write(&data, b, bundle.FormatZIP); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+
+	store := newSQLiteStore(t)
+	h := &Handler{}
+	post := func(query string, body []byte) *httptest.ResponseRecorder {
+		return serve(store, h.Import, http.MethodPost, "/import", "/import"+query, bytes.NewReader(body))
+	}
+
+	w := post("", data.Bytes())
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var result bundle.Result
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if result.Personas.Created != 1 {
+		t.Errorf("Expected one persona created, got %+v", result.Personas)
+	}
+	if _, err := store.GetPersonaByID(persona.ID); err != nil {
+		t.Errorf("Expected the persona to be imported: %v", err)
+	}
+
+	if w := post("?strategy=merge", data.Bytes()); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown strategy, got %d: %s", w.Code, w.Body)
+	}
+	if w := post("", []byte("not a bundle")); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a body that isn't a bundle, got %d: %s", w.Code, w.Body)
+	}
+	if w := post("", make([]byte, maxBundleSize+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestImport_Unsupported(t *testing.T) {
+	h := &Handler{}
+	w := serve(newJSONStore(t), h.Import, http.MethodPost, "/import", "/import", bytes.NewReader([]byte("{}")))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501, got %d: %s", w.Code, w.Body)
+	}
+}
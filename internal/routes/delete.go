@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// deleteOptions reads the dry_run and restrict parameters of a request to
+// delete a persona or template version. It writes a 400 response and
+// returns false when one is invalid.
+func deleteOptions(c *gin.Context) (dryRun, restrict, ok bool) {
+	for param, value := range map[string]*bool{"dry_run": &dryRun, "restrict": &restrict} {
+		if s := c.Query(param); s != "" {
+			parsed, err := strconv.ParseBool(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be true or false"})
+				return false, false, false
+			}
+			*value = parsed
+		}
+	}
+	return dryRun, restrict, true
+}
+
+// errDependentsUnsupported is returned for dry_run and restrict when the
+// store can't find an item's dependents.
+var errDependentsUnsupported = errors.New("dry_run and restrict are not supported by this storage")
+
+// errKeepItem rolls back a delete the modes say not to go ahead with.
+var errKeepItem = errors.New("delete not applied")
+
+// deleteWithDependents deletes an item with the delete modes applied.
+// Finding its dependents and deleting run in one unit of work, so none
+// appear in between. A dry run responds with the dependents, and restrict
+// refuses with 409 while there are any. It writes the response for every
+// outcome but a delete, and returns true when the item was deleted.
+func deleteWithDependents(c *gin.Context, store storage.Storage, dryRun, restrict bool, notFound string,
+	getDependents func(tx storage.DependentsStorage) (*models.Dependents, error), del func(tx storage.Storage) error) bool {
+	var deps *models.Dependents
+	err := store.WithTx(func(tx storage.Storage) error {
+		if dryRun || restrict {
+			depsStore, ok := tx.(storage.DependentsStorage)
+			if !ok {
+				return errDependentsUnsupported
+			}
+			var err error
+			if deps, err = getDependents(depsStore); err != nil {
+				return err
+			}
+			if dryRun || !deps.Empty() {
+				return errKeepItem
+			}
+		}
+		return del(tx)
+	})
+
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errKeepItem) && dryRun:
+		c.JSON(http.StatusOK, deps)
+	case errors.Is(err, errKeepItem):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete while dependents exist", "dependents": deps})
+	case errors.Is(err, errDependentsUnsupported):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+func TestDeletePersona_Modes(t *testing.T) {
+	store := newSQLiteStore(t)
+	persona := createPersona(t, store, "Developer")
+	template, err := store.CreateTemplate(&models.PromptTemplate{
+		PersonaID: persona.ID,
+		Template:  "Review this {{language}} code",
+		Variables: []string{"language"},
+		ProfileID: DefaultProfileID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	h := &Handler{}
+
+	del := func(id uuid.UUID, query string) *httptest.ResponseRecorder {
+		target := "/personas/" + id.String() + query
+		return serve(store, h.DeletePersona, http.MethodDelete, "/personas/:id", target, nil)
+	}
+	exists := func() bool {
+		_, err := store.GetPersonaByID(persona.ID)
+		return err == nil
+	}
+
+	// A dry run reports the dependents and deletes nothing
+	w := del(persona.ID, "?dry_run=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a dry run, got %d: %s", w.Code, w.Body)
+	}
+	var deps models.Dependents
+	if err := json.Unmarshal(w.Body.Bytes(), &deps); err != nil {
+		t.Fatalf("Failed to decode dependents: %v", err)
+	}
+	if deps.TemplateCount != 1 || len(deps.Templates) != 1 || deps.Templates[0].ID != template.ID {
+		t.Errorf("Expected the template as a dependent, got %+v", deps)
+	}
+	if !exists() {
+		t.Error("Expected a dry run to keep the persona")
+	}
+
+	// Restrict refuses while there are dependents
+	w = del(persona.ID, "?restrict=true")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 with dependents, got %d: %s", w.Code, w.Body)
+	}
+	var conflict struct {
+		Dependents models.Dependents `json:"dependents"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &conflict); err != nil {
+		t.Fatalf("Failed to decode conflict: %v", err)
+	}
+	if conflict.Dependents.TemplateCount != 1 {
+		t.Errorf("Expected the conflict to list the template, got %+v", conflict.Dependents)
+	}
+	if !exists() {
+		t.Error("Expected a refused delete to keep the persona")
+	}
+
+	if w := del(persona.ID, "?dry_run=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid dry_run, got %d: %s", w.Code, w.Body)
+	}
+	if w := del(uuid.New(), "?restrict=true"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown persona, got %d: %s", w.Code, w.Body)
+	}
+
+	// Without restrict the dependents go too
+	if w := del(persona.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if exists() {
+		t.Error("Expected the persona to be deleted")
+	}
+	if _, err := store.GetTemplateByID(template.ID); err == nil {
+		t.Error("Expected the template to be deleted with the persona")
+	}
+
+	// Restrict goes ahead when nothing depends on the persona
+	lone := createPersona(t, store, "Tester")
+	if w := del(lone.ID, "?restrict=true"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 without dependents, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestDeletePersona_ModesUnsupported(t *testing.T) {
+	store := newJSONStore(t)
+	persona, err := store.CreatePersona(&models.Persona{UserRoleDisplay: "Developer", LLMRoleDisplay: "Reviewer"})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	h := &Handler{}
+
+	for _, query := range []string{"?dry_run=true", "?restrict=true"} {
+		target := "/personas/" + persona.ID.String() + query
+		w := serve(store, h.DeletePersona, http.MethodDelete, "/personas/:id", target, nil)
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("%s: expected 501, got %d: %s", query, w.Code, w.Body)
+		}
+	}
+	if _, err := store.GetPersonaByID(persona.ID); err != nil {
+		t.Errorf("Expected the persona to be kept: %v", err)
+	}
+}
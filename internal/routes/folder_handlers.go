@@ -0,0 +1,284 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// UpdateFolderRequest renames or moves a folder. Fields left out are
+// unchanged; an empty ParentID moves the folder to the top level.
+type UpdateFolderRequest struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+// MoveRequest moves a prompt or template into a folder. A null or missing
+// FolderID takes it out of any folder.
+type MoveRequest struct {
+	FolderID *uuid.UUID `json:"folder_id"`
+}
+
+// errFoldersUnsupported is returned for storage that doesn't keep folders.
+var errFoldersUnsupported = errors.New("folders are not supported by this storage")
+
+// requestFolderStore returns the request's store as folder storage. When there is
+// none it writes the error response and returns false.
+func requestFolderStore(c *gin.Context) (storage.FolderStorage, bool) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return nil, false
+	}
+	folderStore, ok := store.(storage.FolderStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errFoldersUnsupported.Error()})
+		return nil, false
+	}
+	return folderStore, true
+}
+
+// checkFolder writes a 400 response and returns false when id names a
+// folder that doesn't exist. A nil id is always fine.
+func checkFolder(c *gin.Context, folderStore storage.FolderStorage, id *uuid.UUID) bool {
+	if id == nil {
+		return true
+	}
+	if _, err := folderStore.GetFolder(*id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found: " + id.String()})
+		return false
+	}
+	return true
+}
+
+// GetFolders handles GET /folders. Folders are returned as a flat list;
+// parent_id links them into a tree.
+func (h *Handler) GetFolders(c *gin.Context) {
+	folderStore, ok := requestFolderStore(c)
+	if !ok {
+		return
+	}
+
+	folders, err := folderStore.GetAllFolders(queryProfileID(c, folderStore))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, folders)
+}
+
+// GetFolder handles GET /folders/:id
+func (h *Handler) GetFolder(c *gin.Context) {
+	folderStore, ok := requestFolderStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID format"})
+		return
+	}
+
+	folder, err := folderStore.GetFolder(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// CreateFolder handles POST /folders
+func (h *Handler) CreateFolder(c *gin.Context) {
+	folderStore, ok := requestFolderStore(c)
+	if !ok {
+		return
+	}
+
+	var folder models.Folder
+	if err := c.ShouldBindJSON(&folder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if folder.Name = strings.TrimSpace(folder.Name); folder.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Folder name is required"})
+		return
+	}
+	if !checkFolder(c, folderStore, folder.ParentID) {
+		return
+	}
+	folder.ProfileID = defaultProfileID(folderStore, folder.ProfileID)
+
+	if err := folderStore.CreateFolder(&folder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// UpdateFolder handles PUT /folders/:id
+func (h *Handler) UpdateFolder(c *gin.Context) {
+	folderStore, ok := requestFolderStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID format"})
+		return
+	}
+
+	folder, err := folderStore.GetFolder(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	var req UpdateFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != nil {
+		if folder.Name = strings.TrimSpace(*req.Name); folder.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Folder name is required"})
+			return
+		}
+	}
+	if req.ParentID != nil {
+		folder.ParentID = nil
+		if *req.ParentID != "" {
+			parentID, err := uuid.Parse(*req.ParentID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent_id format"})
+				return
+			}
+			if !checkFolder(c, folderStore, &parentID) {
+				return
+			}
+			folder.ParentID = &parentID
+		}
+	}
+
+	if err := folderStore.UpdateFolder(folder); err != nil {
+		if errors.Is(err, models.ErrFolderCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder handles DELETE /folders/:id. Its subfolders are deleted too;
+// the prompts and templates they held are kept, outside any folder.
+func (h *Handler) DeleteFolder(c *gin.Context) {
+	folderStore, ok := requestFolderStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID format"})
+		return
+	}
+
+	if err := folderStore.DeleteFolder(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
+
+// MovePrompt handles PUT /prompts/:id/folder
+func (h *Handler) MovePrompt(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	folderStore, ok := store.(storage.FolderStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errFoldersUnsupported.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt ID format"})
+		return
+	}
+
+	var req MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkFolder(c, folderStore, req.FolderID) {
+		return
+	}
+
+	if err := folderStore.MovePrompt(id, req.FolderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return
+	}
+
+	prompt, err := store.(storage.Storage).GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+// MoveTemplate handles PUT /templates/:id/folder. Every version of the
+// template moves, and the latest is returned.
+func (h *Handler) MoveTemplate(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	folderStore, ok := store.(storage.FolderStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errFoldersUnsupported.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return
+	}
+
+	var req MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkFolder(c, folderStore, req.FolderID) {
+		return
+	}
+
+	if err := folderStore.MoveTemplate(id, req.FolderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	template, err := store.(storage.Storage).GetTemplateByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
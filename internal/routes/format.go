@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/render"
+)
+
+// FormattedPrompt is a prompt together with its content split into chat
+// messages for Format.
+type FormattedPrompt struct {
+	*models.Prompt
+	Format   render.Format `json:"format"`
+	Messages interface{}   `json:"messages"`
+}
+
+// formatPrompt returns prompt as is for render.FormatText, and otherwise
+// with its content as chat messages assembled in order. Sections are
+// recovered from the content when not given.
+func formatPrompt(prompt *models.Prompt, sections render.Sections, format render.Format, order []string) (interface{}, error) {
+	if format == render.FormatText {
+		return prompt, nil
+	}
+	if sections == nil {
+		sections = render.SplitSections(prompt.Content)
+	}
+	messages, err := render.Messages(sections, order, format)
+	if err != nil {
+		return nil, err
+	}
+	return FormattedPrompt{Prompt: prompt, Format: format, Messages: messages}, nil
+}
@@ -1,45 +1,27 @@
 package routes
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"regexp"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rahulguha/promptly/internal/api"
 	"github.com/rahulguha/promptly/internal/config"
+	"github.com/rahulguha/promptly/internal/intent"
 	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/render"
 	"github.com/rahulguha/promptly/internal/storage"
 )
 
-func extractVariables(text string) []string {
-	re := regexp.MustCompile(`\{\{([a-zA-Z0-9_]+)\}\}`)
-
-	matches := re.FindAllStringSubmatch(text, -1)
-
-	vars := make(map[string]bool)
-	for _, match := range matches {
-		vars[match[1]] = true
-	}
-
-
-uniqueVars := make([]string, 0, len(vars))
-	for v := range vars {
-	
-uniqueVars = append(uniqueVars, v)
-	}
-	return uniqueVars
-}
-
 // Handler contains the dependencies for HTTP handlers
 type Handler struct {
 	DBManager         *storage.DBManager
 	Cfg               *config.Config
 	UserTrackingHandler *api.UserTrackingHandler
+	// Intents is the intent master file built-in intents are seeded from
+	Intents           *intent.Master
 }
 
 // GetPrompts handles GET /prompts
@@ -49,13 +31,13 @@ func (h *Handler) GetPrompts(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
 		return
 	}
-	profileID := c.Query("profile_id")
-	prompts, err := store.(storage.Storage).GetAll(profileID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	opts, ok := listOptions(c, models.SortName, models.SortCreatedAt, models.SortUpdatedAt, models.SortMostUsed, models.SortRecent, models.SortStarred)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, prompts)
+	opts.ProfileID = queryProfileID(c, store)
+	prompts, page, err := store.(storage.Storage).ListPrompts(opts)
+	writeList(c, prompts, page, err)
 }
 
 // GetPrompt handles GET /prompts/:id
@@ -72,13 +54,25 @@ func (h *Handler) GetPrompt(c *gin.Context) {
 		return
 	}
 
+	format, err := render.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	prompt, err := store.(storage.Storage).GetByID(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, prompt)
+	formatted, err := formatPrompt(prompt, nil, format, h.Cfg.SectionOrder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, formatted)
 }
 
 // CreatePrompt handles POST /prompts
@@ -94,9 +88,7 @@ func (h *Handler) CreatePrompt(c *gin.Context) {
 		return
 	}
 
-	if prompt.ProfileID == "" {
-		prompt.ProfileID = DefaultProfileID
-	}
+	prompt.ProfileID = defaultProfileID(store, prompt.ProfileID)
 
 	createdPrompt, err := store.(storage.Storage).Create(&prompt)
 	if err != nil {
@@ -170,18 +162,13 @@ func (h *Handler) GetTemplates(c *gin.Context) {
 		return
 	}
 
-	
-
-	profileID := c.Query("profile_id")
-	templates, err := store.(storage.Storage).GetAllTemplates(profileID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	opts, ok := listOptions(c, models.SortName, models.SortCreatedAt, models.SortUpdatedAt, models.SortVersion, models.SortMostUsed, models.SortRecent, models.SortStarred)
+	if !ok {
 		return
 	}
-
-	
-
-	c.JSON(http.StatusOK, templates)
+	opts.ProfileID = queryProfileID(c, store)
+	templates, page, err := store.(storage.Storage).ListTemplates(opts)
+	writeList(c, templates, page, err)
 }
 
 
@@ -208,37 +195,60 @@ func (h *Handler) GetTemplate(c *gin.Context) {
 
 	c.JSON(http.StatusOK, template)
 }
-// BuildMetaPrompt constructs a meta prompt given user and LLM roles
-func BuildMetaPrompt(userRole, llmRole string) string {
-    return fmt.Sprintf(`
-I am a %s.
-You are a %s. 
-Please respond clearly, in a way that fits my background as a %s, 
-while staying in your role as a %s.
-`, userRole, llmRole, userRole, llmRole)
-}
+// prepareTemplate fills in the fields derived from a template's persona, task
+// and answer guideline, assembling its sections in order, and checks its
+// intent and variable schema.
+func prepareTemplate(store storage.Storage, template *models.PromptTemplate, order []string) error {
+	// Get persona to populate display roles
+	persona, err := store.GetPersonaByID(template.PersonaID)
+	if err != nil {
+		return errors.New("invalid persona_id: persona not found")
+	}
 
-func buildTemplate(metaRole, task, answerGuideline string) string {
-	var sb strings.Builder
+	if err := checkIntent(store, template.Intent); err != nil {
+		return err
+	}
 
-	if metaRole != "" {
-		sb.WriteString("[Meta Role]\n")
-		sb.WriteString(metaRole)
-		sb.WriteString("\n\n")
+	// Each section must be a complete template on its own
+	sections := []string{template.Task, template.AnswerGuideline}
+	if template.ManualMetaRole {
+		sections = append(sections, template.MetaRole)
+	}
+	for _, section := range sections {
+		if _, err := render.Parse(section); err != nil {
+			return err
+		}
 	}
 
-	if task != "" {
-		sb.WriteString("[Task]\n")
-		sb.WriteString(task)
-		sb.WriteString("\n\n")
+	if !template.ManualMetaRole {
+		template.MetaRole, err = render.BuildMetaRole(metaRoleContext(store, persona, template.ProfileID))
+		if err != nil {
+			return fmt.Errorf("invalid meta role template: %w", err)
+		}
 	}
 
-	if answerGuideline != "" {
-		sb.WriteString("[Answer Guideline]\n")
-		sb.WriteString(answerGuideline)
+	template.Template = render.Assemble(render.TemplateSections(template), order)
+
+	// Variables from the task come first, then any new ones from the answer
+	// guideline and a hand-written meta role
+	source, err := render.Parse(render.TemplateSource(template))
+	if err != nil {
+		return err
 	}
+	template.Variables = source.Variables()
 
-	return sb.String()
+	return render.ValidateSchema(source, template.VariableSchema)
+}
+
+// metaRoleContext gathers what a persona's meta role can refer to: the
+// template's profile (or else the persona's) and that profile's intent.
+// Anything that can't be found is left out.
+func metaRoleContext(store storage.Storage, persona *models.Persona, profileID string) render.MetaRoleContext {
+	if profileID == "" || profileID == DefaultProfileID {
+		profileID = persona.ProfileID
+	}
+	profile := lookupProfile(store, profileID)
+	return render.MetaRoleContext{Persona: persona, Profile: profile, Intent: profileIntent(store, profile)}
 }
 
 // CreateTemplate handles POST /templates
@@ -254,43 +264,13 @@ func (h *Handler) CreateTemplate(c *gin.Context) {
 		return
 	}
 
-	if template.ProfileID == "" {
-		template.ProfileID = DefaultProfileID
-	}
+	template.ProfileID = defaultProfileID(store, template.ProfileID)
 
-	// Get persona to populate display roles
-	persona, err := store.(storage.Storage).GetPersonaByID(template.PersonaID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid persona_id: persona not found"})
+	if err := prepareTemplate(store.(storage.Storage), &template, h.Cfg.SectionOrder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Prepend persona context with actual values
-	metaRole := BuildMetaPrompt(persona.UserRoleDisplay, persona.LLMRoleDisplay)
-	template.MetaRole = metaRole
-
-	template.Template = buildTemplate(template.MetaRole, template.Task, template.AnswerGuideline)
-
-	// Extract variables from task and answer guideline
-	taskVars := extractVariables(template.Task)
-	guidelineVars := extractVariables(template.AnswerGuideline)
-
-	// Combine and get unique variables
-	allVars := make(map[string]bool)
-	for _, v := range taskVars {
-		allVars[v] = true
-	}
-	for _, v := range guidelineVars {
-		allVars[v] = true
-	}
-
-	uniqueVars := make([]string, 0, len(allVars))
-	for v := range allVars {
-	
-uniqueVars = append(uniqueVars, v)
-	}
-	template.Variables = uniqueVars
-
 	createdTemplate, err := store.(storage.Storage).CreateTemplate(&template)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -323,39 +303,11 @@ func (h *Handler) UpdateTemplate(c *gin.Context) {
 
 	template.ID = id
 
-	// Get persona to populate display roles
-	persona, err := store.(storage.Storage).GetPersonaByID(template.PersonaID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid persona_id: persona not found"})
+	if err := prepareTemplate(store.(storage.Storage), &template, h.Cfg.SectionOrder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Prepend persona context with actual values
-	metaRole := BuildMetaPrompt(persona.UserRoleDisplay, persona.LLMRoleDisplay)
-	template.MetaRole = metaRole
-
-	template.Template = buildTemplate(template.MetaRole, template.Task, template.AnswerGuideline)
-
-	// Extract variables from task and answer guideline
-	taskVars := extractVariables(template.Task)
-	guidelineVars := extractVariables(template.AnswerGuideline)
-
-	// Combine and get unique variables
-	allVars := make(map[string]bool)
-	for _, v := range taskVars {
-		allVars[v] = true
-	}
-	for _, v := range guidelineVars {
-		allVars[v] = true
-	}
-
-	uniqueVars := make([]string, 0, len(allVars))
-	for v := range allVars {
-	
-uniqueVars = append(uniqueVars, v)
-	}
-	template.Variables = uniqueVars
-
 	updatedTemplate, err := store.(storage.Storage).UpdateTemplate(&template)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -387,39 +339,11 @@ func (h *Handler) CreateTemplateVersion(c *gin.Context) {
 
 	template.ID = id
 
-	// Get persona to populate display roles
-	persona, err := store.(storage.Storage).GetPersonaByID(template.PersonaID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid persona_id: persona not found"})
+	if err := prepareTemplate(store.(storage.Storage), &template, h.Cfg.SectionOrder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Prepend persona context with actual values
-	metaRole := BuildMetaPrompt(persona.UserRoleDisplay, persona.LLMRoleDisplay)
-	template.MetaRole = metaRole
-
-	template.Template = buildTemplate(template.MetaRole, template.Task, template.AnswerGuideline)
-
-	// Extract variables from task and answer guideline
-	taskVars := extractVariables(template.Task)
-	guidelineVars := extractVariables(template.AnswerGuideline)
-
-	// Combine and get unique variables
-	allVars := make(map[string]bool)
-	for _, v := range taskVars {
-		allVars[v] = true
-	}
-	for _, v := range guidelineVars {
-		allVars[v] = true
-	}
-
-	uniqueVars := make([]string, 0, len(allVars))
-	for v := range allVars {
-	
-uniqueVars = append(uniqueVars, v)
-	}
-	template.Variables = uniqueVars
-
 	newVersion, err := store.(storage.Storage).CreateTemplateVersion(&template)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -455,23 +379,31 @@ func (h *Handler) DeleteTemplate(c *gin.Context) {
 		return
 	}
 
-	err = store.(storage.Storage).DeleteTemplate(id, version)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	dryRun, restrict, ok := deleteOptions(c)
+	if !ok {
+		return
+	}
+	if !deleteWithDependents(c, store.(storage.Storage), dryRun, restrict, "Template version not found",
+		func(tx storage.DependentsStorage) (*models.Dependents, error) { return tx.GetTemplateDependents(id, version) },
+		func(tx storage.Storage) error { return tx.DeleteTemplate(id, version) }) {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
 }
 
-// GeneratePromptRequest represents the request for generating a prompt
+// GeneratePromptRequest represents the request for generating a prompt.
+// A TemplateVersion of 0 renders the latest version of the template, and an
+// empty Intent uses the template's intent. Format selects how the prompt is
+// returned; it is always saved as text.
 type GeneratePromptRequest struct {
 	Name            string            `json:"name"`
-	Content         string            `json:"content" binding:"required"`
-	TemplateID      uuid.UUID         `json:"template_id"`
+	TemplateID      uuid.UUID         `json:"template_id" binding:"required"`
 	TemplateVersion int               `json:"template_version"`
 	Values          map[string]string `json:"variable_values"`
 	ProfileID       string            `json:"profile_id"`
+	Intent          string            `json:"intent"`
+	Format          string            `json:"format"`
 }
 
 // GeneratePrompt handles POST /generate-prompt
@@ -487,56 +419,97 @@ func (h *Handler) GeneratePrompt(c *gin.Context) {
 		return
 	}
 
-	// The logic for generating a prompt from a template is now commented out.
-	// The client is expected to send the complete prompt content.
-	/*
-		// Get the template
-		template, err := store.(storage.Storage).GetTemplateByID(req.TemplateID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
-			return
-		}
+	var template *models.PromptTemplate
+	var err error
+	if req.TemplateVersion > 0 {
+		template, err = store.(storage.Storage).GetTemplateVersion(req.TemplateID, req.TemplateVersion)
+	} else {
+		template, err = store.(storage.Storage).GetTemplateByID(req.TemplateID)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
 
-		// Extract variables directly from the template content to ensure they are always up-to-date
-		templateVars := extractVariables(template.Template)
+	if err := checkIntent(store, req.Intent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, err := render.ParseFormat(req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		// Create filtered values map - only include variables that exist in the template
-		filteredValues := make(map[string]string)
-		for _, variable := range templateVars {
-			if value, exists := req.Values[variable]; exists {
-				filteredValues[variable] = value
-			}
-		}
+	sections, err := render.RenderSections(template, req.Values)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
 
-		// Generate the final prompt content by substituting variables
-		content := template.Template
-		for variable, value := range filteredValues {
-			placeholder := "{{" + variable + "}}"
-			content = strings.ReplaceAll(content, placeholder, value)
-		}
-	*/
+	req.ProfileID = defaultProfileID(store, req.ProfileID)
+
+	// Personalize the prompt with its intent and the compiled profile
+	intentName := req.Intent
+	if intentName == "" {
+		intentName = template.Intent
+	}
+	content, err := assemblePrompt(store.(storage.Storage), sections, req.ProfileID, intentName, h.Cfg.ProfileLayout, h.Cfg.SectionOrder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Create new prompt with rendered content
 	prompt := &models.Prompt{
 		Name:            req.Name,
-		Content:         req.Content,
-		TemplateID:      req.TemplateID,
-		TemplateVersion: req.TemplateVersion,
+		Content:         content,
+		TemplateID:      template.ID,
+		TemplateVersion: template.Version,
 		Values:          req.Values,
 		ProfileID:       req.ProfileID,
+		Intent:          req.Intent,
 	}
 
-	createdPrompt, err := store.(storage.Storage).Create(prompt)
+	// The prompt and the template's render are recorded together
+	var createdPrompt *models.Prompt
+	err = store.(storage.Storage).WithTx(func(tx storage.Storage) error {
+		var err error
+		if createdPrompt, err = tx.Create(prompt); err != nil {
+			return err
+		}
+		recordUsage(tx, models.UsageTemplate, template.ID, models.UsageRender)
+		return nil
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, createdPrompt)
-}
-
+	formatted, err := formatPrompt(createdPrompt, sections, format, h.Cfg.SectionOrder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	c.JSON(http.StatusCreated, formatted)
+}
 
+// renderError writes a rendering failure, including the offending variables
+// when the values didn't match the template.
+func renderError(c *gin.Context, err error) {
+	var verr *render.ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             verr.Error(),
+			"missing_variables": verr.Missing,
+			"unknown_variables": verr.Unknown,
+			"invalid_variables": verr.Invalid,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
 
 // Persona handlers
 
@@ -559,17 +532,13 @@ func (h *Handler) GetPersonas(c *gin.Context) {
 	// }
 	// fmt.Println("-----------------------------")
 
-	profileID := c.Query("profile_id")
-	personas, err := store.(storage.Storage).GetAllPersonas(profileID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	opts, ok := listOptions(c, models.SortName, models.SortCreatedAt, models.SortUpdatedAt)
+	if !ok {
 		return
 	}
-
-	
-	
-
-	c.JSON(http.StatusOK, personas)
+	opts.ProfileID = queryProfileID(c, store)
+	personas, page, err := store.(storage.Storage).ListPersonas(opts)
+	writeList(c, personas, page, err)
 }
 
 
@@ -612,8 +581,11 @@ func (h *Handler) CreatePersona(c *gin.Context) {
 		return
 	}
 
-	if persona.ProfileID == "" {
-		persona.ProfileID = DefaultProfileID
+	persona.ProfileID = defaultProfileID(store, persona.ProfileID)
+
+	if err := render.ValidateMetaRoleTemplate(persona.MetaRoleTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	createdPersona, err := store.(storage.Storage).CreatePersona(&persona)
@@ -646,6 +618,12 @@ func (h *Handler) UpdatePersona(c *gin.Context) {
 	}
 
 	persona.ID = id
+
+	if err := render.ValidateMetaRoleTemplate(persona.MetaRoleTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	updatedPersona, err := store.(storage.Storage).UpdatePersona(&persona)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -669,30 +647,15 @@ func (h *Handler) DeletePersona(c *gin.Context) {
 		return
 	}
 
-	err = store.(storage.Storage).DeletePersona(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	dryRun, restrict, ok := deleteOptions(c)
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Persona deleted successfully"})
-}
-
-// GetIntents handles GET /intents
-func (h *Handler) GetIntents(c *gin.Context) {
-	// Assuming intent_master.json is in the same directory as the executable.
-	// For a more robust solution, you might want to pass the path via config.
-	intentsFile, err := os.ReadFile("intent_master.json")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not read intents file"})
+	if !deleteWithDependents(c, store.(storage.Storage), dryRun, restrict, "Persona not found",
+		func(tx storage.DependentsStorage) (*models.Dependents, error) { return tx.GetPersonaDependents(id) },
+		func(tx storage.Storage) error { return tx.DeletePersona(id) }) {
 		return
 	}
 
-	var intents []models.Intent
-	if err := json.Unmarshal(intentsFile, &intents); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not parse intents file"})
-		return
-	}
-
-	c.JSON(http.StatusOK, intents)
+	c.JSON(http.StatusOK, gin.H{"message": "Persona deleted successfully"})
 }
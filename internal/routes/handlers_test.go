@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage/jsonstore"
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
+)
+
+// newSQLiteStore returns a store backed by a new database file.
+func newSQLiteStore(t *testing.T) *sqlite.SQLiteStorage {
+	t.Helper()
+	store, err := sqlite.NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// newJSONStore returns a store backed by new JSON files. It supports none
+// of the optional storage interfaces.
+func newJSONStore(t *testing.T) *jsonstore.FileStorage {
+	t.Helper()
+	store, err := jsonstore.NewFileStorage(filepath.Join(t.TempDir(), "prompts.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return store
+}
+
+// serve runs a request to target through handler, registered at route, with
+// store as the request's store the way DBMiddleware sets it.
+func serve(store interface{}, handler gin.HandlerFunc, method, route, target string, body io.Reader) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, func(c *gin.Context) {
+		c.Set("store", store)
+	}, handler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, body))
+	return w
+}
+
+// createPersona creates a persona in the default profile.
+func createPersona(t *testing.T, store *sqlite.SQLiteStorage, userRole string) *models.Persona {
+	t.Helper()
+	persona, err := store.CreatePersona(&models.Persona{
+		UserRoleDisplay: userRole,
+		LLMRoleDisplay:  "Reviewer",
+		ProfileID:       DefaultProfileID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	return persona
+}
+
+func TestGetPersonas_Pagination(t *testing.T) {
+	store := newSQLiteStore(t)
+	for _, role := range []string{"Alice", "Bob", "Carol"} {
+		createPersona(t, store, role)
+	}
+	h := &Handler{}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		return serve(store, h.GetPersonas, http.MethodGet, "/personas", "/personas"+query, nil)
+	}
+
+	w := list("?sort=name&limit=2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var page []models.Persona
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode personas: %v", err)
+	}
+	if len(page) != 2 || page[0].UserRoleDisplay != "Alice" || page[1].UserRoleDisplay != "Bob" {
+		t.Errorf("Expected Alice and Bob, got %+v", page)
+	}
+	if total := w.Header().Get(headerTotalCount); total != "3" {
+		t.Errorf("Expected a total of 3, got %q", total)
+	}
+	cursor := w.Header().Get(headerNextCursor)
+	if cursor == "" {
+		t.Fatal("Expected a next cursor")
+	}
+
+	w = list("?sort=name&limit=2&cursor=" + cursor)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode personas: %v", err)
+	}
+	if len(page) != 1 || page[0].UserRoleDisplay != "Carol" {
+		t.Errorf("Expected Carol, got %+v", page)
+	}
+	if next := w.Header().Get(headerNextCursor); next != "" {
+		t.Errorf("Expected no next cursor on the last page, got %q", next)
+	}
+
+	// A cursor is only good for the sort it came from
+	for _, query := range []string{
+		"?cursor=not-a-cursor",
+		"?sort=created_at&cursor=" + cursor,
+		"?limit=0",
+		"?limit=1001",
+		"?sort=size",
+		"?order=up",
+	} {
+		if w := list(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", query, w.Code, w.Body)
+		}
+	}
+}
+
+func TestHandlers_UnsupportedStorage(t *testing.T) {
+	store := newJSONStore(t)
+	h := &Handler{}
+
+	tests := []struct {
+		name          string
+		handler       gin.HandlerFunc
+		route, target string
+	}{
+		{"intents", h.GetIntents, "/intents", "/intents"},
+		{"tags", h.GetTags, "/tags", "/tags"},
+		{"folders", h.GetFolders, "/folders", "/folders"},
+		{"trash", h.GetTrash, "/trash", "/trash"},
+		{"search", h.Search, "/search", "/search?q=review"},
+		{"usage", h.GetUsage(models.UsagePrompt), "/prompts/:id/usage", "/prompts/" + uuid.NewString() + "/usage"},
+		{"active profile", (&ProfileHandler{}).GetActiveProfile, "/profiles/active", "/profiles/active"},
+	}
+	for _, tt := range tests {
+		w := serve(store, tt.handler, http.MethodGet, tt.route, tt.target, nil)
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("%s: expected 501, got %d: %s", tt.name, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rahulguha/promptly/internal/intent"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// UpdateIntentRequest changes an intent. Fields left out are unchanged.
+// Built-in intents only accept SystemPrompt and Keywords.
+type UpdateIntentRequest struct {
+	Name         *string   `json:"name"`
+	Description  *string   `json:"description"`
+	SystemPrompt *string   `json:"system_prompt"`
+	Keywords     *[]string `json:"keywords"`
+	Tag          *string   `json:"tag"`
+}
+
+// errIntentsUnsupported is returned for storage that doesn't keep intents.
+var errIntentsUnsupported = errors.New("intents are not supported by this storage")
+
+// requestIntentStore returns the request's store as intent storage. When
+// there is none it writes the error response and returns false.
+func requestIntentStore(c *gin.Context) (storage.IntentStorage, bool) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return nil, false
+	}
+	intentStore, ok := store.(storage.IntentStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errIntentsUnsupported.Error()})
+		return nil, false
+	}
+	return intentStore, true
+}
+
+// GetIntents handles GET /intents
+func (h *Handler) GetIntents(c *gin.Context) {
+	intentStore, ok := requestIntentStore(c)
+	if !ok {
+		return
+	}
+
+	intents, err := intentStore.GetAllIntents()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if intents == nil {
+		intents = []*models.Intent{}
+	}
+
+	c.JSON(http.StatusOK, intents)
+}
+
+// GetIntent handles GET /intents/:intent
+func (h *Handler) GetIntent(c *gin.Context) {
+	intentStore, ok := requestIntentStore(c)
+	if !ok {
+		return
+	}
+
+	found, err := intentStore.GetIntent(c.Param("intent"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intent not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, found)
+}
+
+// CreateIntent handles POST /intents. New intents are always custom.
+func (h *Handler) CreateIntent(c *gin.Context) {
+	intentStore, ok := requestIntentStore(c)
+	if !ok {
+		return
+	}
+
+	var newIntent models.Intent
+	if err := c.ShouldBindJSON(&newIntent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	newIntent.Custom = true
+	newIntent.Overridden = false
+	if err := intent.Validate(&newIntent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := intentStore.GetIntent(newIntent.Intent); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Intent already exists"})
+		return
+	}
+
+	if err := intentStore.CreateIntent(&newIntent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newIntent)
+}
+
+// UpdateIntent handles PUT /intents/:intent
+func (h *Handler) UpdateIntent(c *gin.Context) {
+	intentStore, ok := requestIntentStore(c)
+	if !ok {
+		return
+	}
+
+	existing, err := intentStore.GetIntent(c.Param("intent"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intent not found"})
+		return
+	}
+
+	var req UpdateIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !existing.Custom {
+		if req.Name != nil || req.Description != nil || req.Tag != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only system_prompt and keywords can be changed on built-in intents"})
+			return
+		}
+		existing.Overridden = true
+	}
+	if req.Name != nil {
+		existing.Name = *req.Name
+	}
+	if req.Description != nil {
+		existing.Description = *req.Description
+	}
+	if req.SystemPrompt != nil {
+		existing.SystemPrompt = *req.SystemPrompt
+	}
+	if req.Keywords != nil {
+		existing.Keywords = *req.Keywords
+	}
+	if req.Tag != nil {
+		existing.Tag = *req.Tag
+	}
+	if err := intent.Validate(existing); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := intentStore.UpdateIntent(existing); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, existing)
+}
+
+// DeleteIntent handles DELETE /intents/:intent. Only custom intents can be
+// deleted; built-in ones can be reset instead.
+func (h *Handler) DeleteIntent(c *gin.Context) {
+	intentStore, ok := requestIntentStore(c)
+	if !ok {
+		return
+	}
+
+	existing, err := intentStore.GetIntent(c.Param("intent"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intent not found"})
+		return
+	}
+	if !existing.Custom {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in intents can't be deleted; reset them instead"})
+		return
+	}
+
+	if err := intentStore.DeleteIntent(existing.Intent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Intent deleted successfully"})
+}
+
+// ResetIntent handles POST /intents/:intent/reset. It discards the user's
+// overrides of a built-in intent.
+func (h *Handler) ResetIntent(c *gin.Context) {
+	intentStore, ok := requestIntentStore(c)
+	if !ok {
+		return
+	}
+
+	existing, err := intentStore.GetIntent(c.Param("intent"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intent not found"})
+		return
+	}
+	if existing.Custom {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Custom intents have nothing to reset to"})
+		return
+	}
+
+	if h.Intents == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Intent master file not configured"})
+		return
+	}
+	original, err := h.Intents.Find(existing.Intent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if original == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intent is no longer in the intent master file"})
+		return
+	}
+
+	if err := intentStore.UpdateIntent(original); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, original)
+}
+
+// defaultClassifyLimit is how many intents ClassifyIntents returns by default.
+const defaultClassifyLimit = 5
+
+// ClassifyIntentsRequest is the text to suggest intents for, such as a task
+// or a draft prompt.
+type ClassifyIntentsRequest struct {
+	Text  string `json:"text" binding:"required"`
+	Limit int    `json:"limit"`
+}
+
+// ClassifyIntents handles POST /intents/classify. It ranks the user's
+// intents, custom ones included, by how well they fit the text.
+func (h *Handler) ClassifyIntents(c *gin.Context) {
+	intentStore, ok := requestIntentStore(c)
+	if !ok {
+		return
+	}
+
+	var req ClassifyIntentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must not be negative"})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultClassifyLimit
+	}
+
+	intents, err := intentStore.GetAllIntents()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	matches := intent.Classify(req.Text, intents)
+	if len(matches) > req.Limit {
+		matches = matches[:req.Limit]
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// maxListLimit is the largest page a list request can ask for.
+const maxListLimit = 1000
+
+// Response headers carrying list metadata, so list bodies stay plain arrays
+const (
+	headerTotalCount = "X-Total-Count"
+	headerNextCursor = "X-Next-Cursor"
+)
+
+// listOptions reads the paging, sorting and filtering parameters of a list
+// request, accepting the given sort fields. It writes a 400 response and
+// returns false when a parameter is invalid.
+func listOptions(c *gin.Context, sorts ...string) (models.ListOptions, bool) {
+	var opts models.ListOptions
+
+	if opts.Sort = c.Query("sort"); opts.Sort != "" && !contains(sorts, opts.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field: " + opts.Sort})
+		return opts, false
+	}
+	switch c.Query("order") {
+	case "":
+		// Usage sorts put the most used, most recent and starred first
+		opts.Desc = contains([]string{models.SortMostUsed, models.SortRecent, models.SortStarred}, opts.Sort)
+	case "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return opts, false
+	}
+
+	opts.Cursor = c.Query("cursor")
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxListLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxListLimit)})
+			return opts, false
+		}
+		opts.Limit = n
+	}
+
+	ids := map[string]*uuid.UUID{
+		"template_id": &opts.TemplateID,
+		"persona_id":  &opts.PersonaID,
+		"tag_id":      &opts.TagID,
+		"folder_id":   &opts.FolderID,
+	}
+	for param, id := range ids {
+		if value := c.Query(param); value != "" {
+			parsed, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " format"})
+				return opts, false
+			}
+			*id = parsed
+		}
+	}
+
+	for param, t := range map[string]*time.Time{"created_after": &opts.CreatedAfter, "created_before": &opts.CreatedBefore} {
+		if value := c.Query(param); value != "" {
+			parsed, err := parseTime(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time or a YYYY-MM-DD date"})
+				return opts, false
+			}
+			*t = parsed
+		}
+	}
+
+	return opts, true
+}
+
+// parseTime accepts RFC 3339 times and plain dates, which mean midnight UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeList responds with a page of a list, or with the error listing it.
+func writeList(c *gin.Context, items interface{}, page *models.PageInfo, err error) {
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header(headerTotalCount, strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header(headerNextCursor, page.NextCursor)
+	}
+	c.JSON(http.StatusOK, items)
+}
@@ -1,16 +1,21 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rahulguha/promptly/internal/extract"
 	"github.com/rahulguha/promptly/internal/models"
 	"github.com/rahulguha/promptly/internal/storage"
 )
 
 // ProfileHandler is a placeholder for profile-related routes.
 // The actual storage is retrieved from the context in each handler.
-type ProfileHandler struct{}
+type ProfileHandler struct {
+	// Layout compiles profiles into prompt text; empty means the default.
+	Layout string
+}
 
 // GetProfiles handles GET /profiles
 func (h *ProfileHandler) GetProfiles(c *gin.Context) {
@@ -21,12 +26,12 @@ func (h *ProfileHandler) GetProfiles(c *gin.Context) {
 	}
 	profileStore := store.(storage.ProfileStorage)
 
-	profiles, err := profileStore.GetAllProfiles()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	opts, ok := listOptions(c, models.SortName, models.SortCreatedAt, models.SortUpdatedAt)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, profiles)
+	profiles, page, err := profileStore.ListProfiles(opts)
+	writeList(c, profiles, page, err)
 }
 
 // GetProfile handles GET /profiles/:id
@@ -108,10 +113,41 @@ func (h *ProfileHandler) DeleteProfile(c *gin.Context) {
 
 	id := c.Param("id")
 	err := profileStore.DeleteProfile(id)
+	if errors.Is(err, models.ErrProfileInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Profile deleted successfully"})
-}
\ No newline at end of file
+}
+// maxDescriptionLength bounds the text accepted by ParseProfile.
+const maxDescriptionLength = 10000
+
+// ParseProfileRequest is the free text to extract profile attributes from.
+type ParseProfileRequest struct {
+	Description string `json:"description" binding:"required"`
+}
+
+// ParseProfile handles POST /profiles/parse. It proposes attributes for a
+// description without saving anything.
+func (h *ProfileHandler) ParseProfile(c *gin.Context) {
+	var req ParseProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Description) > maxDescriptionLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Description is too long"})
+		return
+	}
+
+	c.JSON(http.StatusOK, extract.Parse(req.Description))
+}
@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/render"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// findIntent returns the user's intent with the given name, or nil if there
+// is none.
+func findIntent(store interface{}, name string) *models.Intent {
+	intents, ok := store.(storage.IntentStorage)
+	if !ok || name == "" {
+		return nil
+	}
+	intent, err := intents.GetIntent(name)
+	if err != nil {
+		return nil
+	}
+	return intent
+}
+
+// lookupProfile returns the profile with the given ID, or nil for the default
+// profile and profiles that can't be found.
+func lookupProfile(store interface{}, profileID string) *models.Profile {
+	profiles, ok := store.(storage.ProfileStorage)
+	if !ok || profileID == "" || profileID == DefaultProfileID {
+		return nil
+	}
+	profile, err := profiles.GetProfileByID(profileID)
+	if err != nil {
+		return nil
+	}
+	return profile
+}
+
+// profileIntent returns the intent named by a profile's attributes.
+func profileIntent(store interface{}, profile *models.Profile) *models.Intent {
+	if profile == nil || profile.Attributes == nil {
+		return nil
+	}
+	return findIntent(store, profile.Attributes.Intent)
+}
+
+// assemblePrompt completes a prompt's rendered sections with the system
+// prompt of its intent and its profile compiled using layout, then assembles
+// them in order. intentName is the prompt's or else its template's intent;
+// when neither has one, the profile's intent is used.
+func assemblePrompt(store storage.Storage, sections render.Sections, profileID, intentName, layout string, order []string) (string, error) {
+	profile := lookupProfile(store, profileID)
+	if profile != nil {
+		text, err := render.CompileProfile(profile, profileIntent(store, profile), layout)
+		if err != nil {
+			return "", err
+		}
+		sections[render.SectionProfile] = text
+	}
+
+	intent := findIntent(store, intentName)
+	if intent == nil {
+		intent = profileIntent(store, profile)
+	}
+	if intent != nil {
+		sections[render.SectionIntent] = intent.SystemPrompt
+	}
+
+	return render.Assemble(sections, order), nil
+}
+
+// checkIntent reports an error when name isn't one of the user's intents.
+func checkIntent(store interface{}, name string) error {
+	if name == "" {
+		return nil
+	}
+	if findIntent(store, name) == nil {
+		return errors.New("invalid intent: intent not found")
+	}
+	return nil
+}
+
+// GetCompiledProfile handles GET /profiles/:id/system-prompt
+func (h *ProfileHandler) GetCompiledProfile(c *gin.Context) {
+	profileStore, ok := requestProfileStore(c)
+	if !ok {
+		return
+	}
+
+	profile, err := profileStore.GetProfileByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
+
+	systemPrompt, err := render.CompileProfile(profile, profileIntent(profileStore, profile), h.Layout)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profile_id": profile.ID, "system_prompt": systemPrompt})
+}
@@ -0,0 +1,273 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/render"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// StalePrompt is a prompt generated from an older version of its template.
+type StalePrompt struct {
+	*models.Prompt
+	LatestVersion int `json:"latest_version"`
+}
+
+// RerenderRequest selects the prompts to regenerate. With PromptIDs only
+// those prompts are considered; otherwise every stale prompt is, narrowed to
+// TemplateID when it is set. TemplateVersion picks the version to render
+// against and requires TemplateID; 0 means the latest version.
+type RerenderRequest struct {
+	PromptIDs       []uuid.UUID `json:"prompt_ids"`
+	TemplateID      uuid.UUID   `json:"template_id"`
+	TemplateVersion int         `json:"template_version"`
+	ProfileID       string      `json:"profile_id"`
+	DryRun          bool        `json:"dry_run"`
+}
+
+// RerenderResult reports what happened to a single prompt.
+type RerenderResult struct {
+	PromptID         uuid.UUID           `json:"prompt_id"`
+	FromVersion      int                 `json:"from_version"`
+	ToVersion        int                 `json:"to_version,omitempty"`
+	Status           string              `json:"status"`
+	Content          string              `json:"content,omitempty"`
+	MissingVariables []string            `json:"missing_variables,omitempty"`
+	UnusedVariables  []string            `json:"unused_variables,omitempty"`
+	InvalidVariables []render.FieldError `json:"invalid_variables,omitempty"`
+	Error            string              `json:"error,omitempty"`
+}
+
+// Rerender statuses
+const (
+	RerenderUpdated = "updated"
+	RerenderSkipped = "skipped"
+	RerenderFailed  = "failed"
+)
+
+// GetStalePrompts handles GET /prompts/stale
+func (h *Handler) GetStalePrompts(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+
+	prompts, err := store.(storage.Storage).GetStalePrompts(queryProfileID(c, store))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	templates := newTemplateCache(store.(storage.Storage))
+	stale := []StalePrompt{}
+	for _, prompt := range prompts {
+		latest, err := templates.get(prompt.TemplateID, 0)
+		if err != nil {
+			continue
+		}
+		stale = append(stale, StalePrompt{Prompt: prompt, LatestVersion: latest.Version})
+	}
+
+	c.JSON(http.StatusOK, stale)
+}
+
+// RerenderPrompts handles POST /prompts/rerender. Each prompt's Content is
+// regenerated from its stored values; prompts that can't be rendered are
+// reported and left untouched.
+func (h *Handler) RerenderPrompts(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	var req RerenderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TemplateVersion != 0 && req.TemplateID == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template_version requires template_id"})
+		return
+	}
+
+	// The prompts that can be rerendered are saved together
+	results := []RerenderResult{}
+	updated, failed := 0, 0
+	err := store.(storage.Storage).WithTx(func(tx storage.Storage) error {
+		prompts, err := rerenderCandidates(tx, &req)
+		if err != nil {
+			return err
+		}
+
+		templates := newTemplateCache(tx)
+		for _, prompt := range prompts {
+			result := rerenderPrompt(tx, templates, prompt, &req, h.Cfg.ProfileLayout, h.Cfg.SectionOrder)
+			switch result.Status {
+			case RerenderUpdated:
+				updated++
+			case RerenderFailed:
+				failed++
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": req.DryRun,
+		"updated": updated,
+		"failed":  failed,
+		"results": results,
+	})
+}
+
+// rerenderCandidates returns the prompts a rerender request applies to.
+// Requested prompts that don't exist come back with only their ID set so
+// they can be reported.
+func rerenderCandidates(store storage.Storage, req *RerenderRequest) ([]*models.Prompt, error) {
+	if len(req.PromptIDs) > 0 {
+		prompts := make([]*models.Prompt, 0, len(req.PromptIDs))
+		for _, id := range req.PromptIDs {
+			prompt, err := store.GetByID(id)
+			if err != nil {
+				prompt = &models.Prompt{ID: id}
+			}
+			prompts = append(prompts, prompt)
+		}
+		return prompts, nil
+	}
+
+	var prompts []*models.Prompt
+	var err error
+	if req.TemplateVersion != 0 {
+		// A chosen version can be older than what a prompt already uses, so
+		// every prompt of the template is a candidate.
+		prompts, err = store.GetAll(req.ProfileID)
+	} else {
+		prompts, err = store.GetStalePrompts(req.ProfileID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.TemplateID == uuid.Nil {
+		return prompts, nil
+	}
+	var filtered []*models.Prompt
+	for _, prompt := range prompts {
+		if prompt.TemplateID == req.TemplateID {
+			filtered = append(filtered, prompt)
+		}
+	}
+	return filtered, nil
+}
+
+// rerenderPrompt regenerates one prompt, personalized with its intent and
+// its profile compiled using layout, and saves it unless the request is a
+// dry run.
+func rerenderPrompt(store storage.Storage, templates *templateCache, prompt *models.Prompt, req *RerenderRequest, layout string, order []string) RerenderResult {
+	result := RerenderResult{PromptID: prompt.ID, FromVersion: prompt.TemplateVersion, Status: RerenderFailed}
+
+	if prompt.TemplateID == uuid.Nil {
+		result.Error = "Prompt not found"
+		return result
+	}
+	if req.TemplateID != uuid.Nil && prompt.TemplateID != req.TemplateID {
+		result.Error = "Prompt was not generated from template " + req.TemplateID.String()
+		return result
+	}
+
+	template, err := templates.get(prompt.TemplateID, req.TemplateVersion)
+	if err != nil {
+		result.Error = "Template not found"
+		return result
+	}
+	result.ToVersion = template.Version
+
+	if template.Version == prompt.TemplateVersion {
+		result.Status = RerenderSkipped
+		return result
+	}
+
+	sections, unused, err := render.RerenderSections(template, prompt.Values)
+	result.UnusedVariables = unused
+	if err != nil {
+		var verr *render.ValidationError
+		if errors.As(err, &verr) {
+			result.MissingVariables = verr.Missing
+			result.InvalidVariables = verr.Invalid
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	intentName := prompt.Intent
+	if intentName == "" {
+		intentName = template.Intent
+	}
+	content, err := assemblePrompt(store, sections, prompt.ProfileID, intentName, layout, order)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Content = content
+
+	if !req.DryRun {
+		prompt.Content = content
+		prompt.TemplateVersion = template.Version
+		if _, err := store.Update(prompt); err != nil {
+			result.Content = ""
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	result.Status = RerenderUpdated
+	return result
+}
+
+// templateCache memoizes template lookups while processing many prompts.
+type templateCache struct {
+	store     storage.Storage
+	templates map[templateKey]*models.PromptTemplate
+}
+
+type templateKey struct {
+	id      uuid.UUID
+	version int
+}
+
+func newTemplateCache(store storage.Storage) *templateCache {
+	return &templateCache{store: store, templates: make(map[templateKey]*models.PromptTemplate)}
+}
+
+// get returns the given version of a template, or the latest when version is 0.
+func (tc *templateCache) get(id uuid.UUID, version int) (*models.PromptTemplate, error) {
+	key := templateKey{id, version}
+	if template, ok := tc.templates[key]; ok {
+		return template, nil
+	}
+
+	var template *models.PromptTemplate
+	var err error
+	if version > 0 {
+		template, err = tc.store.GetTemplateVersion(id, version)
+	} else {
+		template, err = tc.store.GetTemplateByID(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tc.templates[key] = template
+	return template, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"github.com/rahulguha/promptly/internal/api"
+	"github.com/rahulguha/promptly/internal/models"
 	"github.com/rahulguha/promptly/internal/storage"
 	"github.com/rahulguha/promptly/internal/storage/sqlite"
 )
@@ -28,12 +29,14 @@ func DBMiddleware(dbManager *storage.DBManager) gin.HandlerFunc {
 		}
 
 		// Get the user-specific database connection
-		db, err := dbManager.GetDB(userID.(string), email.(string))
+		db, release, err := dbManager.GetDB(userID.(string), email.(string))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to user database"})
 			c.Abort()
 			return
 		}
+		// The connection may be closed once the request is done with it
+		defer release()
 
 		// Create a new storage instance with the user's DB
 		store := sqlite.NewSQLiteStorageWithDB(db)
@@ -48,8 +51,12 @@ func RegisterProfileRoutes(r *gin.RouterGroup, handler *ProfileHandler) {
 	profiles := r.Group("/profiles")
 	{
 		profiles.GET("", handler.GetProfiles)
+		profiles.GET("/active", handler.GetActiveProfile)
+		profiles.PUT("/active", handler.SetActiveProfile)
 		profiles.GET("/:id", handler.GetProfile)
+		profiles.GET("/:id/system-prompt", handler.GetCompiledProfile)
 		profiles.POST("", handler.CreateProfile)
+		profiles.POST("/parse", handler.ParseProfile)
 		profiles.PUT("/:id", handler.UpdateProfile)
 		profiles.DELETE("/:id", handler.DeleteProfile)
 	}
@@ -72,7 +79,7 @@ func RegisterRoutes(r *gin.Engine, handler *Handler) {
 		AllowOrigins:     []string{"http://localhost:5175"}, // Correct frontend origin
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", headerTotalCount, headerNextCursor},
 		AllowCredentials: true, // Allow credentials
 		MaxAge:           12 * time.Hour,
 	}))
@@ -85,7 +92,7 @@ func RegisterRoutes(r *gin.Engine, handler *Handler) {
 		apiHandler := api.NewAPIHandler(handler.Cfg)
 
 		// Profile routes
-		profileHandler := &ProfileHandler{}
+		profileHandler := &ProfileHandler{Layout: handler.Cfg.ProfileLayout}
 		RegisterProfileRoutes(v1, profileHandler)
 
 		// Persona routes
@@ -106,6 +113,17 @@ func RegisterRoutes(r *gin.Engine, handler *Handler) {
 			templates.POST("", handler.CreateTemplate)
 			templates.PUT("/:id", handler.UpdateTemplate)
 			templates.POST("/:id/version", handler.CreateTemplateVersion)
+			templates.GET("/:id/versions", handler.GetTemplateVersions)
+			templates.GET("/:id/versions/:version", handler.GetTemplateVersion)
+			templates.POST("/:id/versions/:version/rollback", handler.RollbackTemplate)
+			templates.GET("/:id/diff", handler.DiffTemplateVersions)
+			templates.GET("/:id/tags", handler.GetTemplateTags)
+			templates.PUT("/:id/tags", handler.SetTemplateTags)
+			templates.PUT("/:id/folder", handler.MoveTemplate)
+			templates.GET("/:id/usage", handler.GetUsage(models.UsageTemplate))
+			templates.POST("/:id/usage", handler.RecordUsage(models.UsageTemplate))
+			templates.PUT("/:id/star", handler.SetStarred(models.UsageTemplate, true))
+			templates.DELETE("/:id/star", handler.SetStarred(models.UsageTemplate, false))
 			templates.DELETE("/:id", handler.DeleteTemplate)
 		}
 
@@ -113,17 +131,70 @@ func RegisterRoutes(r *gin.Engine, handler *Handler) {
 		prompts := v1.Group("/prompts")
 		{
 			prompts.GET("", handler.GetPrompts)
+			prompts.GET("/stale", handler.GetStalePrompts)
+			prompts.POST("/rerender", handler.RerenderPrompts)
 			prompts.GET("/:id", handler.GetPrompt)
+			prompts.GET("/:id/tags", handler.GetPromptTags)
+			prompts.PUT("/:id/tags", handler.SetPromptTags)
+			prompts.PUT("/:id/folder", handler.MovePrompt)
+			prompts.GET("/:id/usage", handler.GetUsage(models.UsagePrompt))
+			prompts.POST("/:id/usage", handler.RecordUsage(models.UsagePrompt))
+			prompts.PUT("/:id/star", handler.SetStarred(models.UsagePrompt, true))
+			prompts.DELETE("/:id/star", handler.SetStarred(models.UsagePrompt, false))
 			prompts.POST("", handler.CreatePrompt)
 			prompts.PUT("", handler.UpdatePrompt)
 			prompts.DELETE("", handler.DeletePrompt)
 		}
 
+		// Tag routes
+		tags := v1.Group("/tags")
+		{
+			tags.GET("", handler.GetTags)
+			tags.GET("/:id", handler.GetTag)
+			tags.POST("", handler.CreateTag)
+			tags.PUT("/:id", handler.UpdateTag)
+			tags.DELETE("/:id", handler.DeleteTag)
+		}
+
+		// Folder routes
+		folders := v1.Group("/folders")
+		{
+			folders.GET("", handler.GetFolders)
+			folders.GET("/:id", handler.GetFolder)
+			folders.POST("", handler.CreateFolder)
+			folders.PUT("/:id", handler.UpdateFolder)
+			folders.DELETE("/:id", handler.DeleteFolder)
+		}
+
+		// Trash routes
+		trash := v1.Group("/trash")
+		{
+			trash.GET("", handler.GetTrash)
+			trash.POST("/:id/restore", handler.RestoreTrash)
+			trash.DELETE("/:id", handler.PurgeTrashItem)
+		}
+
 		// Generate prompt from template
 		v1.POST("/generate-prompt", handler.GeneratePrompt)
 
+		// Full-text search across prompts, templates and personas
+		v1.GET("/search", handler.Search)
+
+		// Export and import the whole library as a bundle
+		v1.GET("/export", handler.Export)
+		v1.POST("/import", handler.Import)
+
 		// Intent routes
-		v1.GET("/intents", handler.GetIntents)
+		intents := v1.Group("/intents")
+		{
+			intents.GET("", handler.GetIntents)
+			intents.GET("/:intent", handler.GetIntent)
+			intents.POST("", handler.CreateIntent)
+			intents.POST("/classify", handler.ClassifyIntents)
+			intents.PUT("/:intent", handler.UpdateIntent)
+			intents.DELETE("/:intent", handler.DeleteIntent)
+			intents.POST("/:intent/reset", handler.ResetIntent)
+		}
 
 		// User tracking route
 		v1.POST("/track/users", handler.UserTrackingHandler.TrackUser)
@@ -131,6 +202,16 @@ func RegisterRoutes(r *gin.Engine, handler *Handler) {
 		// Activity tracking route
 		v1.POST("/track/activity", handler.UserTrackingHandler.TrackActivity)
 
+		// Stats of the pool of open user databases
+		v1.GET("/health/db", func(c *gin.Context) {
+			authenticated, _ := sessions.Default(c).Get("authenticated").(bool)
+			if !authenticated {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			c.JSON(http.StatusOK, handler.DBManager.Stats())
+		})
+
 		// Auth routes
 		auth := v1.Group("/api/auth")
 		{
@@ -148,6 +229,16 @@ func RegisterRoutes(r *gin.Engine, handler *Handler) {
 			"service": "promptly",
 		})
 	})
+
+	// Whether user databases can be opened. The pool's stats are only shown
+	// to signed in users, under /v1
+	r.GET("/health/db", func(c *gin.Context) {
+		if handler.DBManager.Closed() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "closed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
 }
 
 
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// Number of search results returned by default and at most
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchTypes are the values the type parameter of a search accepts.
+var searchTypes = map[string]bool{
+	models.SearchTypePrompt:   true,
+	models.SearchTypeTemplate: true,
+	models.SearchTypePersona:  true,
+}
+
+// errSearchUnsupported is returned for storage that can't search.
+var errSearchUnsupported = errors.New("search is not supported by this storage")
+
+// Search handles GET /search?q=...&type=prompt,template&limit=N. Results are
+// scoped to a profile the same way lists are.
+func (h *Handler) Search(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	searchStore, ok := store.(storage.SearchStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errSearchUnsupported.Error()})
+		return
+	}
+
+	query := c.Query("q")
+	if strings.IndexFunc(query, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q parameter must contain a word to search for"})
+		return
+	}
+
+	var types []string
+	if typeParam := c.Query("type"); typeParam != "" {
+		for _, t := range strings.Split(typeParam, ",") {
+			t = strings.TrimSpace(t)
+			if !searchTypes[t] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type: " + t})
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	limit := defaultSearchLimit
+	if limitParam := c.Query("limit"); limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+			return
+		}
+		limit = n
+	}
+
+	results, err := searchStore.Search(query, queryProfileID(c, searchStore), types, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,329 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// UpdateTagRequest changes a tag. Fields left out are unchanged.
+type UpdateTagRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// SetTagsRequest replaces the tags of a prompt or template. An empty list
+// removes them all.
+type SetTagsRequest struct {
+	TagIDs []uuid.UUID `json:"tag_ids"`
+}
+
+// errTagsUnsupported is returned for storage that doesn't keep tags.
+var errTagsUnsupported = errors.New("tags are not supported by this storage")
+
+// requestTagStore returns the request's store as tag storage. When there is
+// none it writes the error response and returns false.
+func requestTagStore(c *gin.Context) (storage.TagStorage, bool) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return nil, false
+	}
+	tagStore, ok := store.(storage.TagStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errTagsUnsupported.Error()})
+		return nil, false
+	}
+	return tagStore, true
+}
+
+// writeTagError responds to a failed tag create or update.
+func writeTagError(c *gin.Context, err error) {
+	if errors.Is(err, models.ErrTagExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// GetTags handles GET /tags
+func (h *Handler) GetTags(c *gin.Context) {
+	tagStore, ok := requestTagStore(c)
+	if !ok {
+		return
+	}
+
+	tags, err := tagStore.GetAllTags(queryProfileID(c, tagStore))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// GetTag handles GET /tags/:id
+func (h *Handler) GetTag(c *gin.Context) {
+	tagStore, ok := requestTagStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID format"})
+		return
+	}
+
+	tag, err := tagStore.GetTag(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// CreateTag handles POST /tags
+func (h *Handler) CreateTag(c *gin.Context) {
+	tagStore, ok := requestTagStore(c)
+	if !ok {
+		return
+	}
+
+	var tag models.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.Name = strings.TrimSpace(tag.Name); tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is required"})
+		return
+	}
+	tag.ProfileID = defaultProfileID(tagStore, tag.ProfileID)
+
+	if err := tagStore.CreateTag(&tag); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag handles PUT /tags/:id
+func (h *Handler) UpdateTag(c *gin.Context) {
+	tagStore, ok := requestTagStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID format"})
+		return
+	}
+
+	tag, err := tagStore.GetTag(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var req UpdateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != nil {
+		if tag.Name = strings.TrimSpace(*req.Name); tag.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is required"})
+			return
+		}
+	}
+	if req.Color != nil {
+		tag.Color = *req.Color
+	}
+
+	if err := tagStore.UpdateTag(tag); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag handles DELETE /tags/:id. The tag is removed from every prompt
+// and template it labels.
+func (h *Handler) DeleteTag(c *gin.Context) {
+	tagStore, ok := requestTagStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID format"})
+		return
+	}
+
+	if err := tagStore.DeleteTag(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// bindTagIDs reads a SetTagsRequest and checks every tag exists. It writes
+// a 400 response and returns false when one doesn't.
+func bindTagIDs(c *gin.Context, tagStore storage.TagStorage) ([]uuid.UUID, bool) {
+	var req SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	for _, id := range req.TagIDs {
+		if _, err := tagStore.GetTag(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tag not found: " + id.String()})
+			return nil, false
+		}
+	}
+	return req.TagIDs, true
+}
+
+// GetPromptTags handles GET /prompts/:id/tags
+func (h *Handler) GetPromptTags(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	tagStore, ok := store.(storage.TagStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errTagsUnsupported.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt ID format"})
+		return
+	}
+	if _, err := store.(storage.Storage).GetByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return
+	}
+
+	tags, err := tagStore.GetPromptTags(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// SetPromptTags handles PUT /prompts/:id/tags
+func (h *Handler) SetPromptTags(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	tagStore, ok := store.(storage.TagStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errTagsUnsupported.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt ID format"})
+		return
+	}
+	if _, err := store.(storage.Storage).GetByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return
+	}
+
+	tagIDs, ok := bindTagIDs(c, tagStore)
+	if !ok {
+		return
+	}
+
+	tags, err := tagStore.SetPromptTags(id, tagIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// GetTemplateTags handles GET /templates/:id/tags
+func (h *Handler) GetTemplateTags(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	tagStore, ok := store.(storage.TagStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errTagsUnsupported.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return
+	}
+	if _, err := store.(storage.Storage).GetTemplateByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	tags, err := tagStore.GetTemplateTags(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// SetTemplateTags handles PUT /templates/:id/tags. The tags apply to every
+// version of the template.
+func (h *Handler) SetTemplateTags(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	tagStore, ok := store.(storage.TagStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errTagsUnsupported.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return
+	}
+	if _, err := store.(storage.Storage).GetTemplateByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	tagIDs, ok := bindTagIDs(c, tagStore)
+	if !ok {
+		return
+	}
+
+	tags, err := tagStore.SetTemplateTags(id, tagIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
@@ -0,0 +1,223 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+	"github.com/rahulguha/promptly/internal/textdiff"
+)
+
+// SectionDiff is the line diff of one section of a template.
+type SectionDiff struct {
+	Section string          `json:"section"`
+	Changed bool            `json:"changed"`
+	Lines   []textdiff.Line `json:"lines"`
+}
+
+// TemplateDiff describes what changed between two versions of a template.
+type TemplateDiff struct {
+	TemplateID       uuid.UUID     `json:"template_id"`
+	From             int           `json:"from"`
+	To               int           `json:"to"`
+	Sections         []SectionDiff `json:"sections"`
+	AddedVariables   []string      `json:"added_variables"`
+	RemovedVariables []string      `json:"removed_variables"`
+}
+
+// parseVersion parses a template version number from a path or query value.
+func parseVersion(s string) (int, error) {
+	version, err := strconv.Atoi(s)
+	if err != nil || version < 1 {
+		return 0, errors.New("Invalid version format")
+	}
+	return version, nil
+}
+
+// GetTemplateVersions handles GET /templates/:id/versions
+func (h *Handler) GetTemplateVersions(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return
+	}
+
+	versions, err := store.(storage.Storage).GetTemplateVersions(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetTemplateVersion handles GET /templates/:id/versions/:version
+func (h *Handler) GetTemplateVersion(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return
+	}
+	version, err := parseVersion(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := store.(storage.Storage).GetTemplateVersion(id, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DiffTemplateVersions handles GET /templates/:id/diff?from=N&to=M.
+// to defaults to the latest version and from to the version before it.
+func (h *Handler) DiffTemplateVersions(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return
+	}
+
+	to := 0
+	if s := c.Query("to"); s != "" {
+		if to, err = parseVersion(s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		latest, err := store.(storage.Storage).GetTemplateByID(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		to = latest.Version
+	}
+
+	from := to - 1
+	if s := c.Query("from"); s != "" {
+		if from, err = parseVersion(s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if from < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template has no earlier version to compare with"})
+		return
+	}
+
+	older, err := store.(storage.Storage).GetTemplateVersion(id, from)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version " + strconv.Itoa(from) + " not found"})
+		return
+	}
+	newer, err := store.(storage.Storage).GetTemplateVersion(id, to)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version " + strconv.Itoa(to) + " not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diffTemplates(older, newer))
+}
+
+// diffTemplates compares two versions of a template section by section.
+func diffTemplates(from, to *models.PromptTemplate) TemplateDiff {
+	sections := []struct {
+		name     string
+		from, to string
+	}{
+		{"Meta Role", from.MetaRole, to.MetaRole},
+		{"Task", from.Task, to.Task},
+		{"Answer Guideline", from.AnswerGuideline, to.AnswerGuideline},
+	}
+
+	diff := TemplateDiff{
+		TemplateID:       to.ID,
+		From:             from.Version,
+		To:               to.Version,
+		AddedVariables:   missingFrom(to.Variables, from.Variables),
+		RemovedVariables: missingFrom(from.Variables, to.Variables),
+	}
+	for _, s := range sections {
+		lines := textdiff.Lines(s.from, s.to)
+		diff.Sections = append(diff.Sections, SectionDiff{
+			Section: s.name,
+			Changed: textdiff.Changed(lines),
+			Lines:   lines,
+		})
+	}
+	return diff
+}
+
+// missingFrom returns the names in vars that aren't in other, in order.
+func missingFrom(vars, other []string) []string {
+	seen := make(map[string]bool, len(other))
+	for _, v := range other {
+		seen[v] = true
+	}
+
+	missing := []string{}
+	for _, v := range vars {
+		if !seen[v] {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// RollbackTemplate handles POST /templates/:id/versions/:version/rollback.
+// The old version is copied unchanged into a new latest version, so history is
+// never rewritten.
+func (h *Handler) RollbackTemplate(c *gin.Context) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return
+	}
+	version, err := parseVersion(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := store.(storage.Storage).GetTemplateVersion(id, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version not found"})
+		return
+	}
+
+	newVersion, err := store.(storage.Storage).CreateTemplateVersion(template)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newVersion)
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// errTrashUnsupported is returned for storage that doesn't keep a trash.
+var errTrashUnsupported = errors.New("trash is not supported by this storage")
+
+// requestTrashStore returns the request's store as trash storage. When
+// there is none it writes the error response and returns false.
+func requestTrashStore(c *gin.Context) (storage.TrashStorage, bool) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return nil, false
+	}
+	trashStore, ok := store.(storage.TrashStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errTrashUnsupported.Error()})
+		return nil, false
+	}
+	return trashStore, true
+}
+
+// GetTrash handles GET /trash. Items are listed most recently deleted
+// first.
+func (h *Handler) GetTrash(c *gin.Context) {
+	trashStore, ok := requestTrashStore(c)
+	if !ok {
+		return
+	}
+
+	items, err := trashStore.GetTrash(queryProfileID(c, trashStore))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// RestoreTrash handles POST /trash/:id/restore. Everything the delete took
+// along comes back with the item.
+func (h *Handler) RestoreTrash(c *gin.Context) {
+	trashStore, ok := requestTrashStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trash item ID format"})
+		return
+	}
+
+	if err := trashStore.RestoreTrash(id); err != nil {
+		if errors.Is(err, models.ErrRestoreConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trash item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Restored successfully"})
+}
+
+// PurgeTrashItem handles DELETE /trash/:id, deleting the item for good
+// without waiting for the retention period.
+func (h *Handler) PurgeTrashItem(c *gin.Context) {
+	trashStore, ok := requestTrashStore(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trash item ID format"})
+		return
+	}
+
+	if err := trashStore.PurgeTrashItem(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trash item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purged successfully"})
+}
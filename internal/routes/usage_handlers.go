@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage"
+)
+
+// RecordUsageRequest records one use of a prompt or template
+type RecordUsageRequest struct {
+	Event string `json:"event" binding:"required"` // "render" or "copy"
+}
+
+// recordUsage counts a use in the store's usage ledger, if it keeps one.
+// Failures are logged rather than failing the request that used the item.
+func recordUsage(store interface{}, itemType string, id uuid.UUID, event string) {
+	usageStore, ok := store.(storage.UsageStorage)
+	if !ok {
+		return
+	}
+	if _, err := usageStore.RecordUsage(itemType, id, event); err != nil {
+		log.Printf("Failed to record %s usage: %v", itemType, err)
+	}
+}
+
+// errUsageUnsupported is returned for storage that doesn't keep a usage
+// ledger.
+var errUsageUnsupported = errors.New("usage is not supported by this storage")
+
+// usageItem returns the request's usage storage and reads the ID of the
+// prompt or template a usage request is about, checking it exists. It writes
+// an error response and returns false when either is missing.
+func usageItem(c *gin.Context, itemType string) (storage.UsageStorage, uuid.UUID, bool) {
+	store, exists := c.Get("store")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage not initialized"})
+		return nil, uuid.Nil, false
+	}
+	usageStore, ok := store.(storage.UsageStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": errUsageUnsupported.Error()})
+		return nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + itemType + " ID format"})
+		return nil, id, false
+	}
+	if itemType == models.UsageTemplate {
+		_, err = store.(storage.Storage).GetTemplateByID(id)
+	} else {
+		_, err = store.(storage.Storage).GetByID(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return nil, id, false
+	}
+	return usageStore, id, true
+}
+
+// GetUsage returns the handler for GET /prompts/:id/usage and
+// /templates/:id/usage
+func (h *Handler) GetUsage(itemType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usageStore, id, ok := usageItem(c, itemType)
+		if !ok {
+			return
+		}
+
+		usage, err := usageStore.GetUsage(itemType, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, usage)
+	}
+}
+
+// RecordUsage returns the handler for POST /prompts/:id/usage and
+// /templates/:id/usage, which clients call when they copy an item or render
+// it themselves
+func (h *Handler) RecordUsage(itemType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usageStore, id, ok := usageItem(c, itemType)
+		if !ok {
+			return
+		}
+
+		var req RecordUsageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Event != models.UsageRender && req.Event != models.UsageCopy {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "event must be render or copy"})
+			return
+		}
+
+		usage, err := usageStore.RecordUsage(itemType, id, req.Event)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, usage)
+	}
+}
+
+// SetStarred returns the handler for PUT (starred) or DELETE (not starred)
+// /prompts/:id/star and /templates/:id/star
+func (h *Handler) SetStarred(itemType string, starred bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usageStore, id, ok := usageItem(c, itemType)
+		if !ok {
+			return
+		}
+
+		usage, err := usageStore.SetStarred(itemType, id, starred)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, usage)
+	}
+}
@@ -0,0 +1,15 @@
+package storage
+
+import "github.com/rahulguha/promptly/internal/models"
+
+// BundleStorage defines the interface for importing a library with its IDs.
+type BundleStorage interface {
+	// IDTaken reports whether an item of a kind, models.LibraryProfile or
+	// another library kind, has an ID, counting items in the trash. For
+	// templates a version of 0 asks about any version.
+	IDTaken(kind, id string, version int) (bool, error)
+	// PutLibrary writes every item in lib in one transaction, keeping
+	// their IDs, template versions and timestamps. An item replaces any
+	// with the same ID, which stays in the trash if it is there.
+	PutLibrary(lib *models.Library) error
+}
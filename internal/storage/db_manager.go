@@ -1,54 +1,515 @@
 package storage
 
 import (
+	"container/list"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/rahulguha/promptly/internal/models"
 	"github.com/rahulguha/promptly/internal/storage/sqlite"
 	_ "modernc.org/sqlite"
 )
 
+// DataDir is the default data root, holding the registry and each user's
+// database.
+const DataDir = "./data"
+
+// Defaults for the pool of open databases
+const (
+	DefaultMaxOpenDBs = 256
+	DefaultDBIdleTTL  = 30 * time.Minute
+)
+
+// ErrManagerClosed is returned for databases requested after Close.
+var ErrManagerClosed = errors.New("database manager is closed")
+
+// IntentMaster is where the built-in intents seeded into each database come
+// from, such as an intent.Master. Load returns them with a version that
+// changes whenever they do.
+type IntentMaster interface {
+	Load() ([]models.Intent, string, error)
+}
+
+// PoolStats describes the pool of open databases.
+type PoolStats struct {
+	// Open is how many databases are open, in the pool or still in use
+	// after being evicted from it.
+	Open int `json:"open"`
+	// InUse is how many open databases requests are using.
+	InUse int `json:"in_use"`
+	// MaxOpen is how many databases the pool keeps open at most.
+	MaxOpen int `json:"max_open"`
+	// IdleTTLSeconds is how long an unused database stays open.
+	IdleTTLSeconds int64 `json:"idle_ttl_seconds"`
+	// Hits and Misses count requests for a database that was open and one
+	// that had to be opened.
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Evictions counts databases closed to stay under MaxOpen or because
+	// they sat idle.
+	Evictions uint64 `json:"evictions"`
+}
+
+// dbEntry is one user's open database, keyed by user ID.
+type dbEntry struct {
+	key string
+	db  *sql.DB
+	// refs counts the callers using db; it is closed once evicted and
+	// unused.
+	refs     int
+	lastUsed time.Time
+	evicted  bool
+	// intentsVersion is the version of the built-in intents last seeded.
+	intentsVersion string
+}
+
+// openCall is a database being opened, which other requests for it wait on
+// rather than opening it again.
+type openCall struct {
+	done chan struct{}
+	err  error
+}
+
 // DBManager handles a pool of database connections, one for each user.
+// The pool keeps at most maxOpen databases, evicting the least recently
+// used, and closes databases that sit idle for idleTTL. A database evicted
+// while requests are using it is closed when the last of them releases it.
 type DBManager struct {
-	mu  sync.RWMutex
-	dbs map[string]*sql.DB
+	mu       sync.Mutex
+	registry *Registry
+	options  sqlite.Options
+	maxOpen  int
+	idleTTL  time.Duration
+	dbs      map[string]*list.Element
+	// opening holds the databases being opened, by key.
+	opening map[string]*openCall
+	// lru orders the entries by use, most recent first.
+	lru *list.List
+	// evicted holds entries evicted while in use, until released.
+	evicted map[*dbEntry]bool
+	closed  bool
+	done    chan struct{}
+
+	hits, misses, evictions uint64
+
+	// trashRetention is how long items stay in the trash, once
+	// PurgeTrashEvery has started. Zero means they aren't purged.
+	trashRetention time.Duration
+	// intents are the built-in intents and their version, once
+	// SeedIntentsEvery has loaded them.
+	intents        []models.Intent
+	intentsVersion string
 }
 
-// NewDBManager creates a new DBManager.
-func NewDBManager() *DBManager {
-	return &DBManager{dbs: make(map[string]*sql.DB)}
+// NewDBManager creates a new DBManager for the databases under a data
+// root, opened with options, keeping at most maxOpen open and closing those
+// unused for idleTTL. Zero or less picks the defaults. Legacy databases in
+// the root are moved into place.
+func NewDBManager(dataDir string, options sqlite.Options, maxOpen int, idleTTL time.Duration) (*DBManager, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	registry, err := OpenRegistry(dataDir, options)
+	if err != nil {
+		return nil, err
+	}
+	if moved, err := registry.RelocateLegacy(); err != nil {
+		registry.Close()
+		return nil, fmt.Errorf("failed to move legacy databases: %w", err)
+	} else if moved > 0 {
+		log.Printf("Moved %d legacy databases into %s", moved, filepath.Join(dataDir, UsersDir))
+	}
+
+	if maxOpen <= 0 {
+		maxOpen = DefaultMaxOpenDBs
+	}
+	if idleTTL <= 0 {
+		idleTTL = DefaultDBIdleTTL
+	}
+	m := &DBManager{
+		registry: registry,
+		options:  options,
+		maxOpen:  maxOpen,
+		idleTTL:  idleTTL,
+		dbs:      make(map[string]*list.Element),
+		opening:  make(map[string]*openCall),
+		lru:      list.New(),
+		evicted:  make(map[*dbEntry]bool),
+		done:     make(chan struct{}),
+	}
+	go m.evictIdleEvery(idleTTL / 2)
+	return m, nil
 }
 
-// GetDB returns a database connection for a given user.
-// If a connection for the user does not exist, it creates a new one.
-func (m *DBManager) GetDB(userID, email string) (*sql.DB, error) {
-	key := fmt.Sprintf("%s-%s-promptly", userID, email)
+// GetDB returns a database connection for a given user, opening it if it
+// isn't open. Concurrent requests for a database being opened wait for it,
+// so each is opened and migrated once. The caller must call release once
+// done with the connection, after which it may be closed.
+func (m *DBManager) GetDB(userID, email string) (db *sql.DB, release func(), err error) {
+	key := userID
+
+	m.mu.Lock()
+	for {
+		if m.closed {
+			m.mu.Unlock()
+			return nil, nil, ErrManagerClosed
+		}
+		if elem, ok := m.dbs[key]; ok {
+			m.hits++
+			entry := m.use(elem)
+			m.mu.Unlock()
+			return entry.db, m.releaser(entry), nil
+		}
+		call, ok := m.opening[key]
+		if !ok {
+			break
+		}
+		m.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, nil, call.err
+		}
+		// It is in the pool now, unless already evicted again
+		m.mu.Lock()
+	}
+	m.misses++
+	call := &openCall{done: make(chan struct{})}
+	m.opening[key] = call
+	m.mu.Unlock()
+
+	entry, err := m.open(key, userID, email)
 
-	m.mu.RLock()
-	db, ok := m.dbs[key]
-	m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.opening, key)
+	defer close(call.done)
+	if err == nil && m.closed {
+		closeDB(entry)
+		err = ErrManagerClosed
+	}
+	if err != nil {
+		call.err = err
+		return nil, nil, err
+	}
 
-	if ok {
-		return db, nil
+	m.dbs[key] = m.lru.PushFront(entry)
+	entry.refs++
+	entry.lastUsed = time.Now()
+	for m.lru.Len() > m.maxOpen {
+		m.evict(m.lru.Back())
+	}
+	// Intents that changed while it was opening are seeded like those of
+	// any open database. Until then it has the previous ones.
+	if m.intentsVersion != "" && entry.intentsVersion != m.intentsVersion {
+		go m.reseed(entry)
 	}
+	return entry.db, m.releaser(entry), nil
+}
 
-	// Open new connection if it doesn't exist in the map
-	dbPath := fmt.Sprintf("./data/%s.db", key)
-	newDB, err := sql.Open("sqlite", dbPath)
+// open opens a user's database and brings it up to date: its schema, the
+// purges missed while it wasn't open and its built-in intents. It isn't in
+// the pool yet, so no request sees it before then.
+func (m *DBManager) open(key, userID, email string) (*dbEntry, error) {
+	dbPath, err := m.registry.Path(userID, email)
+	if err != nil {
+		return nil, err
+	}
+	newDB, err := sqlite.Open(dbPath, m.options)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize schema for the new DB
+	// Bring the new DB's schema up to date
 	if err := sqlite.InitializeSchema(newDB); err != nil {
 		newDB.Close()
 		return nil, fmt.Errorf("failed to initialize schema for new db: %w", err)
 	}
 
+	entry := &dbEntry{key: key, db: newDB}
 	m.mu.Lock()
-	m.dbs[key] = newDB
+	retention := m.trashRetention
+	intents, intentsVersion := m.intents, m.intentsVersion
 	m.mu.Unlock()
 
-	return newDB, nil
+	// Catch up on purges missed while the database wasn't open
+	if retention > 0 {
+		purgeTrash(key, newDB, time.Now().Add(-retention))
+	}
+	// Bring its built-in intents up to date
+	if intentsVersion != "" {
+		m.seedIntents(entry, intents, intentsVersion)
+	}
+	return entry, nil
+}
+
+// reseed seeds an entry that was opened with intents older than the
+// current ones.
+func (m *DBManager) reseed(entry *dbEntry) {
+	m.mu.Lock()
+	if entry.evicted {
+		m.mu.Unlock()
+		return
+	}
+	entry.refs++
+	intents, version := m.intents, m.intentsVersion
+	m.mu.Unlock()
+	defer m.releaser(entry)()
+
+	m.seedIntents(entry, intents, version)
+}
+
+// use marks an entry in use and most recently used. m.mu must be held.
+func (m *DBManager) use(elem *list.Element) *dbEntry {
+	entry := elem.Value.(*dbEntry)
+	entry.refs++
+	entry.lastUsed = time.Now()
+	m.lru.MoveToFront(elem)
+	return entry
+}
+
+// releaser returns the function releasing a use of an entry. Calls after
+// the first do nothing.
+func (m *DBManager) releaser(entry *dbEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			entry.refs--
+			entry.lastUsed = time.Now()
+			if entry.evicted && entry.refs == 0 {
+				delete(m.evicted, entry)
+				closeDB(entry)
+			}
+		})
+	}
+}
+
+// evict removes an entry from the pool, closing its database unless it is
+// in use. m.mu must be held.
+func (m *DBManager) evict(elem *list.Element) {
+	entry := m.lru.Remove(elem).(*dbEntry)
+	delete(m.dbs, entry.key)
+	entry.evicted = true
+	m.evictions++
+	if entry.refs == 0 {
+		closeDB(entry)
+	} else {
+		m.evicted[entry] = true
+	}
+}
+
+// closeDB closes an entry's database, logging rather than returning
+// failures.
+func closeDB(entry *dbEntry) {
+	if err := entry.db.Close(); err != nil {
+		log.Printf("Failed to close database %s: %v", entry.key, err)
+	}
+}
+
+// EvictIdle closes the databases that haven't been used for the idle TTL,
+// returning how many it closed.
+func (m *DBManager) EvictIdle() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-m.idleTTL)
+	evicted := 0
+	for elem := m.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*dbEntry); entry.refs == 0 && !entry.lastUsed.After(cutoff) {
+			m.evict(elem)
+			evicted++
+		}
+		elem = prev
+	}
+	return evicted
+}
+
+// evictIdleEvery runs EvictIdle every interval until the manager is closed.
+func (m *DBManager) evictIdleEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.EvictIdle()
+		}
+	}
+}
+
+// Stats returns the current state of the pool.
+func (m *DBManager) Stats() PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := PoolStats{
+		Open:           m.lru.Len() + len(m.evicted),
+		InUse:          len(m.evicted),
+		MaxOpen:        m.maxOpen,
+		IdleTTLSeconds: int64(m.idleTTL / time.Second),
+		Hits:           m.hits,
+		Misses:         m.misses,
+		Evictions:      m.evictions,
+	}
+	for elem := m.lru.Front(); elem != nil; elem = elem.Next() {
+		if elem.Value.(*dbEntry).refs > 0 {
+			stats.InUse++
+		}
+	}
+	return stats
+}
+
+// Close closes every database once it is released, and the registry, and
+// stops the background work. Databases can't be requested afterwards.
+func (m *DBManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.closed = true
+	close(m.done)
+	for m.lru.Len() > 0 {
+		m.evict(m.lru.Back())
+	}
+	if err := m.registry.Close(); err != nil {
+		log.Printf("Failed to close registry: %v", err)
+	}
+}
+
+// Closed reports whether the manager has been closed.
+func (m *DBManager) Closed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// acquireAll returns every database in the pool, marked in use, with the
+// functions releasing them.
+func (m *DBManager) acquireAll() ([]*dbEntry, []func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []*dbEntry
+	var releases []func()
+	for elem := m.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*dbEntry)
+		entry.refs++
+		entries = append(entries, entry)
+		releases = append(releases, m.releaser(entry))
+	}
+	return entries, releases
+}
+
+// PurgeTrashEvery permanently deletes items that have been in the trash
+// longer than retention, checking every open database now and then every
+// interval. Databases are also checked when they are opened. It returns
+// once the manager is closed, so run it in its own goroutine.
+func (m *DBManager) PurgeTrashEvery(retention, interval time.Duration) {
+	m.mu.Lock()
+	m.trashRetention = retention
+	m.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		before := time.Now().Add(-retention)
+		entries, releases := m.acquireAll()
+		for i, entry := range entries {
+			purgeTrash(entry.key, entry.db, before)
+			releases[i]()
+		}
+
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash purges one database's trash of items deleted before a time,
+// logging rather than returning failures.
+func purgeTrash(key string, db *sql.DB, before time.Time) {
+	purged, err := sqlite.NewSQLiteStorageWithDB(db).PurgeTrash(before)
+	if err != nil {
+		log.Printf("Failed to purge trash of %s: %v", key, err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d items from the trash of %s", purged, key)
+	}
+}
+
+// SeedIntents loads the built-in intents from master and seeds them into
+// the open databases that don't have this version yet. Databases opened
+// from then on are seeded when they open. Call it before serving, so no
+// database opens without them.
+func (m *DBManager) SeedIntents(master IntentMaster) error {
+	intents, version, err := master.Load()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.intents, m.intentsVersion = intents, version
+	m.mu.Unlock()
+
+	entries, releases := m.acquireAll()
+	for i, entry := range entries {
+		m.mu.Lock()
+		seeded := entry.intentsVersion == version
+		m.mu.Unlock()
+		if !seeded {
+			m.seedIntents(entry, intents, version)
+		}
+		releases[i]()
+	}
+	return nil
+}
+
+// SeedIntentsEvery keeps the built-in intents of every database in step
+// with master, running SeedIntents every interval. It returns once the
+// manager is closed, so run it in its own goroutine.
+func (m *DBManager) SeedIntentsEvery(master IntentMaster, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastErr string
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.SeedIntents(master); err != nil {
+			// Log a missing or broken file once, not every interval
+			if err.Error() != lastErr {
+				log.Printf("Failed to load intents: %v", err)
+			}
+			lastErr = err.Error()
+		} else {
+			lastErr = ""
+		}
+	}
+}
+
+// seedIntents seeds built-in intents into an entry's database, logging
+// rather than returning failures.
+func (m *DBManager) seedIntents(entry *dbEntry, intents []models.Intent, version string) {
+	if err := sqlite.NewSQLiteStorageWithDB(entry.db).SeedIntents(intents, version); err != nil {
+		log.Printf("Failed to seed intents of %s: %v", entry.key, err)
+		return
+	}
+	m.mu.Lock()
+	entry.intentsVersion = version
+	m.mu.Unlock()
 }
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
+)
+
+func newTestManager(t *testing.T, maxOpen int, idleTTL time.Duration) *DBManager {
+	t.Helper()
+	m, err := NewDBManager(t.TempDir(), sqlite.DefaultOptions(), maxOpen, idleTTL)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestDBManager_Evicts(t *testing.T) {
+	m := newTestManager(t, 2, time.Hour)
+
+	first, releaseFirst, err := m.GetDB("u1", "a@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	_, releaseSecond, err := m.GetDB("u2", "b@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	releaseSecond()
+
+	// The same user gets the same database
+	again, releaseAgain, err := m.GetDB("u1", "a@example.com")
+	if err != nil || again != first {
+		t.Fatalf("Expected the open database to be reused: %v", err)
+	}
+	releaseAgain()
+
+	// A third database evicts the least recently used, which is idle
+	_, releaseThird, err := m.GetDB("u3", "c@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	stats := m.Stats()
+	if stats.Open != 2 || stats.InUse != 2 || stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// A fourth evicts u1, which is still in use, so it stays open
+	_, releaseFourth, err := m.GetDB("u4", "d@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	if err := first.Ping(); err != nil {
+		t.Errorf("Expected a database in use to stay open after eviction: %v", err)
+	}
+	if stats := m.Stats(); stats.Open != 3 || stats.InUse != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Releasing it closes it, and releasing twice does nothing
+	releaseFirst()
+	releaseFirst()
+	if err := first.Ping(); err == nil {
+		t.Error("Expected an evicted database to be closed once released")
+	}
+	if stats := m.Stats(); stats.Open != 2 || stats.InUse != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	releaseThird()
+	releaseFourth()
+}
+
+func TestDBManager_EvictIdle(t *testing.T) {
+	m := newTestManager(t, 10, time.Hour)
+
+	idle, release, err := m.GetDB("u1", "a@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	release()
+	_, releaseBusy, err := m.GetDB("u2", "b@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	defer releaseBusy()
+
+	if evicted := m.EvictIdle(); evicted != 0 {
+		t.Errorf("Expected nothing idle yet, evicted %d", evicted)
+	}
+
+	// Pretend an hour has passed; only the database not in use goes
+	m.mu.Lock()
+	m.idleTTL = 0
+	m.mu.Unlock()
+	if evicted := m.EvictIdle(); evicted != 1 {
+		t.Errorf("Expected 1 idle database evicted, got %d", evicted)
+	}
+	if err := idle.Ping(); err == nil {
+		t.Error("Expected the idle database to be closed")
+	}
+	if stats := m.Stats(); stats.Open != 1 || stats.InUse != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDBManager_Close(t *testing.T) {
+	m := newTestManager(t, 10, time.Hour)
+
+	db, release, err := m.GetDB("u1", "a@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+
+	if m.Closed() {
+		t.Error("Expected the manager to be open")
+	}
+	m.Close()
+	if !m.Closed() {
+		t.Error("Expected the manager to be closed")
+	}
+	if _, _, err := m.GetDB("u2", "b@example.com"); err != ErrManagerClosed {
+		t.Errorf("Expected ErrManagerClosed after closing, got %v", err)
+	}
+
+	// The request in flight finishes before its database is closed
+	if err := db.Ping(); err != nil {
+		t.Errorf("Expected a database in use to stay open: %v", err)
+	}
+	release()
+	if err := db.Ping(); err == nil {
+		t.Error("Expected the database to be closed once released")
+	}
+	if stats := m.Stats(); stats.Open != 0 {
+		t.Errorf("Expected nothing open, got %+v", stats)
+	}
+}
+
+// fakeMaster is an intent master file whose contents the test sets.
+type fakeMaster struct {
+	mu      sync.Mutex
+	intents []models.Intent
+	version string
+	err     error
+}
+
+func (f *fakeMaster) Load() ([]models.Intent, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, "", f.err
+	}
+	return f.intents, f.version, nil
+}
+
+func (f *fakeMaster) set(version string, intents ...models.Intent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.intents, f.version = intents, version
+}
+
+func TestDBManager_SeedIntents(t *testing.T) {
+	m := newTestManager(t, 2, time.Hour)
+	master := &fakeMaster{err: errors.New("missing")}
+	if err := m.SeedIntents(master); err == nil {
+		t.Error("Expected the master's error")
+	}
+	master.err = nil
+	master.set("v1", models.Intent{Intent: "explain", Name: "Explain"})
+	if err := m.SeedIntents(master); err != nil {
+		t.Fatalf("Failed to seed intents: %v", err)
+	}
+	go m.SeedIntentsEvery(master, 10*time.Millisecond)
+
+	intentNames := func(userID string) []string {
+		t.Helper()
+		db, release, err := m.GetDB(userID, userID+"@example.com")
+		if err != nil {
+			t.Fatalf("Failed to get database: %v", err)
+		}
+		defer release()
+		intents, err := sqlite.NewSQLiteStorageWithDB(db).GetAllIntents()
+		if err != nil {
+			t.Fatalf("Failed to get intents: %v", err)
+		}
+		var names []string
+		for _, i := range intents {
+			names = append(names, i.Intent)
+		}
+		return names
+	}
+	waitFor := func(userID string, want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(intentNames(userID)) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d intents for %s, got %v", want, userID, intentNames(userID))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// Databases are seeded when they open, once the master is loaded
+	if names := intentNames("u1"); len(names) != 1 {
+		t.Errorf("Expected a database opened after loading the master to be seeded, got %v", names)
+	}
+	if names := intentNames("u2"); len(names) != 1 || names[0] != "explain" {
+		t.Errorf("Expected a new database to be seeded on open, got %v", names)
+	}
+
+	// Open databases follow a new version of the master
+	master.set("v2", models.Intent{Intent: "explain", Name: "Explain"}, models.Intent{Intent: "summarize", Name: "Summarize"})
+	waitFor("u1", 2)
+	waitFor("u2", 2)
+}
+
+func TestDBManager_ConcurrentOpen(t *testing.T) {
+	m := newTestManager(t, 10, time.Hour)
+	m.intents, m.intentsVersion = []models.Intent{{Intent: "explain", Name: "Explain"}}, "v1"
+
+	const requests = 8
+	start := make(chan struct{})
+	dbs := make(chan *sql.DB, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			db, release, err := m.GetDB("u1", "a@example.com")
+			if err != nil {
+				t.Errorf("Failed to get database: %v", err)
+				return
+			}
+			defer release()
+
+			// No request sees the database before it is seeded
+			intents, err := sqlite.NewSQLiteStorageWithDB(db).GetAllIntents()
+			if err != nil || len(intents) != 1 {
+				t.Errorf("Expected the database seeded when first seen, got %v (%v)", intents, err)
+			}
+			dbs <- db
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(dbs)
+
+	first := <-dbs
+	for db := range dbs {
+		if db != first {
+			t.Error("Expected every request to get the same database")
+		}
+	}
+	if stats := m.Stats(); stats.Misses != 1 || stats.Hits != requests-1 || stats.Open != 1 {
+		t.Errorf("Expected the database opened once, got %+v", stats)
+	}
+}
@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// DependentsStorage defines the interface for finding what a delete would
+// cascade to.
+type DependentsStorage interface {
+	GetPersonaDependents(id uuid.UUID) (*models.Dependents, error)
+	GetTemplateDependents(id uuid.UUID, version int) (*models.Dependents, error)
+}
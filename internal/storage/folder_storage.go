@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// FolderStorage defines the interface for the folder tree and what each
+// folder holds. A nil folder ID means outside any folder.
+type FolderStorage interface {
+	GetAllFolders(profileID string) ([]*models.Folder, error)
+	GetFolder(id uuid.UUID) (*models.Folder, error)
+	CreateFolder(folder *models.Folder) error
+	// UpdateFolder renames or moves a folder, returning
+	// models.ErrFolderCycle when moving it under itself.
+	UpdateFolder(folder *models.Folder) error
+	// DeleteFolder deletes a folder and its subfolders. Prompts and
+	// templates in them are kept, outside any folder.
+	DeleteFolder(id uuid.UUID) error
+
+	MovePrompt(promptID uuid.UUID, folderID *uuid.UUID) error
+	// MoveTemplate moves every version of a template.
+	MoveTemplate(templateID uuid.UUID, folderID *uuid.UUID) error
+}
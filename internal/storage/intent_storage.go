@@ -0,0 +1,16 @@
+package storage
+
+import "github.com/rahulguha/promptly/internal/models"
+
+// IntentStorage defines the interface for per-user intent storage
+type IntentStorage interface {
+	GetAllIntents() ([]*models.Intent, error)
+	GetIntent(name string) (*models.Intent, error)
+	CreateIntent(intent *models.Intent) error
+	UpdateIntent(intent *models.Intent) error
+	DeleteIntent(name string) error
+
+	// SeedIntents brings built-in intents up to date with the master file
+	// identified by version, keeping user overrides and custom intents.
+	SeedIntents(intents []models.Intent, version string) error
+}
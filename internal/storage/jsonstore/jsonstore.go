@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rahulguha/promptly/internal/models"
@@ -30,6 +32,11 @@ func NewFileStorage(filePath string) (*FileStorage, error) {
 	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
+
+	// Undo a transaction interrupted while committing
+	if err := fs.rollBack(); err != nil {
+		return nil, fmt.Errorf("failed to roll back interrupted commit: %w", err)
+	}
 	
 	// Create empty prompts file if it doesn't exist
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -110,6 +117,34 @@ func (fs *FileStorage) GetAll(profileID string) ([]*models.Prompt, error) {
 	return filteredPrompts, nil
 }
 
+func (fs *FileStorage) GetStalePrompts(profileID string) ([]*models.Prompt, error) {
+	prompts, err := fs.GetAll(profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := fs.loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[uuid.UUID]int)
+	for _, template := range templates {
+		if template.Version > latest[template.ID] {
+			latest[template.ID] = template.Version
+		}
+	}
+
+	var stale []*models.Prompt
+	for _, prompt := range prompts {
+		if prompt.TemplateVersion < latest[prompt.TemplateID] {
+			stale = append(stale, prompt)
+		}
+	}
+
+	return stale, nil
+}
+
 func (fs *FileStorage) GetByID(id uuid.UUID) (*models.Prompt, error) {
 	prompts, err := fs.load()
 	if err != nil {
@@ -145,6 +180,8 @@ func (fs *FileStorage) Create(prompt *models.Prompt) (*models.Prompt, error) {
 	if prompt.ID == uuid.Nil {
 		prompt.ID = uuid.New()
 	}
+	prompt.CreatedAt = time.Now().UTC()
+	prompt.UpdatedAt = prompt.CreatedAt
 	
 	prompts = append(prompts, *prompt)
 	
@@ -179,6 +216,9 @@ func (fs *FileStorage) Update(prompt *models.Prompt) (*models.Prompt, error) {
 	
 	for i, p := range prompts {
 		if p.ID == prompt.ID {
+			prompt.CreatedAt = p.CreatedAt
+			prompt.UpdatedAt = time.Now().UTC()
+			prompt.FolderID = p.FolderID
 			prompts[i] = *prompt
 			
 			jsonData, err := json.MarshalIndent(prompts, "", "  ")
@@ -323,6 +363,44 @@ func (fs *FileStorage) GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, er
 	return latestTemplate, nil
 }
 
+func (fs *FileStorage) GetTemplateVersion(id uuid.UUID, version int) (*models.PromptTemplate, error) {
+	templates, err := fs.loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, template := range templates {
+		if template.ID == id && template.Version == version {
+			return &template, nil
+		}
+	}
+
+	return nil, fmt.Errorf("template with ID %s version %d not found", id, version)
+}
+
+func (fs *FileStorage) GetTemplateVersions(id uuid.UUID) ([]*models.PromptTemplate, error) {
+	templates, err := fs.loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*models.PromptTemplate
+	for i := range templates {
+		if templates[i].ID == id {
+			versions = append(versions, &templates[i])
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("template with ID %s not found", id)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	return versions, nil
+}
+
 func (fs *FileStorage) CreateTemplate(template *models.PromptTemplate) (*models.PromptTemplate, error) {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
@@ -343,6 +421,8 @@ func (fs *FileStorage) CreateTemplate(template *models.PromptTemplate) (*models.
 		template.ID = uuid.New()
 	}
 	template.Version = 1 // New templates start at version 1
+	template.CreatedAt = time.Now().UTC()
+	template.UpdatedAt = template.CreatedAt
 	
 	templates = append(templates, *template)
 	
@@ -377,6 +457,9 @@ func (fs *FileStorage) UpdateTemplate(template *models.PromptTemplate) (*models.
 	// Find and update the specific version
 	for i, t := range templates {
 		if t.ID == template.ID && t.Version == template.Version {
+			template.CreatedAt = t.CreatedAt
+			template.UpdatedAt = time.Now().UTC()
+			template.FolderID = t.FolderID
 			templates[i] = *template
 			
 			jsonData, err := json.MarshalIndent(templates, "", "  ")
@@ -416,6 +499,7 @@ func (fs *FileStorage) CreateTemplateVersion(template *models.PromptTemplate) (*
 	for _, t := range templates {
 		if t.ID == template.ID && t.Version > maxVersion {
 			maxVersion = t.Version
+			template.FolderID = t.FolderID // Versions share a folder
 		}
 	}
 	
@@ -425,6 +509,8 @@ func (fs *FileStorage) CreateTemplateVersion(template *models.PromptTemplate) (*
 	
 	// Create new version
 	template.Version = maxVersion + 1
+	template.CreatedAt = time.Now().UTC()
+	template.UpdatedAt = template.CreatedAt
 	templates = append(templates, *template)
 	
 	jsonData, err := json.MarshalIndent(templates, "", "  ")
@@ -571,6 +657,8 @@ func (fs *FileStorage) CreatePersona(persona *models.Persona) (*models.Persona,
 	if persona.ID == uuid.Nil {
 		persona.ID = uuid.New()
 	}
+	persona.CreatedAt = time.Now().UTC()
+	persona.UpdatedAt = persona.CreatedAt
 	
 	personas = append(personas, *persona)
 	
@@ -604,6 +692,8 @@ func (fs *FileStorage) UpdatePersona(persona *models.Persona) (*models.Persona,
 	
 	for i, p := range personas {
 		if p.ID == persona.ID {
+			persona.CreatedAt = p.CreatedAt
+			persona.UpdatedAt = time.Now().UTC()
 			personas[i] = *persona
 			
 			jsonData, err := json.MarshalIndent(personas, "", "  ")
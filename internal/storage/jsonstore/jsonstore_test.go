@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage/store"
 )
 
 func TestNewFileStorage(t *testing.T) {
@@ -214,4 +216,265 @@ func TestFileStorage_PersonaOperations(t *testing.T) {
 	if found.UserRoleDisplay != persona.UserRoleDisplay {
 		t.Errorf("Expected user role display %s, got %s", persona.UserRoleDisplay, found.UserRoleDisplay)
 	}
-}
\ No newline at end of file
+}
+func TestFileStorage_TemplateVersions(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test_prompts.json")
+	storage, _ := NewFileStorage(filePath)
+
+	template := &models.PromptTemplate{PersonaID: uuid.New(), Template: "v1"}
+	created, err := storage.CreateTemplate(template)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	next := *created
+	next.Template = "v2"
+	if _, err := storage.CreateTemplateVersion(&next); err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+
+	versions, err := storage.GetTemplateVersions(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get template versions: %v", err)
+	}
+
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+
+	if versions[0].Version != 1 || versions[0].Template != "v1" || versions[1].Version != 2 || versions[1].Template != "v2" {
+		t.Errorf("Versions not returned oldest first: %+v, %+v", versions[0], versions[1])
+	}
+
+	if _, err := storage.GetTemplateVersions(uuid.New()); err == nil {
+		t.Error("Expected error for unknown template")
+	}
+}
+
+func TestFileStorage_GetStalePrompts(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test_prompts.json")
+	storage, _ := NewFileStorage(filePath)
+
+	template, err := storage.CreateTemplate(&models.PromptTemplate{PersonaID: uuid.New(), Template: "v1"})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	prompt, _ := storage.Create(&models.Prompt{TemplateID: template.ID, TemplateVersion: 1, Content: "v1"})
+
+	stale, err := storage.GetStalePrompts("")
+	if err != nil {
+		t.Fatalf("Failed to get stale prompts: %v", err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("Expected no stale prompts, got %d", len(stale))
+	}
+
+	next := *template
+	storage.CreateTemplateVersion(&next)
+
+	stale, err = storage.GetStalePrompts("")
+	if err != nil {
+		t.Fatalf("Failed to get stale prompts: %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != prompt.ID {
+		t.Errorf("Expected prompt %s to be stale, got %+v", prompt.ID, stale)
+	}
+}
+
+func TestFileStorage_ListPrompts(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test_prompts.json")
+	storage, _ := NewFileStorage(filePath)
+
+	templateID := uuid.New()
+	for _, name := range []string{"echo", "Alpha", "delta", "bravo", "Charlie"} {
+		storage.Create(&models.Prompt{Name: name, TemplateID: templateID, Content: name})
+	}
+	storage.Create(&models.Prompt{Name: "other", TemplateID: uuid.New(), Content: "other"})
+
+	// Page through the template's prompts two at a time
+	opts := models.ListOptions{TemplateID: templateID, Sort: models.SortName, Limit: 2}
+	var seen []string
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected paging to end")
+		}
+		prompts, page, err := storage.ListPrompts(opts)
+		if err != nil {
+			t.Fatalf("Failed to list prompts: %v", err)
+		}
+		if page.Total != 5 {
+			t.Errorf("Expected a total of 5, got %d", page.Total)
+		}
+		for _, p := range prompts {
+			seen = append(seen, p.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if fmt.Sprint(seen) != "[Alpha bravo Charlie delta echo]" {
+		t.Errorf("Unexpected order %v", seen)
+	}
+
+	// Newest first, and only those created in the window
+	prompts, _, err := storage.ListPrompts(models.ListOptions{Desc: true})
+	if err != nil || len(prompts) != 6 || prompts[0].Name != "other" {
+		t.Errorf("Expected the newest prompt first, got %v (%v)", prompts, err)
+	}
+	prompts, _, _ = storage.ListPrompts(models.ListOptions{CreatedBefore: prompts[5].CreatedAt})
+	if len(prompts) != 0 {
+		t.Errorf("Expected no prompts created before the first, got %d", len(prompts))
+	}
+
+	if _, _, err := storage.ListPrompts(models.ListOptions{Sort: models.SortVersion}); err == nil {
+		t.Error("Expected an error sorting prompts by version")
+	}
+	if _, _, err := storage.ListPrompts(models.ListOptions{Sort: models.SortName, Cursor: "bogus"}); err != models.ErrInvalidCursor {
+		t.Errorf("Expected an invalid cursor error, got %v", err)
+	}
+}
+
+func TestFileStorage_WithTx(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test_prompts.json")
+	storage, _ := NewFileStorage(filePath)
+
+	// A failed transaction leaves the files untouched
+	var persona *models.Persona
+	err := storage.WithTx(func(tx store.Storage) error {
+		var err error
+		if persona, err = tx.CreatePersona(&models.Persona{UserRoleDisplay: "Student", LLMRoleDisplay: "Tutor"}); err != nil {
+			return err
+		}
+		if _, err := tx.CreateTemplate(&models.PromptTemplate{Name: "Explain", PersonaID: persona.ID, Template: "Explain {{topic}}"}); err != nil {
+			return err
+		}
+		return fmt.Errorf("step failed")
+	})
+	if err == nil || err.Error() != "step failed" {
+		t.Fatalf("Expected the transaction's error, got %v", err)
+	}
+	if personas, _ := storage.GetAllPersonas(""); len(personas) != 0 {
+		t.Errorf("Expected no personas after rollback, got %d", len(personas))
+	}
+	if templates, _ := storage.GetAllTemplates(""); len(templates) != 0 {
+		t.Errorf("Expected no templates after rollback, got %d", len(templates))
+	}
+
+	// A successful one writes everything
+	err = storage.WithTx(func(tx store.Storage) error {
+		var err error
+		if persona, err = tx.CreatePersona(&models.Persona{UserRoleDisplay: "Student", LLMRoleDisplay: "Tutor"}); err != nil {
+			return err
+		}
+		template, err := tx.CreateTemplate(&models.PromptTemplate{Name: "Explain", PersonaID: persona.ID, Template: "Explain {{topic}}"})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Create(&models.Prompt{TemplateID: template.ID, TemplateVersion: 1, Content: "Explain gravity"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	if _, err := storage.GetPersonaByID(persona.ID); err != nil {
+		t.Errorf("Expected the persona to be committed: %v", err)
+	}
+	if prompts, _ := storage.GetAll(""); len(prompts) != 1 {
+		t.Errorf("Expected 1 prompt committed, got %d", len(prompts))
+	}
+
+	// No staged copies are left behind
+	entries, _ := os.ReadDir(tempDir)
+	if len(entries) != 3 {
+		t.Errorf("Expected only the 3 store files, got %d entries", len(entries))
+	}
+}
+
+func TestFileStorage_WithTxCommitFails(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test_prompts.json")
+	storage, _ := NewFileStorage(filePath)
+	persona, _ := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Student", LLMRoleDisplay: "Tutor"})
+
+	snapshot := func() map[string]string {
+		files := map[string]string{}
+		entries, _ := os.ReadDir(tempDir)
+		for _, e := range entries {
+			data, _ := os.ReadFile(filepath.Join(tempDir, e.Name()))
+			files[e.Name()] = string(data)
+		}
+		return files
+	}
+	before := snapshot()
+
+	// writeBoth changes the templates and the personas files
+	writeBoth := func(tx store.Storage) error {
+		if _, err := tx.CreateTemplate(&models.PromptTemplate{Name: "Explain", PersonaID: persona.ID, Template: "Explain {{topic}}"}); err != nil {
+			return err
+		}
+		_, err := tx.CreatePersona(&models.Persona{UserRoleDisplay: "Teacher", LLMRoleDisplay: "Assistant"})
+		return err
+	}
+	// failRenames makes the nth rename onto the store's files fail, and
+	// with after those following it too
+	failRenames := func(n int, after bool) {
+		count := 0
+		rename = func(oldPath, newPath string) error {
+			if newPath == storage.journalPath() {
+				return os.Rename(oldPath, newPath)
+			}
+			if count++; count == n || after && count > n {
+				return fmt.Errorf("disk on fire")
+			}
+			return os.Rename(oldPath, newPath)
+		}
+	}
+	defer func() { rename = os.Rename }()
+
+	// The second file failing to commit puts the first back
+	failRenames(2, false)
+	if err := storage.WithTx(writeBoth); err == nil {
+		t.Fatal("Expected the commit to fail")
+	}
+	if after := snapshot(); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected the files to be left as they were, got %v", after)
+	}
+
+	// When putting it back fails too, the journal finishes the rollback
+	// once the store is opened again
+	failRenames(2, true)
+	if err := storage.WithTx(writeBoth); err == nil {
+		t.Fatal("Expected the commit to fail")
+	}
+	if _, err := os.Stat(storage.journalPath()); err != nil {
+		t.Fatalf("Expected the journal to be kept: %v", err)
+	}
+	rename = os.Rename
+	reopened, err := NewFileStorage(filePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	if templates, _ := reopened.GetAllTemplates(""); len(templates) != 0 {
+		t.Errorf("Expected no templates after recovery, got %d", len(templates))
+	}
+	if personas, _ := reopened.GetAllPersonas(""); len(personas) != 1 {
+		t.Errorf("Expected 1 persona after recovery, got %d", len(personas))
+	}
+	if after := snapshot(); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected the files to be restored, got %v", after)
+	}
+
+	// With renames working again, the transaction commits
+	if err := reopened.WithTx(writeBoth); err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	if personas, _ := reopened.GetAllPersonas(""); len(personas) != 2 {
+		t.Errorf("Expected 2 personas, got %d", len(personas))
+	}
+}
@@ -0,0 +1,230 @@
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// timeKeyFormat renders times as fixed-width UTC strings, which sort the
+// same as the times themselves.
+const timeKeyFormat = "2006-01-02T15:04:05.000000000Z"
+
+func timeKey(t time.Time) string {
+	return t.UTC().Format(timeKeyFormat)
+}
+
+// listEntry is an item with what it is sorted by.
+type listEntry[T any] struct {
+	item    T
+	key     string
+	id      string
+	version int
+}
+
+// compareEntries orders entries by sort key, then ID and version. Version
+// keys compare as numbers and name keys ignoring case.
+func compareEntries(field, keyA, idA string, versionA int, keyB, idB string, versionB int) int {
+	switch {
+	case field == models.SortVersion:
+		a, _ := strconv.Atoi(keyA)
+		b, _ := strconv.Atoi(keyB)
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	case field == models.SortName:
+		if c := strings.Compare(strings.ToLower(keyA), strings.ToLower(keyB)); c != 0 {
+			return c
+		}
+	default:
+		if c := strings.Compare(keyA, keyB); c != 0 {
+			return c
+		}
+	}
+	if c := strings.Compare(idA, idB); c != 0 {
+		return c
+	}
+	return versionA - versionB
+}
+
+// paginate sorts, pages and counts items that have already been filtered.
+// keyOf returns an item's sort key for a field, or false when the field
+// isn't supported; position returns its ID and version.
+func paginate[T any](items []T, opts models.ListOptions, created func(T) time.Time, keyOf func(T, string) (string, bool), position func(T) (string, int)) ([]T, *models.PageInfo, error) {
+	field := opts.SortField()
+
+	entries := []listEntry[T]{}
+	for _, item := range items {
+		if t := created(item); (!opts.CreatedAfter.IsZero() && t.Before(opts.CreatedAfter)) ||
+			(!opts.CreatedBefore.IsZero() && !t.Before(opts.CreatedBefore)) {
+			continue
+		}
+		key, ok := keyOf(item, field)
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported sort field %q", field)
+		}
+		id, version := position(item)
+		entries = append(entries, listEntry[T]{item: item, key: key, id: id, version: version})
+	}
+
+	direction := 1
+	if opts.Desc {
+		direction = -1
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return direction*compareEntries(field, a.key, a.id, a.version, b.key, b.id, b.version) < 0
+	})
+
+	page := &models.PageInfo{Total: len(entries)}
+
+	// Continue after the cursor
+	if opts.Cursor != "" {
+		cursor, err := models.DecodeCursor(opts.Cursor, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		start := sort.Search(len(entries), func(i int) bool {
+			e := entries[i]
+			return direction*compareEntries(field, e.key, e.id, e.version, cursor.Key, cursor.ID, cursor.Version) > 0
+		})
+		entries = entries[start:]
+	}
+
+	if opts.Limit > 0 && len(entries) > opts.Limit {
+		entries = entries[:opts.Limit]
+		last := entries[len(entries)-1]
+		page.NextCursor = models.Cursor{
+			Sort:    field,
+			Desc:    opts.Desc,
+			Key:     last.key,
+			ID:      last.id,
+			Version: last.version,
+		}.Encode()
+	}
+
+	result := make([]T, len(entries))
+	for i, e := range entries {
+		result[i] = e.item
+	}
+	return result, page, nil
+}
+
+// commonKey returns the sort key of the timestamp fields every item has.
+func commonKey(field string, createdAt, updatedAt time.Time) (string, bool) {
+	switch field {
+	case models.SortCreatedAt:
+		return timeKey(createdAt), true
+	case models.SortUpdatedAt:
+		return timeKey(updatedAt), true
+	}
+	return "", false
+}
+
+// errTagsUnsupported is returned when filtering by tag; files don't store
+// tags.
+var errTagsUnsupported = errors.New("filtering by tag is not supported")
+
+// inFolder reports whether an item in folderID passes a folder filter.
+func inFolder(folderID *uuid.UUID, filter uuid.UUID) bool {
+	return filter == uuid.Nil || (folderID != nil && *folderID == filter)
+}
+
+// ListPrompts lists prompts, which can be filtered by template and folder
+// and sorted by name, created_at or updated_at.
+func (fs *FileStorage) ListPrompts(opts models.ListOptions) ([]*models.Prompt, *models.PageInfo, error) {
+	prompts, err := fs.GetAll(opts.ProfileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.TagID != uuid.Nil {
+		return nil, nil, errTagsUnsupported
+	}
+
+	var filtered []*models.Prompt
+	for _, prompt := range prompts {
+		if opts.TemplateID != uuid.Nil && prompt.TemplateID != opts.TemplateID {
+			continue
+		}
+		if !inFolder(prompt.FolderID, opts.FolderID) {
+			continue
+		}
+		filtered = append(filtered, prompt)
+	}
+
+	return paginate(filtered, opts,
+		func(p *models.Prompt) time.Time { return p.CreatedAt },
+		func(p *models.Prompt, field string) (string, bool) {
+			if field == models.SortName {
+				return p.Name, true
+			}
+			return commonKey(field, p.CreatedAt, p.UpdatedAt)
+		},
+		func(p *models.Prompt) (string, int) { return p.ID.String(), 0 })
+}
+
+// ListTemplates lists every version of templates, which can be filtered by
+// persona and folder and sorted by name, created_at, updated_at or version.
+func (fs *FileStorage) ListTemplates(opts models.ListOptions) ([]*models.PromptTemplate, *models.PageInfo, error) {
+	templates, err := fs.GetAllTemplates(opts.ProfileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.TagID != uuid.Nil {
+		return nil, nil, errTagsUnsupported
+	}
+
+	var filtered []*models.PromptTemplate
+	for _, template := range templates {
+		if opts.PersonaID != uuid.Nil && template.PersonaID != opts.PersonaID {
+			continue
+		}
+		if !inFolder(template.FolderID, opts.FolderID) {
+			continue
+		}
+		filtered = append(filtered, template)
+	}
+
+	return paginate(filtered, opts,
+		func(t *models.PromptTemplate) time.Time { return t.CreatedAt },
+		func(t *models.PromptTemplate, field string) (string, bool) {
+			switch field {
+			case models.SortName:
+				return t.Name, true
+			case models.SortVersion:
+				return strconv.Itoa(t.Version), true
+			}
+			return commonKey(field, t.CreatedAt, t.UpdatedAt)
+		},
+		func(t *models.PromptTemplate) (string, int) { return t.ID.String(), t.Version })
+}
+
+// ListPersonas lists personas, sorted by name (their user role),
+// created_at or updated_at.
+func (fs *FileStorage) ListPersonas(opts models.ListOptions) ([]*models.Persona, *models.PageInfo, error) {
+	personas, err := fs.GetAllPersonas(opts.ProfileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return paginate(personas, opts,
+		func(p *models.Persona) time.Time { return p.CreatedAt },
+		func(p *models.Persona, field string) (string, bool) {
+			if field == models.SortName {
+				return p.UserRoleDisplay, true
+			}
+			return commonKey(field, p.CreatedAt, p.UpdatedAt)
+		},
+		func(p *models.Persona) (string, int) { return p.ID.String(), 0 })
+}
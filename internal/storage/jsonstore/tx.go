@@ -0,0 +1,244 @@
+package jsonstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rahulguha/promptly/internal/storage/store"
+)
+
+var _ store.Storage = (*FileStorage)(nil)
+
+// rename is os.Rename, replaced in tests to make commits fail.
+var rename = os.Rename
+
+// stagedFile is a copy of one of the store's files that a transaction
+// writes to.
+type stagedFile struct {
+	path     string
+	staged   string
+	existed  bool
+	original []byte
+	// backup holds original while the transaction commits
+	backup string
+}
+
+// journalEntry is a file a commit is replacing, with the backup of what it
+// held, empty if it didn't exist.
+type journalEntry struct {
+	Path   string `json:"path"`
+	Backup string `json:"backup,omitempty"`
+}
+
+// writeTemp writes data to a new file beside path, returning its name.
+func writeTemp(path, suffix string, data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+suffix)
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(name)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	if err := os.Chmod(name, 0644); err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// stage copies a file to a new file beside it, so a rename can later put
+// the copy in its place.
+func stage(path string) (*stagedFile, error) {
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	existed := err == nil
+	staged, err := writeTemp(path, ".tx-*", original)
+	if err != nil {
+		return nil, err
+	}
+	return &stagedFile{path: path, staged: staged, existed: existed, original: original}, nil
+}
+
+// changed reports whether the transaction changed the staged copy.
+func (f *stagedFile) changed() (bool, error) {
+	data, err := os.ReadFile(f.staged)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(data, f.original), nil
+}
+
+// journalPath is the journal of the commit in progress, if any. It lists
+// the files being replaced and their backups, so a commit that fails or is
+// interrupted can be undone.
+func (fs *FileStorage) journalPath() string {
+	return filepath.Join(filepath.Dir(fs.filePath), "."+filepath.Base(fs.filePath)+".tx-journal")
+}
+
+// commit replaces the files the transaction changed with their staged
+// copies. The journal of backups is renamed into place before any file is
+// replaced, and the transaction commits when it is removed, so the files
+// either all change or, once rolled back, none do. fs.mutex must be held.
+func (fs *FileStorage) commit(files []*stagedFile) error {
+	var changed []*stagedFile
+	for _, f := range files {
+		ok, err := f.changed()
+		if err != nil {
+			return fmt.Errorf("failed to read staged %s: %w", f.path, err)
+		}
+		if ok {
+			changed = append(changed, f)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// Until the journal is in place nothing has changed, and the backups
+	// can go
+	removeBackups := func() {
+		for _, f := range changed {
+			if f.backup != "" {
+				os.Remove(f.backup)
+			}
+		}
+	}
+	entries := make([]journalEntry, len(changed))
+	for i, f := range changed {
+		if f.existed {
+			backup, err := writeTemp(f.path, ".tx-backup-*", f.original)
+			if err != nil {
+				removeBackups()
+				return fmt.Errorf("failed to back up %s: %w", f.path, err)
+			}
+			f.backup = backup
+		}
+		entries[i] = journalEntry{Path: f.path, Backup: f.backup}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		removeBackups()
+		return err
+	}
+	journal, err := writeTemp(fs.journalPath(), ".tmp-*", data)
+	if err != nil {
+		removeBackups()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := rename(journal, fs.journalPath()); err != nil {
+		os.Remove(journal)
+		removeBackups()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	for _, f := range changed {
+		if err := rename(f.staged, f.path); err != nil {
+			return fs.abort(fmt.Errorf("failed to commit %s: %w", f.path, err))
+		}
+	}
+	if err := os.Remove(fs.journalPath()); err != nil {
+		return fs.abort(fmt.Errorf("failed to remove journal: %w", err))
+	}
+	removeBackups()
+	return nil
+}
+
+// abort rolls back a failed commit, returning its error.
+func (fs *FileStorage) abort(err error) error {
+	if rbErr := fs.rollBack(); rbErr != nil {
+		return fmt.Errorf("%w; rollback failed, retried when the store is next opened: %v", err, rbErr)
+	}
+	return err
+}
+
+// rollBack undoes the commit in the journal, if there is one, putting back
+// each file's backup. It can run again after failing partway. fs.mutex must
+// be held.
+func (fs *FileStorage) rollBack() error {
+	data, err := os.ReadFile(fs.journalPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []journalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	for _, e := range entries {
+		if e.Backup == "" {
+			if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		// A missing backup was put back by an earlier attempt
+		if _, err := os.Stat(e.Backup); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := rename(e.Backup, e.Path); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", e.Path, err)
+		}
+	}
+	return os.Remove(fs.journalPath())
+}
+
+// WithTx runs fn as a unit of work against staged copies of the store's
+// files. If fn returns nil the changed copies replace their files together;
+// if it returns an error or panics the copies are discarded and the files
+// are left as they were. fn must not use fs itself, which is locked until
+// the transaction is done.
+func (fs *FileStorage) WithTx(fn func(tx store.Storage) error) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	// Finish undoing a commit that couldn't be rolled back before
+	if err := fs.rollBack(); err != nil {
+		return fmt.Errorf("failed to roll back interrupted commit: %w", err)
+	}
+
+	tx := &FileStorage{}
+	targets := []struct {
+		path   string
+		staged *string
+	}{
+		{fs.filePath, &tx.filePath},
+		{fs.templatesPath, &tx.templatesPath},
+		{fs.personasPath, &tx.personasPath},
+	}
+
+	var files []*stagedFile
+	defer func() {
+		// Committed copies were renamed away, so only the rest go
+		for _, f := range files {
+			os.Remove(f.staged)
+		}
+	}()
+	for _, target := range targets {
+		f, err := stage(target.path)
+		if err != nil {
+			return fmt.Errorf("failed to stage %s: %w", target.path, err)
+		}
+		files = append(files, f)
+		*target.staged = f.staged
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return fs.commit(files)
+}
@@ -5,8 +5,14 @@ import "github.com/rahulguha/promptly/internal/models"
 // ProfileStorage defines the interface for profile storage operations
 type ProfileStorage interface {
 	GetAllProfiles() ([]*models.Profile, error)
+	ListProfiles(opts models.ListOptions) ([]*models.Profile, *models.PageInfo, error)
 	GetProfileByID(id string) (*models.Profile, error)
 	CreateProfile(profile *models.Profile) error
 	UpdateProfile(profile *models.Profile) error
 	DeleteProfile(id string) error
+
+	// The active profile scopes lists and creates that don't name a
+	// profile. An empty ID means none is set.
+	GetActiveProfileID() (string, error)
+	SetActiveProfileID(id string) error
 }
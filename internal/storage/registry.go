@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
+)
+
+// Layout of the data root
+const (
+	// registryFile is the database mapping users to their database files.
+	registryFile = "registry.db"
+	// UsersDir is the directory under the data root holding each user's
+	// database.
+	UsersDir = "users"
+	// legacySuffix ends the names of databases from before the registry,
+	// which were <userID>-<email>-promptly.db in the data root.
+	legacySuffix = "-promptly.db"
+)
+
+const registrySchema = `
+CREATE TABLE IF NOT EXISTS user_databases (
+	user_id TEXT PRIMARY KEY,
+	file TEXT NOT NULL UNIQUE,
+	email TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Registry maps users to their database files under a data root. A user's
+// file is named by a hash of their user ID, so it doesn't reveal their
+// email and survives an email change.
+type Registry struct {
+	mu   sync.Mutex
+	root string
+	db   *sql.DB
+}
+
+// OpenRegistry opens the registry of a data root with options, creating
+// both if needed.
+func OpenRegistry(root string, options sqlite.Options) (*Registry, error) {
+	if err := os.MkdirAll(filepath.Join(root, UsersDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	db, err := sqlite.Open(filepath.Join(root, registryFile), options)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(registrySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize registry: %w", err)
+	}
+	return &Registry{root: root, db: db}, nil
+}
+
+// Close closes the registry.
+func (r *Registry) Close() error {
+	return r.db.Close()
+}
+
+// UserFile returns the file name, relative to the data root, a user's
+// database gets.
+func UserFile(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return filepath.Join(UsersDir, hex.EncodeToString(sum[:16])+".db")
+}
+
+// Path returns the path of a user's database, registering it if the user
+// is new. A legacy database named by the user's ID and email is moved into
+// place first.
+func (r *Registry) Path(userID, email string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var file string
+	err := r.db.QueryRow(`SELECT file FROM user_databases WHERE user_id = ?`, userID).Scan(&file)
+	if err == nil {
+		_, err = r.db.Exec(`UPDATE user_databases SET email = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ? AND email IS NOT ?`, email, userID, email)
+		if err != nil {
+			return "", fmt.Errorf("failed to update registry: %w", err)
+		}
+		return filepath.Join(r.root, file), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to read registry: %w", err)
+	}
+
+	file = UserFile(userID)
+	legacy := filepath.Join(r.root, fmt.Sprintf("%s-%s%s", userID, email, legacySuffix))
+	if err := r.relocate(legacy, file); err != nil {
+		return "", err
+	}
+	if err := r.register(userID, email, file); err != nil {
+		return "", err
+	}
+	return filepath.Join(r.root, file), nil
+}
+
+// register records a user's database file.
+func (r *Registry) register(userID, email, file string) error {
+	_, err := r.db.Exec(`INSERT INTO user_databases (user_id, file, email) VALUES (?, ?, ?)`, userID, file, email)
+	if err != nil {
+		return fmt.Errorf("failed to register database: %w", err)
+	}
+	return nil
+}
+
+// relocate moves a legacy database, with any journal files SQLite left
+// beside it, to file under the data root. A missing legacy database is
+// nothing to move.
+func (r *Registry) relocate(legacy, file string) error {
+	if _, err := os.Stat(legacy); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	target := filepath.Join(r.root, file)
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("can't move %s: %s already exists", legacy, target)
+	}
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Rename(legacy+suffix, target+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to move %s: %w", legacy+suffix, err)
+		}
+	}
+	if err := os.Rename(legacy, target); err != nil {
+		return fmt.Errorf("failed to move %s: %w", legacy, err)
+	}
+	log.Printf("Moved legacy database %s to %s", legacy, target)
+	return nil
+}
+
+// RelocateLegacy moves every legacy database in the data root whose user
+// can be told from its name into place and registers it, returning how
+// many it moved. Legacy names join the user ID and email with dashes, so
+// only user IDs that are UUIDs, as Cognito's are, can be split off; other
+// databases are moved when their user next signs in.
+func (r *Registry) RelocateLegacy() (int, error) {
+	paths, err := filepath.Glob(filepath.Join(r.root, "*"+legacySuffix))
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moved := 0
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), legacySuffix)
+		userID, email, ok := splitLegacyName(name)
+		if !ok {
+			log.Printf("Leaving legacy database %s until its user signs in", path)
+			continue
+		}
+
+		var registered bool
+		if err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM user_databases WHERE user_id = ?)`, userID).Scan(&registered); err != nil {
+			return moved, fmt.Errorf("failed to read registry: %w", err)
+		}
+		if registered {
+			// An older database of a user whose email has changed
+			log.Printf("Leaving legacy database %s: user already has a database", path)
+			continue
+		}
+
+		file := UserFile(userID)
+		if err := r.relocate(path, file); err != nil {
+			return moved, err
+		}
+		if err := r.register(userID, email, file); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// splitLegacyName splits a legacy database name, without its suffix, into
+// a UUID user ID and an email.
+func splitLegacyName(name string) (userID, email string, ok bool) {
+	const uuidLen = 36
+	if len(name) <= uuidLen+1 || name[uuidLen] != '-' {
+		return "", "", false
+	}
+	if _, err := uuid.Parse(name[:uuidLen]); err != nil {
+		return "", "", false
+	}
+	return name[:uuidLen], name[uuidLen+1:], true
+}
+
+// UserFiles returns the paths of every user database under a data root.
+func UserFiles(root string) ([]string, error) {
+	return filepath.Glob(filepath.Join(root, UsersDir, "*.db"))
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rahulguha/promptly/internal/storage/sqlite"
+)
+
+func TestRegistry(t *testing.T) {
+	root := t.TempDir()
+
+	// Legacy databases: one whose user ID is a UUID, one whose isn't
+	cognitoID := "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b"
+	legacyUUID := filepath.Join(root, cognitoID+"-ann-lee@example.com-promptly.db")
+	legacyOther := filepath.Join(root, "user-7-bob@example.com-promptly.db")
+	for _, path := range []string{legacyUUID, legacyOther} {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0o644); err != nil {
+			t.Fatalf("Failed to write legacy database: %v", err)
+		}
+	}
+
+	registry, err := OpenRegistry(root, sqlite.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to open registry: %v", err)
+	}
+	defer registry.Close()
+
+	moved, err := registry.RelocateLegacy()
+	if err != nil {
+		t.Fatalf("Failed to relocate legacy databases: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("Expected 1 database moved, got %d", moved)
+	}
+
+	path, err := registry.Path(cognitoID, "ann-lee@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get path: %v", err)
+	}
+	if path != filepath.Join(root, UserFile(cognitoID)) || strings.Contains(path, "example.com") {
+		t.Errorf("Unexpected path %s", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != filepath.Base(legacyUUID) {
+		t.Errorf("Expected the legacy database to be moved: %v", err)
+	}
+
+	// The other is moved when its user signs in
+	path, err = registry.Path("user-7", "bob@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get path: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != filepath.Base(legacyOther) {
+		t.Errorf("Expected the legacy database to be moved on sign in: %v", err)
+	}
+	if _, err := os.Stat(legacyOther); !os.IsNotExist(err) {
+		t.Error("Expected the legacy database to be gone")
+	}
+
+	// A changed email keeps the database
+	again, err := registry.Path("user-7", "robert@example.com")
+	if err != nil || again != path {
+		t.Errorf("Expected the same database after an email change, got %s: %v", again, err)
+	}
+
+	// New users get a database of their own
+	other, err := registry.Path("user-8", "carol@example.com")
+	if err != nil || other == path {
+		t.Errorf("Expected a new database for a new user, got %s: %v", other, err)
+	}
+
+	files, err := UserFiles(root)
+	if err != nil || len(files) != 2 {
+		t.Errorf("Expected the 2 moved databases, got %v: %v", files, err)
+	}
+}
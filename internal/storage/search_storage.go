@@ -0,0 +1,12 @@
+package storage
+
+import "github.com/rahulguha/promptly/internal/models"
+
+// SearchStorage defines the interface for full-text search over prompts,
+// templates and personas
+type SearchStorage interface {
+	// Search returns up to limit items of the given types matching query,
+	// best first. An empty profileID searches every profile, and no types
+	// searches every type.
+	Search(query, profileID string, types []string, limit int) ([]*models.SearchResult, error)
+}
@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// timestampArg returns a time as a query argument stored the way
+// CURRENT_TIMESTAMP stores times. The zero time is NULL.
+func timestampArg(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(timestampFormat)
+}
+
+// IDTaken reports whether an item of a kind has an ID, counting items in
+// the trash, whose IDs an import can't reuse without disturbing them.
+func (s *SQLiteStorage) IDTaken(kind, id string, version int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	args := []interface{}{id}
+	var query string
+	switch kind {
+	case models.LibraryProfile:
+		query = `SELECT EXISTS (SELECT 1 FROM profiles WHERE id = ?)`
+	case models.LibraryPersona:
+		query = `SELECT EXISTS (SELECT 1 FROM personas WHERE id = ?)`
+	case models.LibraryTemplate:
+		query = `SELECT EXISTS (SELECT 1 FROM prompt_templates WHERE id = ? AND (? = 0 OR version = ?))`
+		args = append(args, version, version)
+	case models.LibraryPrompt:
+		query = `SELECT EXISTS (SELECT 1 FROM prompts WHERE id = ?)`
+	default:
+		return false, fmt.Errorf("unknown item kind %q", kind)
+	}
+
+	var taken bool
+	if err := s.db.QueryRow(query, args...).Scan(&taken); err != nil {
+		return false, fmt.Errorf("failed to look up %s %s: %w", kind, id, err)
+	}
+	return taken, nil
+}
+
+func (s *SQLiteStorage) PutLibrary(lib *models.Library) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, profile := range lib.Profiles {
+		attributesJSON, err := json.Marshal(profile.Attributes)
+		if err != nil {
+			return fmt.Errorf("failed to marshal attributes: %w", err)
+		}
+		_, err = tx.Exec(`INSERT INTO profiles (id, name, description, attributes, created_at, updated_at)
+			VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))
+			ON CONFLICT (id) DO UPDATE SET
+				name = excluded.name, description = excluded.description, attributes = excluded.attributes,
+				created_at = excluded.created_at, updated_at = excluded.updated_at`,
+			profile.ID, profile.Name, profile.Description, string(attributesJSON), timestampArg(profile.CreatedAt), timestampArg(profile.UpdatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to import profile %s: %w", profile.ID, err)
+		}
+	}
+
+	for _, persona := range lib.Personas {
+		_, err := tx.Exec(`INSERT INTO personas (id, user_role_display, llm_role_display, profile_id, meta_role_template, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))
+			ON CONFLICT (id) DO UPDATE SET
+				user_role_display = excluded.user_role_display, llm_role_display = excluded.llm_role_display,
+				profile_id = excluded.profile_id, meta_role_template = excluded.meta_role_template,
+				created_at = excluded.created_at, updated_at = excluded.updated_at`,
+			persona.ID.String(), persona.UserRoleDisplay, persona.LLMRoleDisplay, profileArg(persona.ProfileID), persona.MetaRoleTemplate, timestampArg(persona.CreatedAt), timestampArg(persona.UpdatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to import persona %s: %w", persona.ID, err)
+		}
+	}
+
+	// Imported templates and prompts keep the folder of the item they
+	// replace, and are otherwise in no folder.
+	for _, template := range lib.Templates {
+		variablesJSON, schemaJSON, err := marshalTemplateJSON(template)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO prompt_templates (id, name, persona_id, version, meta_role, task, answer_guideline, template, variables, profile_id, variable_schema, manual_meta_role, intent, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))
+			ON CONFLICT (id, version) DO UPDATE SET
+				name = excluded.name, persona_id = excluded.persona_id, meta_role = excluded.meta_role,
+				task = excluded.task, answer_guideline = excluded.answer_guideline, template = excluded.template,
+				variables = excluded.variables, profile_id = excluded.profile_id, variable_schema = excluded.variable_schema,
+				manual_meta_role = excluded.manual_meta_role, intent = excluded.intent,
+				created_at = excluded.created_at, updated_at = excluded.updated_at`,
+			template.ID.String(), template.Name, template.PersonaID.String(), template.Version, template.MetaRole, template.Task, template.AnswerGuideline, template.Template, variablesJSON, profileArg(template.ProfileID), schemaJSON, template.ManualMetaRole, template.Intent, timestampArg(template.CreatedAt), timestampArg(template.UpdatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to import template %s version %d: %w", template.ID, template.Version, err)
+		}
+	}
+
+	for _, prompt := range lib.Prompts {
+		valuesJSON, err := json.Marshal(prompt.Values)
+		if err != nil {
+			return fmt.Errorf("failed to marshal values: %w", err)
+		}
+		_, err = tx.Exec(`INSERT INTO prompts (id, name, template_id, template_version, variable_values, content, profile_id, intent, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))
+			ON CONFLICT (id) DO UPDATE SET
+				name = excluded.name, template_id = excluded.template_id, template_version = excluded.template_version,
+				variable_values = excluded.variable_values, content = excluded.content,
+				profile_id = excluded.profile_id, intent = excluded.intent,
+				created_at = excluded.created_at, updated_at = excluded.updated_at`,
+			prompt.ID.String(), prompt.Name, prompt.TemplateID.String(), prompt.TemplateVersion, string(valuesJSON), prompt.Content, profileArg(prompt.ProfileID), prompt.Intent, timestampArg(prompt.CreatedAt), timestampArg(prompt.UpdatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to import prompt %s: %w", prompt.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
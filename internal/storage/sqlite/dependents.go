@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// GetPersonaDependents returns the templates and prompts DeletePersona
+// would move to the trash along with the persona.
+func (s *SQLiteStorage) GetPersonaDependents(id uuid.UUID) (*models.Dependents, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM personas WHERE id = ? AND deleted_at IS NULL)`, id.String()).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get persona: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("persona %w", models.ErrNotFound)
+	}
+
+	deps := &models.Dependents{Templates: []models.TemplateVersionRef{}}
+	rows, err := s.db.Query(`SELECT id, version FROM prompt_templates WHERE persona_id = ? AND deleted_at IS NULL ORDER BY created_at, id, version`, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependent templates: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var templateID string
+		var ref models.TemplateVersionRef
+		if err := rows.Scan(&templateID, &ref.Version); err != nil {
+			return nil, fmt.Errorf("failed to scan dependent template: %w", err)
+		}
+		ref.ID = uuid.MustParse(templateID)
+		deps.Templates = append(deps.Templates, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query dependent templates: %w", err)
+	}
+	deps.TemplateCount = len(deps.Templates)
+
+	deps.PromptIDs, err = s.dependentPrompts(`SELECT pr.id FROM prompts pr
+		JOIN prompt_templates pt ON pt.id = pr.template_id AND pt.version = pr.template_version
+		WHERE pt.persona_id = ? AND pt.deleted_at IS NULL AND pr.deleted_at IS NULL
+		ORDER BY pr.created_at, pr.rowid`, id.String())
+	if err != nil {
+		return nil, err
+	}
+	deps.PromptCount = len(deps.PromptIDs)
+
+	return deps, nil
+}
+
+// GetTemplateDependents returns the prompts DeleteTemplate would move to
+// the trash along with a template version.
+func (s *SQLiteStorage) GetTemplateDependents(id uuid.UUID, version int) (*models.Dependents, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM prompt_templates WHERE id = ? AND version = ? AND deleted_at IS NULL)`, id.String(), version).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template version: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("template version %w", models.ErrNotFound)
+	}
+
+	deps := &models.Dependents{Templates: []models.TemplateVersionRef{}}
+	deps.PromptIDs, err = s.dependentPrompts(`SELECT id FROM prompts
+		WHERE template_id = ? AND template_version = ? AND deleted_at IS NULL
+		ORDER BY created_at, rowid`, id.String(), version)
+	if err != nil {
+		return nil, err
+	}
+	deps.PromptCount = len(deps.PromptIDs)
+
+	return deps, nil
+}
+
+// dependentPrompts runs a query selecting prompt IDs. Callers hold s.mu.
+func (s *SQLiteStorage) dependentPrompts(query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependent prompts: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dependent prompt: %w", err)
+		}
+		ids = append(ids, uuid.MustParse(id))
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,197 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// intentMasterKey is the settings key holding the version of the intent
+// master file the built-in intents were last seeded from.
+const intentMasterKey = "intent_master_version"
+
+const intentColumns = `intent, name, description, system_prompt, keywords, tag, custom, overridden`
+
+func scanIntent(row rowScanner) (*models.Intent, error) {
+	var intent models.Intent
+	var description, systemPrompt, tag sql.NullString
+	var keywords string
+	err := row.Scan(&intent.Intent, &intent.Name, &description, &systemPrompt, &keywords, &tag, &intent.Custom, &intent.Overridden)
+	if err != nil {
+		return nil, err
+	}
+	intent.Description = description.String
+	intent.SystemPrompt = systemPrompt.String
+	intent.Tag = tag.String
+	if err := json.Unmarshal([]byte(keywords), &intent.Keywords); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal keywords: %w", err)
+	}
+	return &intent, nil
+}
+
+func marshalKeywords(keywords []string) (string, error) {
+	if keywords == nil {
+		keywords = []string{}
+	}
+	data, err := json.Marshal(keywords)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal keywords: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetAllIntents returns built-in intents in master file order, followed by
+// custom intents in the order they were added.
+func (s *SQLiteStorage) GetAllIntents() ([]*models.Intent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`SELECT ` + intentColumns + ` FROM intents ORDER BY custom, rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []*models.Intent
+	for rows.Next() {
+		intent, err := scanIntent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan intent: %w", err)
+		}
+		intents = append(intents, intent)
+	}
+
+	return intents, rows.Err()
+}
+
+func (s *SQLiteStorage) GetIntent(name string) (*models.Intent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	intent, err := scanIntent(s.db.QueryRow(`SELECT `+intentColumns+` FROM intents WHERE intent = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("intent not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get intent: %w", err)
+	}
+
+	return intent, nil
+}
+
+func (s *SQLiteStorage) CreateIntent(intent *models.Intent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keywords, err := marshalKeywords(intent.Keywords)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO intents (` + intentColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.db.Exec(query, intent.Intent, intent.Name, intent.Description, intent.SystemPrompt, keywords, intent.Tag, intent.Custom, intent.Overridden)
+	if err != nil {
+		return fmt.Errorf("failed to create intent: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) UpdateIntent(intent *models.Intent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keywords, err := marshalKeywords(intent.Keywords)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE intents SET name = ?, description = ?, system_prompt = ?, keywords = ?, tag = ?, custom = ?, overridden = ?, updated_at = CURRENT_TIMESTAMP WHERE intent = ?`
+	result, err := s.db.Exec(query, intent.Name, intent.Description, intent.SystemPrompt, keywords, intent.Tag, intent.Custom, intent.Overridden, intent.Intent)
+	if err != nil {
+		return fmt.Errorf("failed to update intent: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("intent not found")
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteIntent(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec(`DELETE FROM intents WHERE intent = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete intent: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("intent not found")
+	}
+
+	return nil
+}
+
+// SeedIntents adds new built-in intents and refreshes existing ones from the
+// master file. Overridden system prompts and keywords are kept, and custom
+// intents that share a name with a built-in one are left alone. Nothing is
+// done when version matches the last seeded master file.
+func (s *SQLiteStorage) SeedIntents(intents []models.Intent, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var seeded string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, intentMasterKey).Scan(&seeded)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get intent master version: %w", err)
+	}
+	if seeded == version {
+		return nil
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO intents (` + intentColumns + `) VALUES (?, ?, ?, ?, ?, ?, 0, 0)
+		ON CONFLICT(intent) DO UPDATE SET
+			name = excluded.name,
+			description = excluded.description,
+			tag = excluded.tag,
+			system_prompt = CASE WHEN intents.overridden THEN intents.system_prompt ELSE excluded.system_prompt END,
+			keywords = CASE WHEN intents.overridden THEN intents.keywords ELSE excluded.keywords END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE intents.custom = 0`
+	for _, intent := range intents {
+		keywords, err := marshalKeywords(intent.Keywords)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, intent.Intent, intent.Name, intent.Description, intent.SystemPrompt, keywords, intent.Tag); err != nil {
+			return fmt.Errorf("failed to seed intent %s: %w", intent.Intent, err)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`, intentMasterKey, version)
+	if err != nil {
+		return fmt.Errorf("failed to record intent master version: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,444 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// v4LibraryTriggers holds the triggers that keep tags, folders and usage
+// consistent with the items they describe, and indexes on columns added after their
+// tables. Foreign keys aren't enforced on every connection, so the triggers
+// do the clean-up the foreign keys would. The library_triggers migration
+// creates them, and like its other DDL this never changes.
+const v4LibraryTriggers = `
+CREATE INDEX IF NOT EXISTS idx_templates_folder ON prompt_templates(folder_id);
+CREATE INDEX IF NOT EXISTS idx_prompts_folder ON prompts(folder_id);
+
+CREATE TRIGGER IF NOT EXISTS prompt_tags_delete AFTER DELETE ON prompts BEGIN
+	DELETE FROM prompt_tags WHERE prompt_id = old.id;
+END;
+
+-- Template tags go with the template's last version
+CREATE TRIGGER IF NOT EXISTS template_tags_delete AFTER DELETE ON prompt_templates
+WHEN NOT EXISTS (SELECT 1 FROM prompt_templates WHERE id = old.id) BEGIN
+	DELETE FROM template_tags WHERE template_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS prompt_usage_delete AFTER DELETE ON prompts BEGIN
+	DELETE FROM item_usage WHERE item_type = 'prompt' AND item_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS template_usage_delete AFTER DELETE ON prompt_templates
+WHEN NOT EXISTS (SELECT 1 FROM prompt_templates WHERE id = old.id) BEGIN
+	DELETE FROM item_usage WHERE item_type = 'template' AND item_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS tags_delete AFTER DELETE ON tags BEGIN
+	DELETE FROM prompt_tags WHERE tag_id = old.id;
+	DELETE FROM template_tags WHERE tag_id = old.id;
+END;
+
+-- Items in a deleted folder move out of any folder
+CREATE TRIGGER IF NOT EXISTS folders_delete AFTER DELETE ON folders BEGIN
+	UPDATE prompts SET folder_id = NULL WHERE folder_id = old.id;
+	UPDATE prompt_templates SET folder_id = NULL WHERE folder_id = old.id;
+END;
+`
+
+// folderArg returns a folder ID as a query argument, NULL for no folder.
+func folderArg(id *uuid.UUID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return id.String()
+}
+
+// parseFolderID reads a nullable folder ID column.
+func parseFolderID(s sql.NullString) *uuid.UUID {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	id, err := uuid.Parse(s.String)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// isUniqueViolation reports whether err is a failed UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// Tag operations
+
+// tagColumns lists the columns scanTag expects, in order.
+const tagColumns = `id, name, color, profile_id, created_at, updated_at`
+
+func scanTag(row rowScanner) (*models.Tag, error) {
+	var tag models.Tag
+	var idStr string
+	var color, profileID sql.NullString
+	if err := row.Scan(&idStr, &tag.Name, &color, &profileID, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+		return nil, err
+	}
+	tag.ID = uuid.MustParse(idStr)
+	tag.Color = color.String
+	tag.ProfileID = profileID.String
+	return &tag, nil
+}
+
+func queryTags(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, query string, args ...interface{}) ([]*models.Tag, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetAllTags returns tags in name order. An empty profileID returns the
+// tags of every profile.
+func (s *SQLiteStorage) GetAllTags(profileID string) ([]*models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + tagColumns + ` FROM tags`
+	args := []interface{}{}
+	if profileID != "" {
+		query += " WHERE profile_id = ?"
+		args = append(args, profileID)
+	}
+	query += " ORDER BY name COLLATE NOCASE"
+	return queryTags(s.db, query, args...)
+}
+
+func (s *SQLiteStorage) GetTag(id uuid.UUID) (*models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tag, err := scanTag(s.db.QueryRow(`SELECT `+tagColumns+` FROM tags WHERE id = ?`, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("tag not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return tag, nil
+}
+
+func (s *SQLiteStorage) CreateTag(tag *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag.ID = uuid.New()
+	query := `INSERT INTO tags (id, name, color, profile_id) VALUES (?, ?, ?, ?) ` + returningTimestamps
+	err := s.db.QueryRow(query, tag.ID.String(), tag.Name, tag.Color, profileArg(tag.ProfileID)).Scan(&tag.CreatedAt, &tag.UpdatedAt)
+	if isUniqueViolation(err) {
+		return models.ErrTagExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) UpdateTag(tag *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `UPDATE tags SET name = ?, color = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? ` + returningTimestamps
+	err := s.db.QueryRow(query, tag.Name, tag.Color, tag.ID.String()).Scan(&tag.CreatedAt, &tag.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("tag not found")
+	}
+	if isUniqueViolation(err) {
+		return models.ErrTagExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteTag(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec(`DELETE FROM tags WHERE id = ?`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("tag not found")
+	}
+	return nil
+}
+
+// itemTags returns the tags linked to an item through a link table.
+func itemTags(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, linkTable, itemColumn string, itemID uuid.UUID) ([]*models.Tag, error) {
+	query := fmt.Sprintf(`SELECT %s FROM tags WHERE id IN (SELECT tag_id FROM %s WHERE %s = ?) ORDER BY name COLLATE NOCASE`,
+		tagColumns, linkTable, itemColumn)
+	return queryTags(q, query, itemID.String())
+}
+
+// setItemTags replaces the tags linked to an item through a link table.
+// Unknown tag IDs are an error.
+func (s *SQLiteStorage) setItemTags(linkTable, itemColumn string, itemID uuid.UUID, tagIDs []uuid.UUID) ([]*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s = ?`, linkTable, itemColumn), itemID.String()); err != nil {
+		return nil, fmt.Errorf("failed to clear tags: %w", err)
+	}
+	for _, tagID := range tagIDs {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM tags WHERE id = ?`, tagID.String()).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to look up tag: %w", err)
+		}
+		if exists == 0 {
+			return nil, fmt.Errorf("tag %s not found", tagID)
+		}
+		insert := fmt.Sprintf(`INSERT OR IGNORE INTO %s (%s, tag_id) VALUES (?, ?)`, linkTable, itemColumn)
+		if _, err := tx.Exec(insert, itemID.String(), tagID.String()); err != nil {
+			return nil, fmt.Errorf("failed to add tag: %w", err)
+		}
+	}
+
+	tags, err := itemTags(tx, linkTable, itemColumn, itemID)
+	if err != nil {
+		return nil, err
+	}
+	return tags, tx.Commit()
+}
+
+func (s *SQLiteStorage) GetPromptTags(promptID uuid.UUID) ([]*models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return itemTags(s.db, "prompt_tags", "prompt_id", promptID)
+}
+
+func (s *SQLiteStorage) SetPromptTags(promptID uuid.UUID, tagIDs []uuid.UUID) ([]*models.Tag, error) {
+	return s.setItemTags("prompt_tags", "prompt_id", promptID, tagIDs)
+}
+
+func (s *SQLiteStorage) GetTemplateTags(templateID uuid.UUID) ([]*models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return itemTags(s.db, "template_tags", "template_id", templateID)
+}
+
+func (s *SQLiteStorage) SetTemplateTags(templateID uuid.UUID, tagIDs []uuid.UUID) ([]*models.Tag, error) {
+	return s.setItemTags("template_tags", "template_id", templateID, tagIDs)
+}
+
+// Folder operations
+
+// folderColumns lists the columns scanFolder expects, in order.
+const folderColumns = `id, name, parent_id, profile_id, created_at, updated_at`
+
+func scanFolder(row rowScanner) (*models.Folder, error) {
+	var folder models.Folder
+	var idStr string
+	var parentID, profileID sql.NullString
+	if err := row.Scan(&idStr, &folder.Name, &parentID, &profileID, &folder.CreatedAt, &folder.UpdatedAt); err != nil {
+		return nil, err
+	}
+	folder.ID = uuid.MustParse(idStr)
+	folder.ParentID = parseFolderID(parentID)
+	folder.ProfileID = profileID.String
+	return &folder, nil
+}
+
+// GetAllFolders returns folders as a flat list in name order; ParentID
+// links them into a tree. An empty profileID returns the folders of every
+// profile.
+func (s *SQLiteStorage) GetAllFolders(profileID string) ([]*models.Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + folderColumns + ` FROM folders`
+	args := []interface{}{}
+	if profileID != "" {
+		query += " WHERE profile_id = ?"
+		args = append(args, profileID)
+	}
+	query += " ORDER BY name COLLATE NOCASE"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query folders: %w", err)
+	}
+	defer rows.Close()
+
+	folders := []*models.Folder{}
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan folder: %w", err)
+		}
+		folders = append(folders, folder)
+	}
+	return folders, rows.Err()
+}
+
+func (s *SQLiteStorage) GetFolder(id uuid.UUID) (*models.Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	folder, err := scanFolder(s.db.QueryRow(`SELECT `+folderColumns+` FROM folders WHERE id = ?`, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("folder not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder: %w", err)
+	}
+	return folder, nil
+}
+
+func (s *SQLiteStorage) CreateFolder(folder *models.Folder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folder.ID = uuid.New()
+	query := `INSERT INTO folders (id, name, parent_id, profile_id) VALUES (?, ?, ?, ?) ` + returningTimestamps
+	err := s.db.QueryRow(query, folder.ID.String(), folder.Name, folderArg(folder.ParentID), profileArg(folder.ProfileID)).Scan(&folder.CreatedAt, &folder.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) UpdateFolder(folder *models.Folder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Walk up from the new parent; finding the folder on the way means it
+	// would end up inside itself
+	if folder.ParentID != nil {
+		var cycle int
+		err := s.db.QueryRow(`
+			WITH RECURSIVE ancestors(id) AS (
+				SELECT ?
+				UNION
+				SELECT f.parent_id FROM folders f JOIN ancestors a ON f.id = a.id WHERE f.parent_id IS NOT NULL
+			)
+			SELECT COUNT(*) FROM ancestors WHERE id = ?`,
+			folder.ParentID.String(), folder.ID.String()).Scan(&cycle)
+		if err != nil {
+			return fmt.Errorf("failed to check folder tree: %w", err)
+		}
+		if cycle > 0 {
+			return models.ErrFolderCycle
+		}
+	}
+
+	query := `UPDATE folders SET name = ?, parent_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? ` + returningTimestamps
+	err := s.db.QueryRow(query, folder.Name, folderArg(folder.ParentID), folder.ID.String()).Scan(&folder.CreatedAt, &folder.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("folder not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update folder: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteFolder(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Delete the whole subtree at once; the folders_delete trigger moves
+	// what each folder held out of it
+	result, err := s.db.Exec(`
+		DELETE FROM folders WHERE id IN (
+			WITH RECURSIVE subtree(id) AS (
+				SELECT ?
+				UNION
+				SELECT f.id FROM folders f JOIN subtree st ON f.parent_id = st.id
+			)
+			SELECT id FROM subtree
+		)`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("folder not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) MovePrompt(promptID uuid.UUID, folderID *uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec(`UPDATE prompts SET folder_id = ? WHERE id = ? AND deleted_at IS NULL`, folderArg(folderID), promptID.String())
+	if err != nil {
+		return fmt.Errorf("failed to move prompt: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("prompt not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) MoveTemplate(templateID uuid.UUID, folderID *uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Versions in the trash move too, so they are restored alongside the rest
+	result, err := s.db.Exec(`UPDATE prompt_templates SET folder_id = ? WHERE id = ?
+		AND EXISTS (SELECT 1 FROM prompt_templates WHERE id = ? AND deleted_at IS NULL)`, folderArg(folderID), templateID.String(), templateID.String())
+	if err != nil {
+		return fmt.Errorf("failed to move template: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("template not found")
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// timestampFormat is how CURRENT_TIMESTAMP stores times, so bounds given in
+// it compare correctly with stored timestamps as text.
+const timestampFormat = "2006-01-02 15:04:05"
+
+// numericSorts are the sort fields whose keys are numbers. Their cursor keys
+// are converted back to numbers so they compare as the column does.
+var numericSorts = map[string]bool{
+	models.SortVersion:  true,
+	models.SortMostUsed: true,
+}
+
+// listSpec describes how to list one kind of item.
+type listSpec struct {
+	from    string // Tables to select from, joins included
+	columns string // Columns the item's scan function expects
+	where   []string
+	args    []interface{}
+	// sorts maps each sort field the list supports to its SQL expression.
+	sorts map[string]string
+	// id and version break ties between items with equal sort keys.
+	// version is empty for items without versions.
+	id, version string
+	created     string
+}
+
+// keyedRow scans the sort key selected ahead of an item's columns, so the
+// item's own scan function can be used unchanged.
+type keyedRow struct {
+	rows *sql.Rows
+	key  *sql.NullString
+}
+
+func (r keyedRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append([]interface{}{r.key}, dest...)...)
+}
+
+// list runs a filtered, sorted and paged list query. position returns the
+// ID and version of an item for its cursor.
+func list[T any](s *SQLiteStorage, spec listSpec, opts models.ListOptions, scan func(rowScanner) (T, error), position func(T) (string, int)) ([]T, *models.PageInfo, error) {
+	field := opts.SortField()
+	sortExpr, ok := spec.sorts[field]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported sort field %q", field)
+	}
+	orderExpr := sortExpr
+	if field == models.SortName {
+		orderExpr += " COLLATE NOCASE"
+	}
+
+	where := append([]string{}, spec.where...)
+	args := append([]interface{}{}, spec.args...)
+	if !opts.CreatedAfter.IsZero() {
+		where = append(where, spec.created+" >= ?")
+		args = append(args, opts.CreatedAfter.UTC().Format(timestampFormat))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where = append(where, spec.created+" < ?")
+		args = append(args, opts.CreatedBefore.UTC().Format(timestampFormat))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var page models.PageInfo
+	countQuery := `SELECT COUNT(*) FROM ` + spec.from + whereClause(where)
+	if err := s.db.QueryRow(countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, nil, fmt.Errorf("failed to count: %w", err)
+	}
+
+	dir, cmp := "ASC", ">"
+	if opts.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	// Continue after the cursor: a later sort key, or the same key and a
+	// later ID (and version)
+	if opts.Cursor != "" {
+		cursor, err := models.DecodeCursor(opts.Cursor, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		var key interface{} = cursor.Key
+		if numericSorts[field] {
+			if key, err = strconv.Atoi(cursor.Key); err != nil {
+				return nil, nil, models.ErrInvalidCursor
+			}
+		}
+		tie := spec.id + " " + cmp + " ?"
+		tieArgs := []interface{}{cursor.ID}
+		if spec.version != "" {
+			tie = "(" + tie + " OR (" + spec.id + " = ? AND " + spec.version + " " + cmp + " ?))"
+			tieArgs = append(tieArgs, cursor.ID, cursor.Version)
+		}
+		where = append(where, "("+orderExpr+" "+cmp+" ? OR ("+orderExpr+" = ? AND "+tie+"))")
+		args = append(append(args, key, key), tieArgs...)
+	}
+
+	order := []string{orderExpr + " " + dir, spec.id + " " + dir}
+	if spec.version != "" {
+		order = append(order, spec.version+" "+dir)
+	}
+	query := `SELECT CAST(` + sortExpr + ` AS TEXT), ` + spec.columns + ` FROM ` + spec.from + whereClause(where) +
+		` ORDER BY ` + strings.Join(order, ", ")
+	if opts.Limit > 0 {
+		// One extra row tells whether there is another page
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit+1)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list: %w", err)
+	}
+	defer rows.Close()
+
+	items := []T{}
+	var keys []string
+	for rows.Next() {
+		var key sql.NullString
+		item, err := scan(keyedRow{rows, &key})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		items = append(items, item)
+		keys = append(keys, key.String)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		items = items[:opts.Limit]
+		id, version := position(items[len(items)-1])
+		page.NextCursor = models.Cursor{
+			Sort:    field,
+			Desc:    opts.Desc,
+			Key:     keys[opts.Limit-1],
+			ID:      id,
+			Version: version,
+		}.Encode()
+	}
+
+	return items, &page, nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// ListPrompts lists prompts, which can be filtered by template, tag and
+// folder and sorted by name, created_at, updated_at or usage.
+func (s *SQLiteStorage) ListPrompts(opts models.ListOptions) ([]*models.Prompt, *models.PageInfo, error) {
+	spec := listSpec{
+		from:    "prompts LEFT JOIN item_usage u ON u.item_type = 'prompt' AND u.item_id = prompts.id",
+		columns: promptColumns + ", " + usageColumns,
+		sorts: withUsageSorts(map[string]string{
+			models.SortName:      "COALESCE(name, '')",
+			models.SortCreatedAt: "created_at",
+			models.SortUpdatedAt: "updated_at",
+		}),
+		id:      "id",
+		created: "created_at",
+		where:   []string{"deleted_at IS NULL"},
+	}
+	if opts.ProfileID != "" {
+		spec.where = append(spec.where, "profile_id = ?")
+		spec.args = append(spec.args, opts.ProfileID)
+	}
+	if opts.TemplateID != uuid.Nil {
+		spec.where = append(spec.where, "template_id = ?")
+		spec.args = append(spec.args, opts.TemplateID.String())
+	}
+	if opts.TagID != uuid.Nil {
+		spec.where = append(spec.where, "id IN (SELECT prompt_id FROM prompt_tags WHERE tag_id = ?)")
+		spec.args = append(spec.args, opts.TagID.String())
+	}
+	if opts.FolderID != uuid.Nil {
+		spec.where = append(spec.where, "folder_id = ?")
+		spec.args = append(spec.args, opts.FolderID.String())
+	}
+
+	scan := withUsage(scanPrompt, func(p *models.Prompt, u *models.Usage) { p.Usage = u })
+	return list(s, spec, opts, scan, func(p *models.Prompt) (string, int) { return p.ID.String(), 0 })
+}
+
+// ListTemplates lists every version of templates, which can be filtered by
+// persona, tag and folder and sorted by name, created_at, updated_at,
+// version or usage.
+func (s *SQLiteStorage) ListTemplates(opts models.ListOptions) ([]*models.PromptTemplate, *models.PageInfo, error) {
+	spec := listSpec{
+		from: "prompt_templates pt LEFT JOIN personas p ON pt.persona_id = p.id" +
+			" LEFT JOIN item_usage u ON u.item_type = 'template' AND u.item_id = pt.id",
+		columns: selectTemplateColumns("pt") + ", " + usageColumns,
+		sorts: withUsageSorts(map[string]string{
+			models.SortName:      "COALESCE(pt.name, '')",
+			models.SortCreatedAt: "pt.created_at",
+			models.SortUpdatedAt: "pt.updated_at",
+			models.SortVersion:   "pt.version",
+		}),
+		id:      "pt.id",
+		version: "pt.version",
+		created: "pt.created_at",
+		where:   []string{"pt.deleted_at IS NULL"},
+	}
+	if opts.ProfileID != "" {
+		spec.where = append(spec.where, "(pt.profile_id = ? OR p.profile_id = ?)")
+		spec.args = append(spec.args, opts.ProfileID, opts.ProfileID)
+	}
+	if opts.PersonaID != uuid.Nil {
+		spec.where = append(spec.where, "pt.persona_id = ?")
+		spec.args = append(spec.args, opts.PersonaID.String())
+	}
+	if opts.TagID != uuid.Nil {
+		spec.where = append(spec.where, "pt.id IN (SELECT template_id FROM template_tags WHERE tag_id = ?)")
+		spec.args = append(spec.args, opts.TagID.String())
+	}
+	if opts.FolderID != uuid.Nil {
+		spec.where = append(spec.where, "pt.folder_id = ?")
+		spec.args = append(spec.args, opts.FolderID.String())
+	}
+
+	scan := withUsage(scanTemplate, func(t *models.PromptTemplate, u *models.Usage) { t.Usage = u })
+	return list(s, spec, opts, scan, func(t *models.PromptTemplate) (string, int) { return t.ID.String(), t.Version })
+}
+
+// ListPersonas lists personas, sorted by name (their user role),
+// created_at or updated_at.
+func (s *SQLiteStorage) ListPersonas(opts models.ListOptions) ([]*models.Persona, *models.PageInfo, error) {
+	spec := listSpec{
+		from:    "personas",
+		columns: personaColumns,
+		sorts: map[string]string{
+			models.SortName:      "user_role_display",
+			models.SortCreatedAt: "created_at",
+			models.SortUpdatedAt: "updated_at",
+		},
+		id:      "id",
+		created: "created_at",
+		where:   []string{"deleted_at IS NULL"},
+	}
+	if opts.ProfileID != "" {
+		spec.where = append(spec.where, "(profile_id = ? OR profile_id = ?)")
+		spec.args = append(spec.args, opts.ProfileID, defaultProfileID)
+	}
+
+	return list(s, spec, opts, scanPersona, func(p *models.Persona) (string, int) { return p.ID.String(), 0 })
+}
+
+// ListProfiles lists profiles, sorted by name, created_at or updated_at.
+// ProfileID doesn't apply.
+func (s *SQLiteStorage) ListProfiles(opts models.ListOptions) ([]*models.Profile, *models.PageInfo, error) {
+	spec := listSpec{
+		from:    "profiles",
+		columns: profileColumns,
+		where:   []string{"id != ?"},
+		args:    []interface{}{defaultProfileID},
+		sorts: map[string]string{
+			models.SortName:      "name",
+			models.SortCreatedAt: "created_at",
+			models.SortUpdatedAt: "updated_at",
+		},
+		id:      "id",
+		created: "created_at",
+	}
+
+	return list(s, spec, opts, scanProfile, func(p *models.Profile) (string, int) { return p.ID, 0 })
+}
@@ -0,0 +1,260 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Migration is one numbered change to the schema, with the change that
+// undoes it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// execAll returns a migration step running SQL statements.
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// dropAll returns statements dropping objects of a kind, such as "TRIGGER".
+func dropAll(kind string, names ...string) []string {
+	statements := make([]string, len(names))
+	for i, name := range names {
+		statements[i] = fmt.Sprintf("DROP %s IF EXISTS %s", kind, name)
+	}
+	return statements
+}
+
+// noChange is the Down of migrations that bring databases from before
+// versioning in line with what earlier migrations create, so there is
+// nothing to undo.
+func noChange(tx *sql.Tx) error { return nil }
+
+// Migrations lists every schema migration in order. Databases created
+// before versioning start with none applied; the first migrations tolerate
+// whatever part of the schema they already have. New migrations go at the
+// end, and a released migration never changes, nor does the DDL it runs:
+// each migration's DDL is its own constant, named for its version, which
+// nothing else uses.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up:      execAll(v1InitialSchema),
+		Down: execAll(dropAll("TABLE", "trash", "item_usage", "folders", "template_tags", "prompt_tags", "tags",
+			"intents", "prompts", "prompt_templates", "personas", "settings", "profiles")...),
+	},
+	{
+		Version: 2,
+		Name:    "backfill_columns",
+		Up: func(tx *sql.Tx) error {
+			for _, c := range v2AddedColumns {
+				if err := ensureColumn(tx, c.table, c.column, c.definition); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: noChange,
+	},
+	{
+		Version: 3,
+		Name:    "template_version_key",
+		Up:      upgradeTemplateKey,
+		Down:    noChange,
+	},
+	{
+		Version: 4,
+		Name:    "library_triggers",
+		Up:      execAll(v4LibraryTriggers),
+		Down: execAll(append(
+			dropAll("TRIGGER", "prompt_tags_delete", "template_tags_delete", "prompt_usage_delete",
+				"template_usage_delete", "tags_delete", "folders_delete"),
+			dropAll("INDEX", "idx_templates_folder", "idx_prompts_folder")...)...),
+	},
+	{
+		Version: 5,
+		Name:    "search_indexes",
+		Up:      execAll(v5SearchIndexes),
+		Down: execAll(append(
+			dropAll("TRIGGER", "prompts_fts_insert", "prompts_fts_update", "prompts_fts_delete",
+				"templates_fts_insert", "templates_fts_update", "templates_fts_delete",
+				"personas_fts_insert", "personas_fts_update", "personas_fts_delete"),
+			dropAll("TABLE", "prompts_fts", "templates_fts", "personas_fts")...)...),
+	},
+	{
+		Version: 6,
+		Name:    "default_profile",
+		Up: execAll(append([]string{
+			`INSERT OR IGNORE INTO profiles (id, name, attributes) VALUES ('` + defaultProfileID + `', 'Default', 'null')`},
+			detachOrphans("personas", "prompt_templates", "prompts", "tags", "folders")...)...),
+		Down: execAll(`DELETE FROM profiles WHERE id = '` + defaultProfileID + `'`),
+	},
+}
+
+// detachOrphans returns statements clearing profile IDs that name no
+// profile, such as empty ones, in tables with a foreign key to profiles.
+// Databases opened without foreign keys could gather them, and they would
+// fail the key on update once it is enforced.
+func detachOrphans(tables ...string) []string {
+	statements := make([]string, len(tables))
+	for i, table := range tables {
+		statements[i] = fmt.Sprintf("UPDATE OR IGNORE %s SET profile_id = NULL WHERE profile_id NOT IN (SELECT id FROM profiles)", table)
+	}
+	return statements
+}
+
+// LatestVersion is the schema version Migrate brings databases to.
+func LatestVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+const migrationsSchema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`
+
+// SchemaVersion returns the version of the last migration applied to a
+// database, 0 for none.
+func SchemaVersion(db *sql.DB) (int, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Migrate applies every pending migration to a database, returning the
+// version it started at.
+func Migrate(db *sql.DB) (int, error) {
+	return MigrateTo(db, LatestVersion())
+}
+
+// MigrateTo brings a database to a schema version, applying pending
+// migrations up to it or undoing applied ones above it, newest first. Each
+// migration runs in its own transaction with foreign keys off, so tables
+// can be rebuilt. It returns the version the database started at; on error
+// the database is left at the last migration that succeeded.
+func MigrateTo(db *sql.DB, target int) (int, error) {
+	if target < 0 || target > LatestVersion() {
+		return 0, fmt.Errorf("unknown schema version %d", target)
+	}
+
+	ctx := context.Background()
+
+	// PRAGMA foreign_keys only applies to the connection it runs on and can't
+	// change inside a transaction, so pin a connection for the migrations.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, migrationsSchema); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	current := 0
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = true
+		if version > current {
+			current = version
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if current > LatestVersion() {
+		return current, fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, LatestVersion())
+	}
+
+	var pending []Migration
+	up := true
+	for _, m := range Migrations {
+		if m.Version <= target && !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	if len(pending) == 0 {
+		up = false
+		for i := len(Migrations) - 1; i >= 0; i-- {
+			if m := Migrations[i]; m.Version > target && applied[m.Version] {
+				pending = append(pending, m)
+			}
+		}
+	}
+	if len(pending) == 0 {
+		return current, nil
+	}
+
+	var foreignKeys int
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return current, err
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return current, err
+	}
+	defer conn.ExecContext(ctx, fmt.Sprintf("PRAGMA foreign_keys = %d", foreignKeys))
+
+	for _, m := range pending {
+		if err := runMigration(ctx, conn, m, up); err != nil {
+			direction := "apply"
+			if !up {
+				direction = "undo"
+			}
+			return current, fmt.Errorf("failed to %s migration %d %s: %w", direction, m.Version, m.Name, err)
+		}
+	}
+	return current, nil
+}
+
+// runMigration applies or undoes one migration and records it.
+func runMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if up {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name)
+	} else {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Options are the settings every connection to a database is opened with.
+type Options struct {
+	// JournalMode is the journal mode, such as WAL or DELETE.
+	JournalMode string
+	// Synchronous is how often SQLite waits for writes to reach the disk:
+	// OFF, NORMAL, FULL or EXTRA.
+	Synchronous string
+	// BusyTimeout is how long a connection waits for a lock held by
+	// another before failing with SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// ForeignKeys enforces foreign keys, which cascade deletes.
+	ForeignKeys bool
+}
+
+// DefaultOptions suit a database shared by concurrent requests: WAL lets
+// reads run alongside a write, and with it NORMAL synchronous only risks
+// the last transactions on power loss, never corruption.
+func DefaultOptions() Options {
+	return Options{
+		JournalMode: "WAL",
+		Synchronous: "NORMAL",
+		BusyTimeout: 5 * time.Second,
+		ForeignKeys: true,
+	}
+}
+
+var (
+	journalModes  = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	synchronouses = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// oneOf reports whether value is one of values, ignoring case.
+func oneOf(value string, values []string) bool {
+	for _, v := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports whether the options are ones SQLite accepts.
+func (o Options) Validate() error {
+	if !oneOf(o.JournalMode, journalModes) {
+		return fmt.Errorf("unknown journal mode %q", o.JournalMode)
+	}
+	if !oneOf(o.Synchronous, synchronouses) {
+		return fmt.Errorf("unknown synchronous setting %q", o.Synchronous)
+	}
+	if o.BusyTimeout < 0 {
+		return fmt.Errorf("busy timeout can't be negative")
+	}
+	return nil
+}
+
+// DSN returns the data source name opening a database file with the
+// options. The driver runs the pragmas on every connection it opens, where
+// running them once on a *sql.DB would only reach one of its connections.
+func (o Options) DSN(path string) string {
+	foreignKeys := 0
+	if o.ForeignKeys {
+		foreignKeys = 1
+	}
+	// busy_timeout goes first so the others wait out a locked database
+	pragmas := url.Values{"_pragma": {
+		fmt.Sprintf("busy_timeout(%d)", o.BusyTimeout.Milliseconds()),
+		fmt.Sprintf("journal_mode(%s)", strings.ToUpper(o.JournalMode)),
+		fmt.Sprintf("synchronous(%s)", strings.ToUpper(o.Synchronous)),
+		fmt.Sprintf("foreign_keys(%d)", foreignKeys),
+	}}
+	// As a URI the path is escaped, so characters such as ? and # in it
+	// stay part of the file name
+	dsn := url.URL{Scheme: "file", Path: path, OmitHost: true, RawQuery: pragmas.Encode()}
+	return dsn.String()
+}
+
+// Open opens a database file with the options. It connects once so that
+// a file that can't be opened, or options it rejects, fail here.
+func Open(path string, opts Options) (*sql.DB, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", opts.DSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
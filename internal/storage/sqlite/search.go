@@ -0,0 +1,263 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// v5SearchIndexes holds the full-text indexes and the triggers that keep
+// them in sync with their tables, as created by the search_indexes
+// migration. Like its other DDL it never changes.
+const v5SearchIndexes = `
+-- Full-text index of prompts
+CREATE VIRTUAL TABLE IF NOT EXISTS prompts_fts USING fts5(
+	id UNINDEXED,
+	name,
+	content,
+	variable_values
+);
+
+CREATE TRIGGER IF NOT EXISTS prompts_fts_insert AFTER INSERT ON prompts BEGIN
+	INSERT INTO prompts_fts (id, name, content, variable_values)
+	VALUES (new.id, new.name, new.content, new.variable_values);
+END;
+
+CREATE TRIGGER IF NOT EXISTS prompts_fts_update AFTER UPDATE ON prompts BEGIN
+	DELETE FROM prompts_fts WHERE id = old.id;
+	INSERT INTO prompts_fts (id, name, content, variable_values)
+	VALUES (new.id, new.name, new.content, new.variable_values);
+END;
+
+CREATE TRIGGER IF NOT EXISTS prompts_fts_delete AFTER DELETE ON prompts BEGIN
+	DELETE FROM prompts_fts WHERE id = old.id;
+END;
+
+-- Full-text index of every template version
+CREATE VIRTUAL TABLE IF NOT EXISTS templates_fts USING fts5(
+	id UNINDEXED,
+	version UNINDEXED,
+	name,
+	task,
+	answer_guideline
+);
+
+CREATE TRIGGER IF NOT EXISTS templates_fts_insert AFTER INSERT ON prompt_templates BEGIN
+	INSERT INTO templates_fts (id, version, name, task, answer_guideline)
+	VALUES (new.id, new.version, new.name, new.task, new.answer_guideline);
+END;
+
+CREATE TRIGGER IF NOT EXISTS templates_fts_update AFTER UPDATE ON prompt_templates BEGIN
+	DELETE FROM templates_fts WHERE id = old.id AND version = old.version;
+	INSERT INTO templates_fts (id, version, name, task, answer_guideline)
+	VALUES (new.id, new.version, new.name, new.task, new.answer_guideline);
+END;
+
+CREATE TRIGGER IF NOT EXISTS templates_fts_delete AFTER DELETE ON prompt_templates BEGIN
+	DELETE FROM templates_fts WHERE id = old.id AND version = old.version;
+END;
+
+-- Full-text index of personas
+CREATE VIRTUAL TABLE IF NOT EXISTS personas_fts USING fts5(
+	id UNINDEXED,
+	user_role_display,
+	llm_role_display
+);
+
+CREATE TRIGGER IF NOT EXISTS personas_fts_insert AFTER INSERT ON personas BEGIN
+	INSERT INTO personas_fts (id, user_role_display, llm_role_display)
+	VALUES (new.id, new.user_role_display, new.llm_role_display);
+END;
+
+CREATE TRIGGER IF NOT EXISTS personas_fts_update AFTER UPDATE ON personas BEGIN
+	DELETE FROM personas_fts WHERE id = old.id;
+	INSERT INTO personas_fts (id, user_role_display, llm_role_display)
+	VALUES (new.id, new.user_role_display, new.llm_role_display);
+END;
+
+CREATE TRIGGER IF NOT EXISTS personas_fts_delete AFTER DELETE ON personas BEGIN
+	DELETE FROM personas_fts WHERE id = old.id;
+END;
+`
+
+// searchIndexes pairs each table with its full-text index and the columns
+// copied into it.
+var searchIndexes = []struct {
+	table, index, columns string
+}{
+	{"prompts", "prompts_fts", "id, name, content, variable_values"},
+	{"prompt_templates", "templates_fts", "id, version, name, task, answer_guideline"},
+	{"personas", "personas_fts", "id, user_role_display, llm_role_display"},
+}
+
+// syncSearchIndexes refills any full-text index that is out of step with
+// its table, such as when a database predates the indexes.
+func syncSearchIndexes(db *sql.DB) error {
+	for _, idx := range searchIndexes {
+		var rows, indexed int
+		if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", idx.table)).Scan(&rows); err != nil {
+			return fmt.Errorf("failed to count %s: %w", idx.table, err)
+		}
+		if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", idx.index)).Scan(&indexed); err != nil {
+			return fmt.Errorf("failed to count %s: %w", idx.index, err)
+		}
+		if rows == indexed {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		statements := []string{
+			fmt.Sprintf("DELETE FROM %s", idx.index),
+			fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", idx.index, idx.columns, idx.columns, idx.table),
+		}
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to rebuild %s: %w", idx.index, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchQuery turns free text into an FTS5 query matching rows that contain
+// every word, each as a prefix. Words are quoted so FTS5 syntax in the text
+// is searched for rather than interpreted.
+func matchQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Markers FTS5 puts around matched terms in search results, and the
+// ellipsis marking text left out of snippets. The markers are private-use
+// characters so that markHTML can escape the text before turning them into
+// <mark> tags.
+const (
+	matchOpen      = "\uE000"
+	matchClose     = "\uE001"
+	highlightOpen  = "'" + matchOpen + "'"
+	highlightClose = "'" + matchClose + "'"
+	snippetArgs    = highlightOpen + ", " + highlightClose + ", '…', 16"
+)
+
+// markHTML HTML-escapes a highlighted name or snippet and wraps its matched
+// terms in <mark> and </mark>.
+func markHTML(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, matchOpen, "<mark>")
+	return strings.ReplaceAll(text, matchClose, "</mark>")
+}
+
+// Search returns the prompts, templates and personas matching query, ranked
+// by BM25 with matches in names counting most. Only the latest version of
+// each template is searched.
+func (s *SQLiteStorage) Search(query, profileID string, types []string, limit int) ([]*models.SearchResult, error) {
+	match := matchQuery(query)
+	if match == "" {
+		return nil, fmt.Errorf("search query has no words")
+	}
+
+	wanted := make(map[string]bool)
+	for _, t := range types {
+		wanted[t] = true
+	}
+	all := len(wanted) == 0
+
+	var selects []string
+	var args []interface{}
+
+	if all || wanted[models.SearchTypePrompt] {
+		q := `SELECT 'prompt' AS type, p.id, 0 AS version,
+				highlight(prompts_fts, 1, ` + highlightOpen + `, ` + highlightClose + `) AS name,
+				snippet(prompts_fts, -1, ` + snippetArgs + `) AS snippet,
+				bm25(prompts_fts, 0, 5, 1, 1) AS rank
+			FROM prompts_fts JOIN prompts p ON p.id = prompts_fts.id
+			WHERE prompts_fts MATCH ? AND p.deleted_at IS NULL`
+		args = append(args, match)
+		if profileID != "" {
+			q += " AND p.profile_id = ?"
+			args = append(args, profileID)
+		}
+		selects = append(selects, q)
+	}
+
+	if all || wanted[models.SearchTypeTemplate] {
+		q := `SELECT 'template' AS type, pt.id, pt.version,
+				highlight(templates_fts, 2, ` + highlightOpen + `, ` + highlightClose + `) AS name,
+				snippet(templates_fts, -1, ` + snippetArgs + `) AS snippet,
+				bm25(templates_fts, 0, 0, 5, 1, 1) AS rank
+			FROM templates_fts
+			JOIN prompt_templates pt ON pt.id = templates_fts.id AND pt.version = templates_fts.version
+			LEFT JOIN personas p ON pt.persona_id = p.id
+			WHERE templates_fts MATCH ? AND pt.deleted_at IS NULL
+			AND pt.version = (SELECT MAX(version) FROM prompt_templates WHERE id = pt.id AND deleted_at IS NULL)`
+		args = append(args, match)
+		if profileID != "" {
+			q += " AND (pt.profile_id = ? OR p.profile_id = ?)"
+			args = append(args, profileID, profileID)
+		}
+		selects = append(selects, q)
+	}
+
+	if all || wanted[models.SearchTypePersona] {
+		q := `SELECT 'persona' AS type, p.id, 0 AS version,
+				highlight(personas_fts, 1, ` + highlightOpen + `, ` + highlightClose + `) || ' / ' ||
+				highlight(personas_fts, 2, ` + highlightOpen + `, ` + highlightClose + `) AS name,
+				snippet(personas_fts, -1, ` + snippetArgs + `) AS snippet,
+				bm25(personas_fts, 0, 5, 5) AS rank
+			FROM personas_fts JOIN personas p ON p.id = personas_fts.id
+			WHERE personas_fts MATCH ? AND p.deleted_at IS NULL`
+		args = append(args, match)
+		if profileID != "" {
+			q += " AND (p.profile_id = ? OR p.profile_id = ?)"
+			args = append(args, profileID, defaultProfileID)
+		}
+		selects = append(selects, q)
+	}
+
+	if len(selects) == 0 {
+		return []*models.SearchResult{}, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(strings.Join(selects, " UNION ALL ")+" ORDER BY rank LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		var name, snippet sql.NullString
+		var rank float64
+		if err := rows.Scan(&result.Type, &result.ID, &result.Version, &name, &snippet, &rank); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.Name = markHTML(name.String)
+		result.Snippet = markHTML(snippet.String)
+		// BM25 is lower for better matches
+		result.Score = -rank
+		results = append(results, &result)
+	}
+
+	return results, rows.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
@@ -11,7 +12,10 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-const Schema = `
+// v1InitialSchema is the schema the initial_schema migration creates. Like
+// the DDL of every migration it is frozen once released: changes to the
+// schema are new migrations.
+const v1InitialSchema = `
 -- Profiles table - stores user-defined personas
 CREATE TABLE IF NOT EXISTS profiles (
 	id TEXT PRIMARY KEY,
@@ -27,15 +31,19 @@ CREATE TABLE IF NOT EXISTS personas (
 	id TEXT PRIMARY KEY,
 	user_role_display TEXT NOT NULL,
 	llm_role_display TEXT NOT NULL,
+	meta_role_template TEXT, -- Template for the meta role of this persona's templates
+	deleted_at DATETIME, -- Set while the persona is in the trash
+	deletion_id TEXT, -- Trash entry the persona was deleted under
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	profile_id TEXT,
 	FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
 );
 
--- Prompt templates table - stores reusable prompt templates with variables
+-- Prompt templates table - stores reusable prompt templates with variables.
+-- Every version of a template is its own row sharing the template's id.
 CREATE TABLE IF NOT EXISTS prompt_templates (
-	id TEXT PRIMARY KEY,
+	id TEXT NOT NULL,
 	name TEXT,
 	persona_id TEXT NOT NULL,
 	version INTEGER NOT NULL DEFAULT 1,
@@ -44,9 +52,16 @@ CREATE TABLE IF NOT EXISTS prompt_templates (
 	answer_guideline TEXT,
 	template TEXT NOT NULL,
 	variables TEXT NOT NULL, -- JSON array of variable names
+	variable_schema TEXT, -- JSON array of variable specs
+	manual_meta_role INTEGER NOT NULL DEFAULT 0, -- 1 when meta_role is hand-written
+	intent TEXT, -- Intent whose system prompt goes into generated prompts
+	folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL, -- Same for every version
+	deleted_at DATETIME, -- Set while the version is in the trash
+	deletion_id TEXT, -- Trash entry the version was deleted under
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	profile_id TEXT,
+	PRIMARY KEY (id, version),
 	FOREIGN KEY (persona_id) REFERENCES personas(id) ON DELETE CASCADE,
 	FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
 );
@@ -59,38 +74,134 @@ CREATE TABLE IF NOT EXISTS prompts (
 	template_version INTEGER NOT NULL DEFAULT 1,
 	variable_values TEXT NOT NULL, -- JSON object with variable values
 	content TEXT NOT NULL, -- Final generated prompt content
+	intent TEXT, -- Intent chosen for this prompt, overriding the template's
+	folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL,
+	deleted_at DATETIME, -- Set while the prompt is in the trash
+	deletion_id TEXT, -- Trash entry the prompt was deleted under
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	profile_id TEXT,
+	FOREIGN KEY (template_id, template_version) REFERENCES prompt_templates(id, version) ON DELETE CASCADE,
+	FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
+);
+
+-- Intents table - built-in intents seeded from the intent master file and
+-- the user's custom intents
+CREATE TABLE IF NOT EXISTS intents (
+	intent TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT,
+	system_prompt TEXT,
+	keywords TEXT NOT NULL DEFAULT '[]', -- JSON array of keywords
+	tag TEXT,
+	custom INTEGER NOT NULL DEFAULT 0, -- 1 when added by the user
+	overridden INTEGER NOT NULL DEFAULT 0, -- 1 when a built-in intent was changed by the user
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Tags table - user-defined labels for prompts and templates
+CREATE TABLE IF NOT EXISTS tags (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	color TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	profile_id TEXT,
+	FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
+);
+
+-- Tag names are unique within a profile, ignoring case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags(COALESCE(profile_id, ''), name COLLATE NOCASE);
+
+-- Prompt tags table - links prompts to their tags
+CREATE TABLE IF NOT EXISTS prompt_tags (
+	prompt_id TEXT NOT NULL,
+	tag_id TEXT NOT NULL,
+	PRIMARY KEY (prompt_id, tag_id),
+	FOREIGN KEY (prompt_id) REFERENCES prompts(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- Template tags table - links templates to their tags. Tags apply to every
+-- version, so template_id can't reference a single prompt_templates row.
+CREATE TABLE IF NOT EXISTS template_tags (
+	template_id TEXT NOT NULL,
+	tag_id TEXT NOT NULL,
+	PRIMARY KEY (template_id, tag_id),
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- Folders table - a tree of folders holding prompts and templates
+CREATE TABLE IF NOT EXISTS folders (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	parent_id TEXT, -- NULL for top-level folders
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	profile_id TEXT,
-	FOREIGN KEY (template_id) REFERENCES prompt_templates(id) ON DELETE CASCADE,
+	FOREIGN KEY (parent_id) REFERENCES folders(id) ON DELETE CASCADE,
 	FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
 );
 
+-- Item usage table - how often each prompt and template was rendered or
+-- copied, when it was last used and whether it is starred. Template usage
+-- covers every version.
+CREATE TABLE IF NOT EXISTS item_usage (
+	item_type TEXT NOT NULL, -- 'prompt' or 'template'
+	item_id TEXT NOT NULL,
+	render_count INTEGER NOT NULL DEFAULT 0,
+	copy_count INTEGER NOT NULL DEFAULT 0,
+	last_used_at DATETIME,
+	starred_at DATETIME, -- NULL unless starred
+	PRIMARY KEY (item_type, item_id)
+);
+
+-- Trash table - one entry per delete of a persona, template version or
+-- prompt. The rows it deleted, including cascaded ones, carry its id in
+-- deletion_id until they are restored or purged.
+CREATE TABLE IF NOT EXISTS trash (
+	id TEXT PRIMARY KEY,
+	item_type TEXT NOT NULL, -- 'persona', 'template' or 'prompt'
+	item_id TEXT NOT NULL,
+	version INTEGER, -- Template version, for templates
+	name TEXT,
+	profile_id TEXT,
+	deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Settings table - stores per-user key/value settings such as the active profile
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Indexes for better query performance
 CREATE INDEX IF NOT EXISTS idx_personas_user_role ON personas(user_role_display);
 CREATE INDEX IF NOT EXISTS idx_personas_llm_role ON personas(llm_role_display);
 CREATE INDEX IF NOT EXISTS idx_templates_persona ON prompt_templates(persona_id);
-CREATE INDEX IF NOT EXISTS idx_prompts_template ON prompts(template_id);
+CREATE INDEX IF NOT EXISTS idx_prompts_template ON prompts(template_id, template_version);
+CREATE INDEX IF NOT EXISTS idx_prompt_tags_tag ON prompt_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_template_tags_tag ON template_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_folders_parent ON folders(parent_id);
 `
 
 type SQLiteStorage struct {
-	db   *sql.DB
+	// db runs statements: conn, or tx within WithTx
+	db   querier
+	conn *sql.DB
+	tx   *sql.Tx
 	mu   sync.RWMutex
 	path string
 }
 
 // NewSQLiteStorage creates a new SQLite storage instance by opening a new DB connection
+// with DefaultOptions
 func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-
-	// Enable foreign keys
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	db, err := Open(dbPath, DefaultOptions())
 	if err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+		return nil, err
 	}
 
 	// Initialize schema if it doesn't exist
@@ -101,6 +212,7 @@ func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
 
 	return &SQLiteStorage{
 		db:   db,
+		conn: db,
 		path: dbPath,
 	}, nil
 }
@@ -108,7 +220,8 @@ func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
 // NewSQLiteStorageWithDB creates a new SQLite storage instance from an existing DB connection
 func NewSQLiteStorageWithDB(db *sql.DB) *SQLiteStorage {
 	return &SQLiteStorage{
-		db: db,
+		db:   db,
+		conn: db,
 	}
 }
 
@@ -116,18 +229,103 @@ func NewSQLiteStorageWithDB(db *sql.DB) *SQLiteStorage {
 // Note: This should only be called if the storage instance was created with NewSQLiteStorage.
 func (s *SQLiteStorage) Close() error {
 	if s.path != "" { // Only close DBs opened by NewSQLiteStorage
-		return s.db.Close()
+		return s.conn.Close()
 	}
 	return nil
 }
 
-// InitializeSchema creates the database schema on a given DB connection
+// InitializeSchema brings a database's schema up to date, applying any
+// pending migrations, and repairs full-text indexes that fell out of step.
 func InitializeSchema(db *sql.DB) error {
-	_, err := db.Exec(Schema)
+	if _, err := Migrate(db); err != nil {
+		return err
+	}
+	return syncSearchIndexes(db)
+}
+
+// returningTimestamps ends inserts and updates that report the row's
+// timestamps back.
+const returningTimestamps = `RETURNING created_at, updated_at`
+
+// returningPlacement also reports the folder of updated prompts and
+// templates, which updates leave alone.
+const returningPlacement = returningTimestamps + `, folder_id`
+
+// defaultProfileID is the profile new items belong to when no other is
+// chosen. Its row exists only so those items satisfy the foreign key to
+// profiles; profile reads and writes leave it alone.
+const defaultProfileID = "00000000-0000-0000-0000-000000000000"
+
+// profileArg returns a profile ID as a query argument, NULL for none, since
+// an empty ID would fail the foreign key to profiles.
+func profileArg(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// templateColumns lists the columns scanTemplate expects, in order.
+var templateColumns = []string{"id", "name", "persona_id", "version", "meta_role", "task", "answer_guideline", "template", "variables", "profile_id", "variable_schema", "manual_meta_role", "intent", "folder_id", "created_at", "updated_at"}
+
+// selectTemplateColumns returns templateColumns qualified with a table alias.
+func selectTemplateColumns(alias string) string {
+	cols := make([]string, len(templateColumns))
+	for i, c := range templateColumns {
+		if alias != "" {
+			c = alias + "." + c
+		}
+		cols[i] = c
+	}
+	return strings.Join(cols, ", ")
+}
+
+// scanTemplate reads a row selected with selectTemplateColumns.
+func scanTemplate(row rowScanner) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	var idStr, personaIDStr, variablesJSON string
+	var profileID, schemaJSON, intent, folderID sql.NullString
+	err := row.Scan(&idStr, &template.Name, &personaIDStr, &template.Version, &template.MetaRole, &template.Task, &template.AnswerGuideline, &template.Template, &variablesJSON, &profileID, &schemaJSON, &template.ManualMetaRole, &intent, &folderID, &template.CreatedAt, &template.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+		return nil, err
 	}
-	return nil
+
+	template.ID = uuid.MustParse(idStr)
+	template.PersonaID = uuid.MustParse(personaIDStr)
+	if profileID.Valid {
+		template.ProfileID = profileID.String
+	}
+	template.Intent = intent.String
+	template.FolderID = parseFolderID(folderID)
+
+	if err := json.Unmarshal([]byte(variablesJSON), &template.Variables); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal variables: %w", err)
+	}
+	if schemaJSON.Valid && schemaJSON.String != "" {
+		if err := json.Unmarshal([]byte(schemaJSON.String), &template.VariableSchema); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal variable schema: %w", err)
+		}
+	}
+
+	return &template, nil
+}
+
+// marshalTemplateJSON encodes the JSON-valued template columns.
+func marshalTemplateJSON(template *models.PromptTemplate) (variables, schema string, err error) {
+	variablesJSON, err := json.Marshal(template.Variables)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal variables: %w", err)
+	}
+	schemaJSON, err := json.Marshal(template.VariableSchema)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal variable schema: %w", err)
+	}
+	return string(variablesJSON), string(schemaJSON), nil
 }
 
 // Persona operations
@@ -137,8 +335,8 @@ func (s *SQLiteStorage) CreatePersona(persona *models.Persona) (*models.Persona,
 
 	persona.ID = uuid.New()
 
-	query := `INSERT INTO personas (id, user_role_display, llm_role_display, profile_id) VALUES (?, ?, ?, ?)`
-	_, err := s.db.Exec(query, persona.ID.String(), persona.UserRoleDisplay, persona.LLMRoleDisplay, persona.ProfileID)
+	query := `INSERT INTO personas (id, user_role_display, llm_role_display, profile_id, meta_role_template) VALUES (?, ?, ?, ?, ?) ` + returningTimestamps
+	err := s.db.QueryRow(query, persona.ID.String(), persona.UserRoleDisplay, persona.LLMRoleDisplay, profileArg(persona.ProfileID), persona.MetaRoleTemplate).Scan(&persona.CreatedAt, &persona.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create persona: %w", err)
 	}
@@ -150,11 +348,11 @@ func (s *SQLiteStorage) GetAllPersonas(profileID string) ([]*models.Persona, err
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `SELECT id, user_role_display, llm_role_display, profile_id FROM personas`
+	query := `SELECT ` + personaColumns + ` FROM personas WHERE deleted_at IS NULL`
 	args := []interface{}{}
 
 	if profileID != "" {
-		query += " WHERE profile_id = ? OR profile_id = '00000000-0000-0000-0000-000000000000'"
+		query += " AND (profile_id = ? OR profile_id = '00000000-0000-0000-0000-000000000000')"
 		args = append(args, profileID)
 	}
 
@@ -168,86 +366,82 @@ func (s *SQLiteStorage) GetAllPersonas(profileID string) ([]*models.Persona, err
 
 	var personas []*models.Persona
 	for rows.Next() {
-		var persona models.Persona
-		var idStr string
-		var dbProfileID sql.NullString // Use sql.NullString for nullable profile_id
-		err := rows.Scan(&idStr, &persona.UserRoleDisplay, &persona.LLMRoleDisplay, &dbProfileID)
+		persona, err := scanPersona(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan persona: %w", err)
 		}
-		persona.ID = uuid.MustParse(idStr)
-		if dbProfileID.Valid {
-			persona.ProfileID = dbProfileID.String
-		}
-		personas = append(personas, &persona)
+		personas = append(personas, persona)
 	}
 
 	return personas, nil
 }
 
-func (s *SQLiteStorage) GetPersonaByID(id uuid.UUID) (*models.Persona, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// personaColumns lists the columns scanPersona expects, in order.
+const personaColumns = `id, user_role_display, llm_role_display, profile_id, meta_role_template, created_at, updated_at`
 
+// scanPersona reads a persona from a row selected with personaColumns.
+func scanPersona(row rowScanner) (*models.Persona, error) {
 	var persona models.Persona
 	var idStr string
-	var profileID sql.NullString
-	query := `SELECT id, user_role_display, llm_role_display, profile_id FROM personas WHERE id = ?`
-	err := s.db.QueryRow(query, id.String()).Scan(&idStr, &persona.UserRoleDisplay, &persona.LLMRoleDisplay, &profileID)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("persona not found")
-	}
+	var profileID, metaRoleTemplate sql.NullString // profile_id is nullable
+	err := row.Scan(&idStr, &persona.UserRoleDisplay, &persona.LLMRoleDisplay, &profileID, &metaRoleTemplate, &persona.CreatedAt, &persona.UpdatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get persona: %w", err)
+		return nil, err
 	}
 
 	persona.ID = uuid.MustParse(idStr)
 	if profileID.Valid {
 		persona.ProfileID = profileID.String
 	}
+	persona.MetaRoleTemplate = metaRoleTemplate.String
 	return &persona, nil
 }
 
-func (s *SQLiteStorage) UpdatePersona(persona *models.Persona) (*models.Persona, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *SQLiteStorage) GetPersonaByID(id uuid.UUID) (*models.Persona, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	query := `UPDATE personas SET user_role_display = ?, llm_role_display = ?, profile_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	result, err := s.db.Exec(query, persona.UserRoleDisplay, persona.LLMRoleDisplay, persona.ProfileID, persona.ID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to update persona: %w", err)
+	query := `SELECT ` + personaColumns + ` FROM personas WHERE id = ? AND deleted_at IS NULL`
+	persona, err := scanPersona(s.db.QueryRow(query, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("persona not found")
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return nil, fmt.Errorf("persona not found")
+		return nil, fmt.Errorf("failed to get persona: %w", err)
 	}
 
 	return persona, nil
 }
 
-func (s *SQLiteStorage) DeletePersona(id uuid.UUID) error {
+func (s *SQLiteStorage) UpdatePersona(persona *models.Persona) (*models.Persona, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	query := `DELETE FROM personas WHERE id = ?`
-	result, err := s.db.Exec(query, id.String())
-	if err != nil {
-		return fmt.Errorf("failed to delete persona: %w", err)
+	query := `UPDATE personas SET user_role_display = ?, llm_role_display = ?, profile_id = ?, meta_role_template = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL ` + returningTimestamps
+	err := s.db.QueryRow(query, persona.UserRoleDisplay, persona.LLMRoleDisplay, profileArg(persona.ProfileID), persona.MetaRoleTemplate, persona.ID.String()).Scan(&persona.CreatedAt, &persona.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("persona not found")
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("persona not found")
+		return nil, fmt.Errorf("failed to update persona: %w", err)
 	}
 
-	return nil
+	return persona, nil
+}
+
+// DeletePersona moves a persona to the trash, along with its templates and
+// their prompts.
+func (s *SQLiteStorage) DeletePersona(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletion := uuid.New().String()
+	return s.moveToTrash(deletion, models.TrashPersona, "persona not found",
+		`SELECT id, 0, user_role_display, profile_id FROM personas WHERE id = ? AND deleted_at IS NULL`, []interface{}{id.String()},
+		trashStep{"personas", "id = ?", []interface{}{id.String()}},
+		trashStep{"prompt_templates", "persona_id = ?", []interface{}{id.String()}},
+		trashStep{"prompts", "(template_id, template_version) IN (SELECT id, version FROM prompt_templates WHERE deletion_id = ?)", []interface{}{deletion}},
+	)
 }
 
 // Template operations
@@ -258,13 +452,13 @@ func (s *SQLiteStorage) CreateTemplate(template *models.PromptTemplate) (*models
 	template.ID = uuid.New()
 	template.Version = 1 // New templates start at version 1
 
-	variablesJSON, err := json.Marshal(template.Variables)
+	variablesJSON, schemaJSON, err := marshalTemplateJSON(template)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal variables: %w", err)
+		return nil, err
 	}
 
-	query := `INSERT INTO prompt_templates (id, name, persona_id, version, meta_role, task, answer_guideline, template, variables, profile_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err = s.db.Exec(query, template.ID.String(), template.Name, template.PersonaID.String(), template.Version, template.MetaRole, template.Task, template.AnswerGuideline, template.Template, string(variablesJSON), template.ProfileID)
+	query := `INSERT INTO prompt_templates (id, name, persona_id, version, meta_role, task, answer_guideline, template, variables, profile_id, variable_schema, manual_meta_role, intent, folder_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ` + returningTimestamps
+	err = s.db.QueryRow(query, template.ID.String(), template.Name, template.PersonaID.String(), template.Version, template.MetaRole, template.Task, template.AnswerGuideline, template.Template, variablesJSON, profileArg(template.ProfileID), schemaJSON, template.ManualMetaRole, template.Intent, folderArg(template.FolderID)).Scan(&template.CreatedAt, &template.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create template: %w", err)
 	}
@@ -276,16 +470,17 @@ func (s *SQLiteStorage) GetAllTemplates(profileID string) ([]*models.PromptTempl
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `SELECT pt.id, pt.name, pt.persona_id, pt.version, pt.meta_role, pt.task, pt.answer_guideline, pt.template, pt.variables, pt.profile_id 
-			  FROM prompt_templates pt`
+	query := `SELECT ` + selectTemplateColumns("pt") + ` FROM prompt_templates pt`
+	where := " WHERE pt.deleted_at IS NULL"
 	args := []interface{}{}
 
 	if profileID != "" {
-		query += " LEFT JOIN personas p ON pt.persona_id = p.id WHERE pt.profile_id = ? OR p.profile_id = ?"
+		query += " LEFT JOIN personas p ON pt.persona_id = p.id"
+		where += " AND (pt.profile_id = ? OR p.profile_id = ?)"
 		args = append(args, profileID, profileID)
 	}
 
-	query += " ORDER BY pt.created_at"
+	query += where + " ORDER BY pt.created_at"
 
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
@@ -295,25 +490,11 @@ func (s *SQLiteStorage) GetAllTemplates(profileID string) ([]*models.PromptTempl
 
 	var templates []*models.PromptTemplate
 	for rows.Next() {
-		var template models.PromptTemplate
-		var idStr, personaIDStr, variablesJSON string
-		var dbProfileID sql.NullString
-		err := rows.Scan(&idStr, &template.Name, &personaIDStr, &template.Version, &template.MetaRole, &template.Task, &template.AnswerGuideline, &template.Template, &variablesJSON, &dbProfileID)
+		template, err := scanTemplate(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan template: %w", err)
 		}
-
-		template.ID = uuid.MustParse(idStr)
-		template.PersonaID = uuid.MustParse(personaIDStr)
-		if dbProfileID.Valid {
-			template.ProfileID = dbProfileID.String
-		}
-
-		if err := json.Unmarshal([]byte(variablesJSON), &template.Variables); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal variables: %w", err)
-		}
-
-		templates = append(templates, &template)
+		templates = append(templates, template)
 	}
 
 	return templates, nil
@@ -323,11 +504,8 @@ func (s *SQLiteStorage) GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, e
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var template models.PromptTemplate
-	var idStr, personaIDStr, variablesJSON string
-	var profileID sql.NullString
-	query := `SELECT id, name, persona_id, version, meta_role, task, answer_guideline, template, variables, profile_id FROM prompt_templates WHERE id = ? ORDER BY version DESC LIMIT 1`
-	err := s.db.QueryRow(query, id.String()).Scan(&idStr, &template.Name, &personaIDStr, &template.Version, &template.MetaRole, &template.Task, &template.AnswerGuideline, &template.Template, &variablesJSON, &profileID)
+	query := `SELECT ` + selectTemplateColumns("") + ` FROM prompt_templates WHERE id = ? AND deleted_at IS NULL ORDER BY version DESC LIMIT 1`
+	template, err := scanTemplate(s.db.QueryRow(query, id.String()))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("template not found")
 	}
@@ -335,17 +513,50 @@ func (s *SQLiteStorage) GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, e
 		return nil, fmt.Errorf("failed to get template: %w", err)
 	}
 
-	template.ID = uuid.MustParse(idStr)
-	template.PersonaID = uuid.MustParse(personaIDStr)
-	if profileID.Valid {
-		template.ProfileID = profileID.String
+	return template, nil
+}
+
+func (s *SQLiteStorage) GetTemplateVersion(id uuid.UUID, version int) (*models.PromptTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + selectTemplateColumns("") + ` FROM prompt_templates WHERE id = ? AND version = ? AND deleted_at IS NULL`
+	template, err := scanTemplate(s.db.QueryRow(query, id.String(), version))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("template version not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template version: %w", err)
 	}
 
-	if err := json.Unmarshal([]byte(variablesJSON), &template.Variables); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal variables: %w", err)
+	return template, nil
+}
+
+// GetTemplateVersions returns every version of a template, oldest first.
+func (s *SQLiteStorage) GetTemplateVersions(id uuid.UUID) ([]*models.PromptTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + selectTemplateColumns("") + ` FROM prompt_templates WHERE id = ? AND deleted_at IS NULL ORDER BY version`
+	rows, err := s.db.Query(query, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query template versions: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*models.PromptTemplate
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, template)
 	}
 
-	return &template, nil
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("template not found")
+	}
+	return templates, nil
 }
 
 func (s *SQLiteStorage) UpdateTemplate(template *models.PromptTemplate) (*models.PromptTemplate, error) {
@@ -353,24 +564,21 @@ func (s *SQLiteStorage) UpdateTemplate(template *models.PromptTemplate) (*models
 	defer s.mu.Unlock()
 
 	// Update the current version in place
-	variablesJSON, err := json.Marshal(template.Variables)
+	variablesJSON, schemaJSON, err := marshalTemplateJSON(template)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal variables: %w", err)
+		return nil, err
 	}
 
-	query := `UPDATE prompt_templates SET name = ?, persona_id = ?, meta_role = ?, task = ?, answer_guideline = ?, template = ?, variables = ?, profile_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND version = ?`
-	result, err := s.db.Exec(query, template.Name, template.PersonaID.String(), template.MetaRole, template.Task, template.AnswerGuideline, template.Template, string(variablesJSON), template.ProfileID, template.ID.String(), template.Version)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update template: %w", err)
+	query := `UPDATE prompt_templates SET name = ?, persona_id = ?, meta_role = ?, task = ?, answer_guideline = ?, template = ?, variables = ?, profile_id = ?, variable_schema = ?, manual_meta_role = ?, intent = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND version = ? AND deleted_at IS NULL ` + returningPlacement
+	var folderID sql.NullString
+	err = s.db.QueryRow(query, template.Name, template.PersonaID.String(), template.MetaRole, template.Task, template.AnswerGuideline, template.Template, variablesJSON, profileArg(template.ProfileID), schemaJSON, template.ManualMetaRole, template.Intent, template.ID.String(), template.Version).Scan(&template.CreatedAt, &template.UpdatedAt, &folderID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("template version not found")
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return nil, fmt.Errorf("template version not found")
+		return nil, fmt.Errorf("failed to update template: %w", err)
 	}
+	template.FolderID = parseFolderID(folderID)
 
 	return template, nil
 }
@@ -379,53 +587,51 @@ func (s *SQLiteStorage) CreateTemplateVersion(template *models.PromptTemplate) (
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Get the current max version for this template ID
-	var maxVersion int
-	versionQuery := `SELECT MAX(version) FROM prompt_templates WHERE id = ?`
-	err := s.db.QueryRow(versionQuery, template.ID.String()).Scan(&maxVersion)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("template not found")
-	}
+	// Get the current max version for this template ID. Versions in the
+	// trash still count, so a restored version keeps its number.
+	var maxVersion sql.NullInt64
+	var live int
+	versionQuery := `SELECT MAX(version), COUNT(*) FILTER (WHERE deleted_at IS NULL) FROM prompt_templates WHERE id = ?`
+	err := s.db.QueryRow(versionQuery, template.ID.String()).Scan(&maxVersion, &live)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current version: %w", err)
 	}
+	if live == 0 {
+		return nil, fmt.Errorf("template not found")
+	}
 
-	// Create new version
-	template.Version = maxVersion + 1
-	
-	variablesJSON, err := json.Marshal(template.Variables)
+	// Create new version, in the same folder as the others
+	template.Version = int(maxVersion.Int64) + 1
+
+	variablesJSON, schemaJSON, err := marshalTemplateJSON(template)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal variables: %w", err)
+		return nil, err
 	}
 
-	query := `INSERT INTO prompt_templates (id, name, persona_id, version, meta_role, task, answer_guideline, template, variables) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err = s.db.Exec(query, template.ID.String(), template.Name, template.PersonaID.String(), template.Version, template.MetaRole, template.Task, template.AnswerGuideline, template.Template, string(variablesJSON))
+	query := `INSERT INTO prompt_templates (id, name, persona_id, version, meta_role, task, answer_guideline, template, variables, profile_id, variable_schema, manual_meta_role, intent, folder_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT folder_id FROM prompt_templates WHERE id = ? AND version = ?)) ` + returningPlacement
+	var folderID sql.NullString
+	err = s.db.QueryRow(query, template.ID.String(), template.Name, template.PersonaID.String(), template.Version, template.MetaRole, template.Task, template.AnswerGuideline, template.Template, variablesJSON, profileArg(template.ProfileID), schemaJSON, template.ManualMetaRole, template.Intent, template.ID.String(), maxVersion.Int64).Scan(&template.CreatedAt, &template.UpdatedAt, &folderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new template version: %w", err)
 	}
+	template.FolderID = parseFolderID(folderID)
 
 	return template, nil
 }
 
+// DeleteTemplate moves a template version to the trash, along with the
+// prompts generated from it.
 func (s *SQLiteStorage) DeleteTemplate(id uuid.UUID, version int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	query := `DELETE FROM prompt_templates WHERE id = ? AND version = ?`
-	result, err := s.db.Exec(query, id.String(), version)
-	if err != nil {
-		return fmt.Errorf("failed to delete template: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("template version not found")
-	}
-
-	return nil
+	return s.moveToTrash(uuid.New().String(), models.TrashTemplate, "template version not found",
+		`SELECT pt.id, pt.version, pt.name, COALESCE(NULLIF(pt.profile_id, ''), p.profile_id)
+			FROM prompt_templates pt LEFT JOIN personas p ON pt.persona_id = p.id
+			WHERE pt.id = ? AND pt.version = ? AND pt.deleted_at IS NULL`, []interface{}{id.String(), version},
+		trashStep{"prompt_templates", "id = ? AND version = ?", []interface{}{id.String(), version}},
+		trashStep{"prompts", "template_id = ? AND template_version = ?", []interface{}{id.String(), version}},
+	)
 }
 
 // Prompt operations
@@ -440,15 +646,9 @@ func (s *SQLiteStorage) Create(prompt *models.Prompt) (*models.Prompt, error) {
 		return nil, fmt.Errorf("failed to marshal values: %w", err)
 	}
 
-	query := `INSERT INTO prompts (id, name, template_id, template_version, variable_values, content, profile_id) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	
-	// Log the SQL statement
-	fmt.Println("--- SQL Statement ---")
-	fmt.Printf("Query: %s\n", query)
-	fmt.Printf("Args: %v\n", []interface{}{prompt.ID.String(), prompt.Name, prompt.TemplateID.String(), prompt.TemplateVersion, string(valuesJSON), prompt.Content, prompt.ProfileID})
-	fmt.Println("---------------------")
+	query := `INSERT INTO prompts (id, name, template_id, template_version, variable_values, content, profile_id, intent, folder_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = s.db.Exec(query, prompt.ID.String(), prompt.Name, prompt.TemplateID.String(), prompt.TemplateVersion, string(valuesJSON), prompt.Content, prompt.ProfileID)
+	err = s.db.QueryRow(query+" "+returningTimestamps, prompt.ID.String(), prompt.Name, prompt.TemplateID.String(), prompt.TemplateVersion, string(valuesJSON), prompt.Content, profileArg(prompt.ProfileID), prompt.Intent, folderArg(prompt.FolderID)).Scan(&prompt.CreatedAt, &prompt.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prompt: %w", err)
 	}
@@ -461,15 +661,40 @@ func (s *SQLiteStorage) GetAll(profileID string) ([]*models.Prompt, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `SELECT id, name, template_id, template_version, variable_values, content, profile_id FROM prompts`
+	query := `SELECT ` + promptColumns + ` FROM prompts WHERE deleted_at IS NULL`
 	args := []interface{}{}
 
 	if profileID != "" {
-		query += " WHERE profile_id = ?"
+		query += " AND profile_id = ?"
 		args = append(args, profileID)
 	}
 
 	query += " ORDER BY created_at"
+	return s.queryPrompts(query, args...)
+}
+
+// GetStalePrompts returns prompts generated from an older version of their
+// template than the latest one.
+func (s *SQLiteStorage) GetStalePrompts(profileID string) ([]*models.Prompt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + promptColumns + ` FROM prompts
+		WHERE deleted_at IS NULL
+		AND template_version < (SELECT MAX(version) FROM prompt_templates pt WHERE pt.id = prompts.template_id AND pt.deleted_at IS NULL)`
+	args := []interface{}{}
+
+	if profileID != "" {
+		query += " AND profile_id = ?"
+		args = append(args, profileID)
+	}
+
+	query += " ORDER BY created_at"
+	return s.queryPrompts(query, args...)
+}
+
+// queryPrompts runs a query selecting promptColumns and scans every row.
+func (s *SQLiteStorage) queryPrompts(query string, args ...interface{}) ([]*models.Prompt, error) {
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query prompts: %w", err)
@@ -478,44 +703,26 @@ func (s *SQLiteStorage) GetAll(profileID string) ([]*models.Prompt, error) {
 
 	var prompts []*models.Prompt
 	for rows.Next() {
-		var prompt models.Prompt
-		var idStr, templateIDStr, valuesJSON string
-		var dbProfileID sql.NullString
-		err := rows.Scan(&idStr, &prompt.Name, &templateIDStr, &prompt.TemplateVersion, &valuesJSON, &prompt.Content, &dbProfileID)
+		prompt, err := scanPrompt(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan prompt: %w", err)
 		}
-
-		prompt.ID = uuid.MustParse(idStr)
-		prompt.TemplateID = uuid.MustParse(templateIDStr)
-		if dbProfileID.Valid {
-			prompt.ProfileID = dbProfileID.String
-		}
-
-		if err := json.Unmarshal([]byte(valuesJSON), &prompt.Values); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal values: %w", err)
-		}
-
-		prompts = append(prompts, &prompt)
+		prompts = append(prompts, prompt)
 	}
 
 	return prompts, nil
 }
 
-func (s *SQLiteStorage) GetByID(id uuid.UUID) (*models.Prompt, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// promptColumns lists the columns scanPrompt expects, in order.
+const promptColumns = `id, name, template_id, template_version, variable_values, content, profile_id, intent, folder_id, created_at, updated_at`
 
+// scanPrompt reads a prompt from a row selected with promptColumns.
+func scanPrompt(row rowScanner) (*models.Prompt, error) {
 	var prompt models.Prompt
 	var idStr, templateIDStr, valuesJSON string
-	var profileID sql.NullString
-	query := `SELECT id, name, template_id, template_version, variable_values, content, profile_id FROM prompts WHERE id = ?`
-	err := s.db.QueryRow(query, id.String()).Scan(&idStr, &prompt.Name, &templateIDStr, &prompt.TemplateVersion, &valuesJSON, &prompt.Content, &profileID)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("prompt not found")
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get prompt: %w", err)
+	var profileID, intent, folderID sql.NullString
+	if err := row.Scan(&idStr, &prompt.Name, &templateIDStr, &prompt.TemplateVersion, &valuesJSON, &prompt.Content, &profileID, &intent, &folderID, &prompt.CreatedAt, &prompt.UpdatedAt); err != nil {
+		return nil, err
 	}
 
 	prompt.ID = uuid.MustParse(idStr)
@@ -523,6 +730,8 @@ func (s *SQLiteStorage) GetByID(id uuid.UUID) (*models.Prompt, error) {
 	if profileID.Valid {
 		prompt.ProfileID = profileID.String
 	}
+	prompt.Intent = intent.String
+	prompt.FolderID = parseFolderID(folderID)
 
 	if err := json.Unmarshal([]byte(valuesJSON), &prompt.Values); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal values: %w", err)
@@ -531,6 +740,22 @@ func (s *SQLiteStorage) GetByID(id uuid.UUID) (*models.Prompt, error) {
 	return &prompt, nil
 }
 
+func (s *SQLiteStorage) GetByID(id uuid.UUID) (*models.Prompt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + promptColumns + ` FROM prompts WHERE id = ? AND deleted_at IS NULL`
+	prompt, err := scanPrompt(s.db.QueryRow(query, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prompt not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt: %w", err)
+	}
+
+	return prompt, nil
+}
+
 func (s *SQLiteStorage) Update(prompt *models.Prompt) (*models.Prompt, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -540,49 +765,36 @@ func (s *SQLiteStorage) Update(prompt *models.Prompt) (*models.Prompt, error) {
 		return nil, fmt.Errorf("failed to marshal values: %w", err)
 	}
 
-	query := `UPDATE prompts SET name = ?, template_id = ?, template_version = ?, variable_values = ?, content = ?, profile_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	result, err := s.db.Exec(query, prompt.Name, prompt.TemplateID.String(), prompt.TemplateVersion, string(valuesJSON), prompt.Content, prompt.ProfileID, prompt.ID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to update prompt: %w", err)
+	query := `UPDATE prompts SET name = ?, template_id = ?, template_version = ?, variable_values = ?, content = ?, profile_id = ?, intent = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL ` + returningPlacement
+	var folderID sql.NullString
+	err = s.db.QueryRow(query, prompt.Name, prompt.TemplateID.String(), prompt.TemplateVersion, string(valuesJSON), prompt.Content, profileArg(prompt.ProfileID), prompt.Intent, prompt.ID.String()).Scan(&prompt.CreatedAt, &prompt.UpdatedAt, &folderID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prompt not found")
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return nil, fmt.Errorf("prompt not found")
+		return nil, fmt.Errorf("failed to update prompt: %w", err)
 	}
+	prompt.FolderID = parseFolderID(folderID)
 
 	return prompt, nil
 }
 
+// Delete moves a prompt to the trash.
 func (s *SQLiteStorage) Delete(id uuid.UUID) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	query := `DELETE FROM prompts WHERE id = ?`
-	result, err := s.db.Exec(query, id.String())
-	if err != nil {
-		return fmt.Errorf("failed to delete prompt: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("prompt not found")
-	}
-
-	return nil
+	return s.moveToTrash(uuid.New().String(), models.TrashPrompt, "prompt not found",
+		`SELECT id, 0, name, profile_id FROM prompts WHERE id = ? AND deleted_at IS NULL`, []interface{}{id.String()},
+		trashStep{"prompts", "id = ?", []interface{}{id.String()}},
+	)
 }
 
 func (s *SQLiteStorage) GetTemplatesByPersonaID(personaID uuid.UUID) ([]*models.PromptTemplate, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `SELECT id, name, persona_id, version, meta_role, task, answer_guideline, template, variables FROM prompt_templates WHERE persona_id = ? ORDER BY created_at`
+	query := `SELECT ` + selectTemplateColumns("") + ` FROM prompt_templates WHERE persona_id = ? AND deleted_at IS NULL ORDER BY created_at`
 	rows, err := s.db.Query(query, personaID.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to query templates by persona: %w", err)
@@ -591,21 +803,11 @@ func (s *SQLiteStorage) GetTemplatesByPersonaID(personaID uuid.UUID) ([]*models.
 
 	var templates []*models.PromptTemplate
 	for rows.Next() {
-		var template models.PromptTemplate
-		var idStr, personaIDStr, variablesJSON string
-		err := rows.Scan(&idStr, &template.Name, &personaIDStr, &template.Version, &template.MetaRole, &template.Task, &template.AnswerGuideline, &template.Template, &variablesJSON)
+		template, err := scanTemplate(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan template: %w", err)
 		}
-
-		template.ID = uuid.MustParse(idStr)
-		template.PersonaID = uuid.MustParse(personaIDStr)
-
-		if err := json.Unmarshal([]byte(variablesJSON), &template.Variables); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal variables: %w", err)
-		}
-
-		templates = append(templates, &template)
+		templates = append(templates, template)
 	}
 
 	return templates, nil
@@ -637,8 +839,8 @@ func (s *SQLiteStorage) GetAllProfiles() ([]*models.Profile, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `SELECT id, name, description, attributes, created_at, updated_at FROM profiles ORDER BY created_at`
-	rows, err := s.db.Query(query)
+	query := `SELECT ` + profileColumns + ` FROM profiles WHERE id != ? ORDER BY created_at`
+	rows, err := s.db.Query(query, defaultProfileID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query profiles: %w", err)
 	}
@@ -646,31 +848,41 @@ func (s *SQLiteStorage) GetAllProfiles() ([]*models.Profile, error) {
 
 	var profiles []*models.Profile
 	for rows.Next() {
-		var profile models.Profile
-		var attributesJSON string
-		err := rows.Scan(&profile.ID, &profile.Name, &profile.Description, &attributesJSON, &profile.CreatedAt, &profile.UpdatedAt)
+		profile, err := scanProfile(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan profile: %w", err)
 		}
+		profiles = append(profiles, profile)
+	}
 
-		if err := json.Unmarshal([]byte(attributesJSON), &profile.Attributes); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal attributes: %w", err)
-		}
+	return profiles, nil
+}
 
-		profiles = append(profiles, &profile)
+// profileColumns lists the columns scanProfile expects, in order.
+const profileColumns = `id, name, description, attributes, created_at, updated_at`
+
+// scanProfile reads a profile from a row selected with profileColumns.
+func scanProfile(row rowScanner) (*models.Profile, error) {
+	var profile models.Profile
+	var attributesJSON string
+	err := row.Scan(&profile.ID, &profile.Name, &profile.Description, &attributesJSON, &profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		return nil, err
 	}
 
-	return profiles, nil
+	if err := json.Unmarshal([]byte(attributesJSON), &profile.Attributes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attributes: %w", err)
+	}
+
+	return &profile, nil
 }
 
 func (s *SQLiteStorage) GetProfileByID(id string) (*models.Profile, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var profile models.Profile
-	var attributesJSON string
-	query := `SELECT id, name, description, attributes, created_at, updated_at FROM profiles WHERE id = ?`
-	err := s.db.QueryRow(query, id).Scan(&profile.ID, &profile.Name, &profile.Description, &attributesJSON, &profile.CreatedAt, &profile.UpdatedAt)
+	query := `SELECT ` + profileColumns + ` FROM profiles WHERE id = ? AND id != ?`
+	profile, err := scanProfile(s.db.QueryRow(query, id, defaultProfileID))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("profile not found")
 	}
@@ -678,11 +890,7 @@ func (s *SQLiteStorage) GetProfileByID(id string) (*models.Profile, error) {
 		return nil, fmt.Errorf("failed to get profile: %w", err)
 	}
 
-	if err := json.Unmarshal([]byte(attributesJSON), &profile.Attributes); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal attributes: %w", err)
-	}
-
-	return &profile, nil
+	return profile, nil
 }
 
 func (s *SQLiteStorage) UpdateProfile(profile *models.Profile) error {
@@ -694,8 +902,8 @@ func (s *SQLiteStorage) UpdateProfile(profile *models.Profile) error {
 		return fmt.Errorf("failed to marshal attributes: %w", err)
 	}
 
-	query := `UPDATE profiles SET name = ?, description = ?, attributes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	result, err := s.db.Exec(query, profile.Name, profile.Description, string(attributesJSON), profile.ID)
+	query := `UPDATE profiles SET name = ?, description = ?, attributes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND id != ?`
+	result, err := s.db.Exec(query, profile.Name, profile.Description, string(attributesJSON), profile.ID, defaultProfileID)
 	if err != nil {
 		return fmt.Errorf("failed to update profile: %w", err)
 	}
@@ -715,18 +923,80 @@ func (s *SQLiteStorage) DeleteProfile(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	query := `DELETE FROM profiles WHERE id = ?`
-	result, err := s.db.Exec(query, id)
+	tx, err := s.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The foreign keys would delete a profile's personas, templates and
+	// prompts for good, trashed or not, so a profile that has any stays.
+	// Its tags and folders go with it.
+	var exists, inUse bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM profiles WHERE id = ?1 AND id != ?2),
+		EXISTS (SELECT 1 FROM personas WHERE profile_id = ?1)
+		OR EXISTS (SELECT 1 FROM prompt_templates WHERE profile_id = ?1)
+		OR EXISTS (SELECT 1 FROM prompts WHERE profile_id = ?1)`, id, defaultProfileID).Scan(&exists, &inUse)
 	if err != nil {
 		return fmt.Errorf("failed to delete profile: %w", err)
 	}
+	if !exists {
+		return fmt.Errorf("profile %w", models.ErrNotFound)
+	}
+	if inUse {
+		return models.ErrProfileInUse
+	}
 
-	rowsAffected, err := result.RowsAffected()
+	if _, err := tx.Exec(`DELETE FROM profiles WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete profile: %w", err)
+	}
+
+	// A deleted profile can't stay active
+	_, err = tx.Exec(`DELETE FROM settings WHERE key = ? AND value = ?`, activeProfileKey, id)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to clear active profile: %w", err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("profile not found")
+
+	return tx.Commit()
+}
+
+// Settings operations
+
+// activeProfileKey is the settings key holding the active profile's ID.
+const activeProfileKey = "active_profile_id"
+
+// GetActiveProfileID returns the active profile's ID, or "" if none is set.
+func (s *SQLiteStorage) GetActiveProfileID() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var id string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, activeProfileKey).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get active profile: %w", err)
+	}
+
+	return id, nil
+}
+
+// SetActiveProfileID makes id the active profile. An empty id clears it.
+func (s *SQLiteStorage) SetActiveProfileID(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if id == "" {
+		_, err = s.db.Exec(`DELETE FROM settings WHERE key = ?`, activeProfileKey)
+	} else {
+		query := `INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`
+		_, err = s.db.Exec(query, activeProfileKey, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set active profile: %w", err)
 	}
 
 	return nil
@@ -2,11 +2,19 @@ package sqlite
 
 import (
 	// "os"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rahulguha/promptly/internal/models"
+	"github.com/rahulguha/promptly/internal/storage/store"
 )
 
 func TestSQLiteStorage_Personas(t *testing.T) {
@@ -35,9 +43,10 @@ func TestSQLiteStorage_Personas(t *testing.T) {
 
 	// Test Create
 	persona := &models.Persona{
-		UserRoleDisplay: "Software Developer",
-		LLMRoleDisplay:  "Code Reviewer",
-		ProfileID:       profile.ID,
+		UserRoleDisplay:  "Software Developer",
+		LLMRoleDisplay:   "Code Reviewer",
+		ProfileID:        profile.ID,
+		MetaRoleTemplate: "I am a {{user_role}}.",
 	}
 
 	created, err := storage.CreatePersona(persona)
@@ -73,6 +82,10 @@ func TestSQLiteStorage_Personas(t *testing.T) {
 		t.Error("Retrieved persona doesn't match created persona")
 	}
 
+	if found.MetaRoleTemplate != persona.MetaRoleTemplate {
+		t.Errorf("Expected meta role template %q, got %q", persona.MetaRoleTemplate, found.MetaRoleTemplate)
+	}
+
 	// Test Update
 	found.LLMRoleDisplay = "Senior Code Reviewer"
 	updated, err := storage.UpdatePersona(found)
@@ -135,7 +148,12 @@ func TestSQLiteStorage_Templates(t *testing.T) {
 		PersonaID: createdPersona.ID,
 		Template:  "Review this {{language}} code for {{focus}}",
 		Variables: []string{"language", "focus"},
-		ProfileID: profile.ID,
+		VariableSchema: []models.VariableSpec{
+			{Name: "focus", Type: models.VariableTypeEnum, Options: []string{"performance", "style"}},
+		},
+		ProfileID:      profile.ID,
+		ManualMetaRole: true,
+		Intent:         "study",
 	}
 
 	created, err := storage.CreateTemplate(template)
@@ -160,6 +178,65 @@ func TestSQLiteStorage_Templates(t *testing.T) {
 	if len(templates[0].Variables) != 2 {
 		t.Errorf("Expected 2 variables, got %d", len(templates[0].Variables))
 	}
+
+	// Test GetTemplateVersion
+	version, err := storage.GetTemplateVersion(created.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get template version: %v", err)
+	}
+
+	if version.Template != template.Template {
+		t.Error("Retrieved template version doesn't match created template")
+	}
+
+	if len(version.VariableSchema) != 1 || len(version.VariableSchema[0].Options) != 2 {
+		t.Errorf("Variable schema not stored correctly: %+v", version.VariableSchema)
+	}
+
+	if !version.ManualMetaRole {
+		t.Error("Expected manual meta role flag to be stored")
+	}
+
+	if version.Intent != "study" {
+		t.Errorf("Expected intent to be stored, got %q", version.Intent)
+	}
+
+	if _, err := storage.GetTemplateVersion(created.ID, 2); err == nil {
+		t.Error("Expected error when getting a version that doesn't exist")
+	}
+
+	// Test CreateTemplateVersion and GetTemplateVersions
+	next := *created
+	next.Template = "Review this {{language}} code"
+	if _, err := storage.CreateTemplateVersion(&next); err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+
+	versions, err := storage.GetTemplateVersions(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get template versions: %v", err)
+	}
+
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Fatalf("Expected versions 1 and 2 oldest first, got %+v", versions)
+	}
+
+	if versions[1].ProfileID != profile.ID {
+		t.Errorf("Expected new version to keep profile %s, got %q", profile.ID, versions[1].ProfileID)
+	}
+
+	latest, err := storage.GetTemplateByID(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get template: %v", err)
+	}
+
+	if latest.Version != 2 {
+		t.Errorf("Expected latest version 2, got %d", latest.Version)
+	}
+
+	if _, err := storage.CreateTemplateVersion(&models.PromptTemplate{ID: uuid.New()}); err == nil {
+		t.Error("Expected error when versioning a template that doesn't exist")
+	}
 }
 
 func TestSQLiteStorage_Prompts(t *testing.T) {
@@ -213,6 +290,7 @@ func TestSQLiteStorage_Prompts(t *testing.T) {
 		Values:          map[string]string{"language": "Go", "focus": "performance"},
 		Content:         "Review this Go code for performance",
 		ProfileID:       profile.ID,
+		Intent:          "research",
 	}
 
 	created, err := storage.Create(prompt)
@@ -238,4 +316,1430 @@ func TestSQLiteStorage_Prompts(t *testing.T) {
 	if prompts[0].Values["language"] != "Go" {
 		t.Error("Values not stored correctly")
 	}
-}
\ No newline at end of file
+
+	if prompts[0].Intent != "research" {
+		t.Errorf("Expected intent to be stored, got %q", prompts[0].Intent)
+	}
+
+	// Test GetStalePrompts
+	stale, err := storage.GetStalePrompts("")
+	if err != nil {
+		t.Fatalf("Failed to get stale prompts: %v", err)
+	}
+
+	if len(stale) != 0 {
+		t.Errorf("Expected no stale prompts, got %d", len(stale))
+	}
+
+	if _, err := storage.CreateTemplateVersion(createdTemplate); err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+
+	stale, err = storage.GetStalePrompts(profile.ID)
+	if err != nil {
+		t.Fatalf("Failed to get stale prompts: %v", err)
+	}
+
+	if len(stale) != 1 || stale[0].ID != created.ID {
+		t.Errorf("Expected prompt %s to be stale, got %+v", created.ID, stale)
+	}
+}
+
+func TestInitializeSchema_AddsMissingColumns(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// A prompt_templates table from before variable_schema existed
+	_, err = db.Exec(`CREATE TABLE prompt_templates (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		persona_id TEXT NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1,
+		meta_role TEXT,
+		task TEXT,
+		answer_guideline TEXT,
+		template TEXT NOT NULL,
+		variables TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		profile_id TEXT
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	if err := InitializeSchema(db); err != nil {
+		t.Fatalf("Failed to initialize schema: %v", err)
+	}
+
+	if _, err := db.Exec(`SELECT variable_schema FROM prompt_templates`); err != nil {
+		t.Errorf("Expected variable_schema column to be added: %v", err)
+	}
+
+	// Running it again must be a no-op
+	if err := InitializeSchema(db); err != nil {
+		t.Fatalf("Failed to re-initialize schema: %v", err)
+	}
+}
+
+func TestInitializeSchema_UpgradesTemplateKey(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Tables from when a template's id alone was its primary key
+	_, err = db.Exec(`
+		CREATE TABLE prompt_templates (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			persona_id TEXT NOT NULL,
+			version INTEGER NOT NULL DEFAULT 1,
+			meta_role TEXT,
+			task TEXT,
+			answer_guideline TEXT,
+			template TEXT NOT NULL,
+			variables TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			profile_id TEXT
+		);
+		CREATE TABLE prompts (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			template_id TEXT NOT NULL,
+			template_version INTEGER NOT NULL DEFAULT 1,
+			variable_values TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			profile_id TEXT,
+			FOREIGN KEY (template_id) REFERENCES prompt_templates(id) ON DELETE CASCADE
+		);
+		INSERT INTO prompt_templates (id, name, persona_id, version, meta_role, task, answer_guideline, template, variables, profile_id)
+		VALUES ('11111111-1111-1111-1111-111111111111', 'Greeting', '22222222-2222-2222-2222-222222222222', 3, '', '', '', 'Hello {{name}}', '["name"]', '');
+		INSERT INTO prompts (id, name, template_id, template_version, variable_values, content, profile_id)
+		VALUES ('33333333-3333-3333-3333-333333333333', '', '11111111-1111-1111-1111-111111111111', 1, '{"name":"world"}', 'Hello world', '');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy tables: %v", err)
+	}
+
+	if err := InitializeSchema(db); err != nil {
+		t.Fatalf("Failed to initialize schema: %v", err)
+	}
+
+	storage := NewSQLiteStorageWithDB(db)
+	id := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+
+	template, err := storage.GetTemplateByID(id)
+	if err != nil {
+		t.Fatalf("Failed to get migrated template: %v", err)
+	}
+
+	if _, err := storage.CreateTemplateVersion(template); err != nil {
+		t.Fatalf("Expected a second version to be allowed after the upgrade: %v", err)
+	}
+
+	versions, err := storage.GetTemplateVersions(id)
+	if err != nil {
+		t.Fatalf("Failed to get template versions: %v", err)
+	}
+
+	if len(versions) != 2 || versions[1].Version != 4 {
+		t.Errorf("Expected versions 3 and 4, got %+v", versions)
+	}
+
+	prompt, err := storage.GetByID(uuid.MustParse("33333333-3333-3333-3333-333333333333"))
+	if err != nil {
+		t.Fatalf("Failed to get migrated prompt: %v", err)
+	}
+
+	if prompt.TemplateVersion != 3 {
+		t.Errorf("Expected prompt to point at the stored version 3, got %d", prompt.TemplateVersion)
+	}
+
+	// Running it again must be a no-op
+	if err := InitializeSchema(db); err != nil {
+		t.Fatalf("Failed to re-initialize schema: %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	from, err := Migrate(db)
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if version, _ := SchemaVersion(db); from != 0 || version != LatestVersion() {
+		t.Fatalf("Expected a fresh database to go from 0 to %d, went from %d to %d", LatestVersion(), from, version)
+	}
+
+	// Migrating again changes nothing
+	if from, err := Migrate(db); err != nil || from != LatestVersion() {
+		t.Fatalf("Expected an up-to-date database to stay at %d, got %d: %v", LatestVersion(), from, err)
+	}
+
+	// Down to before the search indexes, then back up
+	if _, err := MigrateTo(db, 4); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	if version, _ := SchemaVersion(db); version != 4 {
+		t.Errorf("Expected version 4 after migrating down, got %d", version)
+	}
+	if _, err := db.Exec(`SELECT * FROM prompts_fts`); err == nil {
+		t.Error("Expected the search indexes to be dropped")
+	}
+	if _, err := Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate back up: %v", err)
+	}
+	if _, err := db.Exec(`SELECT * FROM prompts_fts`); err != nil {
+		t.Errorf("Expected the search indexes to be recreated: %v", err)
+	}
+
+	// Profile IDs naming no profile, as databases opened without foreign
+	// keys could hold, are cleared and the default profile is added
+	if _, err := MigrateTo(db, 5); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO personas (id, user_role_display, llm_role_display, profile_id) VALUES
+		('p1', 'a', 'b', ''), ('p2', 'a', 'b', 'gone'), ('p3', 'a', 'b', '` + defaultProfileID + `')`)
+	if err != nil {
+		t.Fatalf("Failed to insert personas: %v", err)
+	}
+	if _, err := Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate back up: %v", err)
+	}
+	var detached, attached int
+	db.QueryRow(`SELECT COUNT(*) FROM personas WHERE profile_id IS NULL`).Scan(&detached)
+	db.QueryRow(`SELECT COUNT(*) FROM personas p JOIN profiles ON profiles.id = p.profile_id`).Scan(&attached)
+	if detached != 2 || attached != 1 {
+		t.Errorf("Expected 2 personas detached and 1 in the default profile, got %d and %d", detached, attached)
+	}
+
+	// Down to nothing leaves no tables but schema_migrations
+	if _, err := MigrateTo(db, 0); err != nil {
+		t.Fatalf("Failed to migrate down to 0: %v", err)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations'`).Scan(&tables); err != nil || tables != 0 {
+		t.Errorf("Expected no tables left, got %d: %v", tables, err)
+	}
+
+	if _, err := MigrateTo(db, LatestVersion()+1); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+
+	// A database from a newer build is refused
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'future')`, LatestVersion()+1); err != nil {
+		t.Fatalf("Failed to record a future migration: %v", err)
+	}
+	if _, err := Migrate(db); err == nil {
+		t.Error("Expected an error for a database newer than the build")
+	}
+}
+
+func TestOpen_Pragmas(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Every connection gets the settings, not just the first
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+		defer conn.Close()
+
+		var journalMode string
+		var foreignKeys, synchronous, busyTimeout int
+		conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode)
+		conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys)
+		conn.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous)
+		conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout)
+		if journalMode != "wal" || foreignKeys != 1 || synchronous != 1 || busyTimeout != 5000 {
+			t.Errorf("Connection %d: journal_mode=%s foreign_keys=%d synchronous=%d busy_timeout=%d", i, journalMode, foreignKeys, synchronous, busyTimeout)
+		}
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{JournalMode: "FAST", Synchronous: "NORMAL"}); err == nil {
+		t.Error("Expected an error for an unknown journal mode")
+	}
+}
+
+func TestOpen_PathNeedingEscapes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a?b.db", "c#d.db", "e f%20.db", "g&_pragma=foreign_keys(0).db"} {
+		db, err := Open(filepath.Join(dir, name), DefaultOptions())
+		if err != nil {
+			t.Fatalf("Failed to open %q: %v", name, err)
+		}
+		if _, err := db.Exec("CREATE TABLE t (x INTEGER)"); err != nil {
+			t.Fatalf("Failed to write %q: %v", name, err)
+		}
+		var foreignKeys int
+		db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys)
+		db.Close()
+		if foreignKeys != 1 {
+			t.Errorf("%q: expected the path not to change the options", name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected the database at %q: %v", name, err)
+		}
+	}
+}
+
+func TestSQLiteStorageWithDB_Cascades(t *testing.T) {
+	// Opened the way DBManager opens user databases
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := InitializeSchema(db); err != nil {
+		t.Fatalf("Failed to initialize schema: %v", err)
+	}
+	storage := NewSQLiteStorageWithDB(db)
+
+	// Items can belong to the default profile, or to none
+	for _, profileID := range []string{defaultProfileID, ""} {
+		if _, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Student", LLMRoleDisplay: "Tutor", ProfileID: profileID}); err != nil {
+			t.Errorf("Failed to create a persona in profile %q: %v", profileID, err)
+		}
+	}
+	if profiles, _ := storage.GetAllProfiles(); len(profiles) != 0 {
+		t.Errorf("Expected the default profile to stay hidden, got %d profiles", len(profiles))
+	}
+	if err := storage.DeleteProfile(defaultProfileID); err == nil {
+		t.Error("Expected the default profile not to be deletable")
+	}
+
+	// A reference to a missing row is refused
+	if _, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Student", LLMRoleDisplay: "Tutor", ProfileID: uuid.New().String()}); err == nil {
+		t.Error("Expected a persona in a missing profile to be refused")
+	}
+
+	profile := &models.Profile{Name: "Work"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	persona, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Engineer", LLMRoleDisplay: "Reviewer", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	template, err := storage.CreateTemplate(&models.PromptTemplate{Name: "Review", PersonaID: persona.ID, Template: "Review {{code}}", Variables: []string{"code"}, ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	prompt, err := storage.Create(&models.Prompt{TemplateID: template.ID, TemplateVersion: 1, Values: map[string]string{"code": "x"}, Content: "Review x", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	tag := &models.Tag{Name: "go", ProfileID: profile.ID}
+	if err := storage.CreateTag(tag); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if _, err := storage.SetPromptTags(prompt.ID, []uuid.UUID{tag.ID}); err != nil {
+		t.Fatalf("Failed to tag prompt: %v", err)
+	}
+	parent := &models.Folder{Name: "Code", ProfileID: profile.ID}
+	if err := storage.CreateFolder(parent); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := storage.CreateFolder(&models.Folder{Name: "Reviews", ParentID: &parent.ID, ProfileID: profile.ID}); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	// A profile that still has items, in the library or the trash, stays
+	if err := storage.DeleteProfile(profile.ID); !errors.Is(err, models.ErrProfileInUse) {
+		t.Fatalf("Expected ErrProfileInUse deleting a profile with items, got %v", err)
+	}
+	if err := storage.DeletePersona(persona.ID); err != nil {
+		t.Fatalf("Failed to delete persona: %v", err)
+	}
+	if err := storage.DeleteProfile(profile.ID); !errors.Is(err, models.ErrProfileInUse) {
+		t.Fatalf("Expected ErrProfileInUse deleting a profile with items in the trash, got %v", err)
+	}
+	trash, err := storage.GetTrash("")
+	if err != nil || len(trash) != 1 || trash[0].Cascaded != 2 {
+		t.Fatalf("Expected the persona's delete in the trash with 2 cascaded items, got %+v (%v)", trash, err)
+	}
+
+	// Once they are purged it goes, and its tags and folders with it
+	if err := storage.PurgeTrashItem(trash[0].ID); err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	if err := storage.DeleteProfile(profile.ID); err != nil {
+		t.Fatalf("Failed to delete profile: %v", err)
+	}
+	if err := storage.DeleteProfile(profile.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting the profile again, got %v", err)
+	}
+	for table, want := range map[string]int{"personas": 2, "prompt_templates": 0, "prompts": 0, "tags": 0, "prompt_tags": 0, "folders": 0} {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		if count != want {
+			t.Errorf("Expected %d rows in %s after deleting the profile, got %d", want, table, count)
+		}
+	}
+}
+
+func TestSQLiteStorage_WithTx(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	profile := &models.Profile{Name: "Test Profile"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	// createAll creates a persona, a template and a prompt from it
+	createAll := func(tx *SQLiteStorage) (*models.Persona, error) {
+		persona, err := tx.CreatePersona(&models.Persona{UserRoleDisplay: "Student", LLMRoleDisplay: "Tutor", ProfileID: profile.ID})
+		if err != nil {
+			return nil, err
+		}
+		template, err := tx.CreateTemplate(&models.PromptTemplate{Name: "Explain", PersonaID: persona.ID, Template: "Explain {{topic}}", Variables: []string{"topic"}, ProfileID: profile.ID})
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Create(&models.Prompt{TemplateID: template.ID, TemplateVersion: 1, Values: map[string]string{"topic": "gravity"}, Content: "Explain gravity", ProfileID: profile.ID})
+		return persona, err
+	}
+	counts := func() (personas, templates, prompts int) {
+		storage.db.QueryRow(`SELECT COUNT(*) FROM personas`).Scan(&personas)
+		storage.db.QueryRow(`SELECT COUNT(*) FROM prompt_templates`).Scan(&templates)
+		storage.db.QueryRow(`SELECT COUNT(*) FROM prompts`).Scan(&prompts)
+		return
+	}
+
+	// A step failing halfway rolls back the steps before it
+	err = storage.withTx(func(tx *SQLiteStorage) error {
+		if _, err := createAll(tx); err != nil {
+			return err
+		}
+		_, err := tx.CreateTemplate(&models.PromptTemplate{Name: "Orphan", PersonaID: uuid.New(), Template: "x", ProfileID: profile.ID})
+		return err
+	})
+	if err == nil {
+		t.Fatal("Expected the template of a missing persona to fail")
+	}
+	if personas, templates, prompts := counts(); personas+templates+prompts != 0 {
+		t.Errorf("Expected nothing written, got %d personas, %d templates and %d prompts", personas, templates, prompts)
+	}
+
+	// A panic rolls back too
+	func() {
+		defer func() { recover() }()
+		storage.withTx(func(tx *SQLiteStorage) error {
+			createAll(tx)
+			panic("boom")
+		})
+	}()
+	if personas, _, _ := counts(); personas != 0 {
+		t.Errorf("Expected nothing written after a panic, got %d personas", personas)
+	}
+
+	// Success commits everything, including methods with transactions of
+	// their own, and a failed nested unit only undoes its own writes
+	var persona *models.Persona
+	err = storage.withTx(func(tx *SQLiteStorage) error {
+		var err error
+		if persona, err = createAll(tx); err != nil {
+			return err
+		}
+		nested := tx.WithTx(func(tx store.Storage) error {
+			if err := tx.DeletePersona(persona.ID); err != nil {
+				return err
+			}
+			return fmt.Errorf("changed my mind")
+		})
+		if nested == nil {
+			return fmt.Errorf("expected the nested unit to fail")
+		}
+		tag := &models.Tag{Name: "physics", ProfileID: profile.ID}
+		return tx.CreateTag(tag)
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	if personas, templates, prompts := counts(); personas != 1 || templates != 1 || prompts != 1 {
+		t.Errorf("Expected 1 of each committed, got %d personas, %d templates and %d prompts", personas, templates, prompts)
+	}
+	if _, err := storage.GetPersonaByID(persona.ID); err != nil {
+		t.Errorf("Expected the nested delete to be undone: %v", err)
+	}
+	if trash, _ := storage.GetTrash(""); len(trash) != 0 {
+		t.Errorf("Expected an empty trash, got %d items", len(trash))
+	}
+	if tags, _ := storage.GetAllTags(""); len(tags) != 1 {
+		t.Errorf("Expected the tag to be committed, got %d tags", len(tags))
+	}
+}
+
+func TestSQLiteStorage_ActiveProfile(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	id, err := storage.GetActiveProfileID()
+	if err != nil {
+		t.Fatalf("Failed to get active profile: %v", err)
+	}
+	if id != "" {
+		t.Errorf("Expected no active profile, got %s", id)
+	}
+
+	profile := &models.Profile{Name: "Work"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	other := &models.Profile{Name: "Home"}
+	if err := storage.CreateProfile(other); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	// Setting twice replaces the value
+	for _, p := range []*models.Profile{other, profile} {
+		if err := storage.SetActiveProfileID(p.ID); err != nil {
+			t.Fatalf("Failed to set active profile: %v", err)
+		}
+	}
+	id, _ = storage.GetActiveProfileID()
+	if id != profile.ID {
+		t.Errorf("Expected active profile %s, got %s", profile.ID, id)
+	}
+
+	// Deleting another profile leaves it alone
+	if err := storage.DeleteProfile(other.ID); err != nil {
+		t.Fatalf("Failed to delete profile: %v", err)
+	}
+	id, _ = storage.GetActiveProfileID()
+	if id != profile.ID {
+		t.Errorf("Expected active profile %s after deleting another, got %s", profile.ID, id)
+	}
+
+	// Deleting the active profile clears it
+	if err := storage.DeleteProfile(profile.ID); err != nil {
+		t.Fatalf("Failed to delete profile: %v", err)
+	}
+	id, _ = storage.GetActiveProfileID()
+	if id != "" {
+		t.Errorf("Expected active profile to be cleared, got %s", id)
+	}
+
+	if err := storage.SetActiveProfileID("x"); err != nil {
+		t.Fatalf("Failed to set active profile: %v", err)
+	}
+	if err := storage.SetActiveProfileID(""); err != nil {
+		t.Fatalf("Failed to clear active profile: %v", err)
+	}
+	id, _ = storage.GetActiveProfileID()
+	if id != "" {
+		t.Errorf("Expected cleared active profile, got %s", id)
+	}
+}
+
+func TestSQLiteStorage_Intents(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	master := []models.Intent{
+		{Intent: "study", Name: "Study", SystemPrompt: "You are a tutor.", Keywords: []string{"exam"}, Tag: "learning"},
+		{Intent: "research", Name: "Research", SystemPrompt: "Cite sources."},
+	}
+	if err := storage.SeedIntents(master, "v1"); err != nil {
+		t.Fatalf("Failed to seed intents: %v", err)
+	}
+
+	intents, err := storage.GetAllIntents()
+	if err != nil {
+		t.Fatalf("Failed to get intents: %v", err)
+	}
+	if len(intents) != 2 || intents[0].Intent != "study" || intents[1].Intent != "research" {
+		t.Fatalf("Expected seeded intents in master order, got %+v", intents)
+	}
+	if intents[1].Keywords == nil || len(intents[1].Keywords) != 0 {
+		t.Errorf("Expected empty keywords, got %v", intents[1].Keywords)
+	}
+
+	// Override a built-in intent and add a custom one
+	study, err := storage.GetIntent("study")
+	if err != nil {
+		t.Fatalf("Failed to get intent: %v", err)
+	}
+	study.SystemPrompt = "You are my tutor."
+	study.Overridden = true
+	if err := storage.UpdateIntent(study); err != nil {
+		t.Fatalf("Failed to update intent: %v", err)
+	}
+	custom := &models.Intent{Intent: "recipes", Name: "Recipes", Keywords: []string{"cook"}, Custom: true}
+	if err := storage.CreateIntent(custom); err != nil {
+		t.Fatalf("Failed to create intent: %v", err)
+	}
+	if err := storage.CreateIntent(custom); err == nil {
+		t.Error("Expected an error creating a duplicate intent")
+	}
+
+	// The same version is not seeded again
+	master[1].SystemPrompt = "Ignored."
+	if err := storage.SeedIntents(master, "v1"); err != nil {
+		t.Fatalf("Failed to seed intents: %v", err)
+	}
+	research, _ := storage.GetIntent("research")
+	if research.SystemPrompt != "Cite sources." {
+		t.Errorf("Expected an unchanged version to be skipped, got %q", research.SystemPrompt)
+	}
+
+	// A new version refreshes built-ins but keeps overrides and custom intents
+	master[0].SystemPrompt = "You are a new tutor."
+	master[0].Name = "Study & Learning"
+	master[1].SystemPrompt = "Cite primary sources."
+	master = append(master, models.Intent{Intent: "recipes", Name: "Built-in Recipes"}, models.Intent{Intent: "plan", Name: "Plan"})
+	if err := storage.SeedIntents(master, "v2"); err != nil {
+		t.Fatalf("Failed to seed intents: %v", err)
+	}
+
+	study, _ = storage.GetIntent("study")
+	if study.SystemPrompt != "You are my tutor." || study.Name != "Study & Learning" || !study.Overridden {
+		t.Errorf("Expected override kept and name refreshed, got %+v", study)
+	}
+	research, _ = storage.GetIntent("research")
+	if research.SystemPrompt != "Cite primary sources." {
+		t.Errorf("Expected refreshed system prompt, got %q", research.SystemPrompt)
+	}
+	recipes, _ := storage.GetIntent("recipes")
+	if recipes.Name != "Recipes" || !recipes.Custom {
+		t.Errorf("Expected custom intent untouched, got %+v", recipes)
+	}
+
+	intents, _ = storage.GetAllIntents()
+	var names []string
+	for _, intent := range intents {
+		names = append(names, intent.Intent)
+	}
+	if len(names) != 4 || names[2] != "plan" || names[3] != "recipes" {
+		t.Errorf("Expected built-ins before custom intents, got %v", names)
+	}
+
+	if err := storage.DeleteIntent("recipes"); err != nil {
+		t.Fatalf("Failed to delete intent: %v", err)
+	}
+	if _, err := storage.GetIntent("recipes"); err == nil {
+		t.Error("Expected deleted intent to be gone")
+	}
+	if err := storage.DeleteIntent("recipes"); err == nil {
+		t.Error("Expected an error deleting a missing intent")
+	}
+	if err := storage.UpdateIntent(&models.Intent{Intent: "missing", Name: "x"}); err == nil {
+		t.Error("Expected an error updating a missing intent")
+	}
+}
+
+func TestSQLiteStorage_Search(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+
+	storage, err := NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	profile := &models.Profile{Name: "Test Profile"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	persona, err := storage.CreatePersona(&models.Persona{
+		UserRoleDisplay: "Developer",
+		LLMRoleDisplay:  "Security Reviewer",
+		ProfileID:       profile.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+
+	template, err := storage.CreateTemplate(&models.PromptTemplate{
+		Name:      "Code review",
+		PersonaID: persona.ID,
+		Task:      "Review this {{language}} code for bugs",
+		Template:  "Review this {{language}} code for bugs",
+		Variables: []string{"language"},
+		ProfileID: profile.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	template.Task = "Review this {{language}} code for performance"
+	template.Template = template.Task
+	version, err := storage.CreateTemplateVersion(template)
+	if err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+
+	prompt, err := storage.Create(&models.Prompt{
+		Name:            "Go perf review",
+		TemplateID:      version.ID,
+		TemplateVersion: version.Version,
+		Values:          map[string]string{"language": "Golang"},
+		Content:         "Review this Golang code for performance",
+		ProfileID:       profile.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	search := func(query string, types ...string) []*models.SearchResult {
+		t.Helper()
+		results, err := storage.Search(query, "", types, 20)
+		if err != nil {
+			t.Fatalf("Failed to search for %q: %v", query, err)
+		}
+		return results
+	}
+
+	// Only the latest template version is searched
+	results := search("perf")
+	if len(results) != 2 {
+		t.Fatalf("Expected the prompt and template, got %d results", len(results))
+	}
+	// The prompt's name matches too, so it ranks first
+	if results[0].Type != models.SearchTypePrompt || results[0].ID != prompt.ID.String() {
+		t.Errorf("Expected the prompt first, got %+v", results[0])
+	}
+	if results[0].Name != "Go <mark>perf</mark> review" {
+		t.Errorf("Expected a highlighted name, got %q", results[0].Name)
+	}
+	if results[1].Type != models.SearchTypeTemplate || results[1].Version != 2 {
+		t.Errorf("Expected template version 2, got %+v", results[1])
+	}
+	if results[0].Score < results[1].Score {
+		t.Error("Expected results ordered by score")
+	}
+	if len(search("bugs")) != 0 {
+		t.Error("Expected older template versions not to be searched")
+	}
+
+	if results := search("security reviewer"); len(results) != 1 || results[0].Type != models.SearchTypePersona {
+		t.Errorf("Expected the persona, got %v", results)
+	}
+	if results := search("perf", models.SearchTypeTemplate); len(results) != 1 {
+		t.Errorf("Expected only the template, got %v", results)
+	}
+	if results, _ := storage.Search("perf", "other-profile", nil, 20); len(results) != 0 {
+		t.Errorf("Expected no results for another profile, got %v", results)
+	}
+	if results := search(`golang" (`); len(results) != 1 {
+		t.Errorf("Expected FTS syntax in the query to be searched for, got %v", results)
+	}
+	if _, err := storage.Search(" -- ", "", nil, 20); err == nil {
+		t.Error("Expected an error for a query without words")
+	}
+
+	// Indexed text is HTML-escaped around the highlights
+	if _, err := storage.Create(&models.Prompt{
+		Name:            "<b>Markup</b> & more",
+		TemplateID:      version.ID,
+		TemplateVersion: version.Version,
+		Content:         "<script>alert(1)</script>",
+		ProfileID:       profile.ID,
+	}); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	results = search("markup")
+	if len(results) != 1 || results[0].Name != "&lt;b&gt;<mark>Markup</mark>&lt;/b&gt; &amp; more" {
+		t.Errorf("Expected an escaped, highlighted name, got %v", results)
+	}
+	if results := search("script"); len(results) != 1 || strings.Contains(results[0].Snippet, "<script>") {
+		t.Errorf("Expected an escaped snippet, got %v", results)
+	}
+
+	// Indexes follow updates and deletes
+	prompt.Content = "Review this Golang code for readability"
+	prompt.Name = "Go review"
+	if _, err := storage.Update(prompt); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+	if results := search("perf", models.SearchTypePrompt); len(results) != 0 {
+		t.Errorf("Expected the old content to be gone, got %v", results)
+	}
+	if results := search("readability"); len(results) != 1 || results[0].Snippet == "" {
+		t.Errorf("Expected the new content with a snippet, got %v", results)
+	}
+	if err := storage.Delete(prompt.ID); err != nil {
+		t.Fatalf("Failed to delete prompt: %v", err)
+	}
+	if results := search("readability"); len(results) != 0 {
+		t.Errorf("Expected the deleted prompt to be gone, got %v", results)
+	}
+
+	// Indexes that fall out of step are rebuilt on open
+	if _, err := storage.db.Exec("DELETE FROM personas_fts"); err != nil {
+		t.Fatalf("Failed to clear index: %v", err)
+	}
+	if err := InitializeSchema(storage.conn); err != nil {
+		t.Fatalf("Failed to initialize schema: %v", err)
+	}
+	if results := search("reviewer", models.SearchTypePersona); len(results) != 1 {
+		t.Errorf("Expected the persona after rebuilding, got %v", results)
+	}
+}
+
+func TestSQLiteStorage_List(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+
+	storage, err := NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	profile := &models.Profile{Name: "Test Profile"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	persona, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Developer", LLMRoleDisplay: "Reviewer", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	if persona.CreatedAt.IsZero() {
+		t.Error("Expected created_at to be returned")
+	}
+
+	template, err := storage.CreateTemplate(&models.PromptTemplate{Name: "Review", PersonaID: persona.ID, Template: "Review", Variables: []string{}, ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := storage.CreateTemplateVersion(template); err != nil {
+			t.Fatalf("Failed to create template version: %v", err)
+		}
+	}
+
+	names := []string{"echo", "Alpha", "delta", "bravo", "Charlie"}
+	for _, name := range names {
+		_, err := storage.Create(&models.Prompt{Name: name, TemplateID: template.ID, TemplateVersion: 1, Values: map[string]string{}, Content: name, ProfileID: profile.ID})
+		if err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+	}
+
+	// Page through every prompt two at a time
+	opts := models.ListOptions{Sort: models.SortName, Limit: 2}
+	var seen []string
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected paging to end")
+		}
+		prompts, page, err := storage.ListPrompts(opts)
+		if err != nil {
+			t.Fatalf("Failed to list prompts: %v", err)
+		}
+		if page.Total != len(names) {
+			t.Errorf("Expected a total of %d, got %d", len(names), page.Total)
+		}
+		for _, p := range prompts {
+			seen = append(seen, p.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	expected := []string{"Alpha", "bravo", "Charlie", "delta", "echo"}
+	if strings.Join(seen, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, seen)
+	}
+
+	// Cursors only continue the sort they came from
+	if _, _, err := storage.ListPrompts(models.ListOptions{Sort: models.SortName, Desc: true, Cursor: opts.Cursor}); err != models.ErrInvalidCursor {
+		t.Errorf("Expected an invalid cursor error, got %v", err)
+	}
+	if _, _, err := storage.ListPrompts(models.ListOptions{Sort: models.SortVersion}); err == nil {
+		t.Error("Expected an error sorting prompts by version")
+	}
+
+	prompts, _, err := storage.ListPrompts(models.ListOptions{Sort: models.SortName, Desc: true, Limit: 1})
+	if err != nil || len(prompts) != 1 || prompts[0].Name != "echo" {
+		t.Errorf("Expected echo first in descending order, got %v (%v)", prompts, err)
+	}
+
+	// Filters
+	prompts, page, err := storage.ListPrompts(models.ListOptions{TemplateID: uuid.New()})
+	if err != nil || len(prompts) != 0 || page.Total != 0 {
+		t.Errorf("Expected no prompts for another template, got %d (%v)", len(prompts), err)
+	}
+	prompts, _, err = storage.ListPrompts(models.ListOptions{CreatedAfter: time.Now().Add(time.Hour)})
+	if err != nil || len(prompts) != 0 {
+		t.Errorf("Expected no prompts created in the future, got %d (%v)", len(prompts), err)
+	}
+	prompts, _, err = storage.ListPrompts(models.ListOptions{CreatedAfter: time.Now().Add(-time.Hour), CreatedBefore: time.Now().Add(time.Hour)})
+	if err != nil || len(prompts) != len(names) {
+		t.Errorf("Expected every prompt created in the last hour, got %d (%v)", len(prompts), err)
+	}
+
+	// Templates page through versions
+	templates, page, err := storage.ListTemplates(models.ListOptions{Sort: models.SortVersion, Desc: true, Limit: 2, PersonaID: persona.ID})
+	if err != nil {
+		t.Fatalf("Failed to list templates: %v", err)
+	}
+	if page.Total != 3 || len(templates) != 2 || templates[0].Version != 3 || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page of templates: %d of %d", len(templates), page.Total)
+	}
+	templates, page, err = storage.ListTemplates(models.ListOptions{Sort: models.SortVersion, Desc: true, Limit: 2, PersonaID: persona.ID, Cursor: page.NextCursor})
+	if err != nil || len(templates) != 1 || templates[0].Version != 1 || page.NextCursor != "" {
+		t.Errorf("Unexpected last page of templates: %v (%v)", templates, err)
+	}
+
+	personas, _, err := storage.ListPersonas(models.ListOptions{ProfileID: profile.ID})
+	if err != nil || len(personas) != 1 {
+		t.Errorf("Expected the persona, got %v (%v)", personas, err)
+	}
+	profiles, page, err := storage.ListProfiles(models.ListOptions{Sort: models.SortUpdatedAt})
+	if err != nil || len(profiles) != 1 || page.Total != 1 {
+		t.Errorf("Expected the profile, got %v (%v)", profiles, err)
+	}
+}
+
+func TestSQLiteStorage_TagsAndFolders(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	profile := &models.Profile{Name: "Test Profile"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	persona, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Developer", LLMRoleDisplay: "Reviewer", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	template, err := storage.CreateTemplate(&models.PromptTemplate{Name: "Review", PersonaID: persona.ID, Template: "Review", Variables: []string{}, ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	var prompts []*models.Prompt
+	for _, name := range []string{"one", "two"} {
+		prompt, err := storage.Create(&models.Prompt{Name: name, TemplateID: template.ID, TemplateVersion: 1, Values: map[string]string{}, Content: name, ProfileID: profile.ID})
+		if err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+
+	// Tags
+	work := &models.Tag{Name: "work", Color: "#ff0000", ProfileID: profile.ID}
+	if err := storage.CreateTag(work); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := storage.CreateTag(&models.Tag{Name: "Work", ProfileID: profile.ID}); err != models.ErrTagExists {
+		t.Errorf("Expected ErrTagExists for a duplicate name, got %v", err)
+	}
+	draft := &models.Tag{Name: "draft", ProfileID: profile.ID}
+	if err := storage.CreateTag(draft); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+
+	tags, err := storage.SetPromptTags(prompts[0].ID, []uuid.UUID{work.ID, draft.ID, work.ID})
+	if err != nil {
+		t.Fatalf("Failed to set prompt tags: %v", err)
+	}
+	if len(tags) != 2 || tags[0].Name != "draft" || tags[1].Name != "work" {
+		t.Errorf("Expected tags draft and work, got %v", tags)
+	}
+	if _, err := storage.SetPromptTags(prompts[1].ID, []uuid.UUID{uuid.New()}); err == nil {
+		t.Error("Expected an error for an unknown tag")
+	}
+	if _, err := storage.SetTemplateTags(template.ID, []uuid.UUID{work.ID}); err != nil {
+		t.Fatalf("Failed to set template tags: %v", err)
+	}
+
+	listed, _, err := storage.ListPrompts(models.ListOptions{TagID: work.ID})
+	if err != nil {
+		t.Fatalf("Failed to list prompts by tag: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != prompts[0].ID {
+		t.Errorf("Expected only the tagged prompt, got %d prompts", len(listed))
+	}
+
+	// Template tags cover every version and survive deleting all but one
+	if _, err := storage.CreateTemplateVersion(template); err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+	versions, _, err := storage.ListTemplates(models.ListOptions{TagID: work.ID})
+	if err != nil {
+		t.Fatalf("Failed to list templates by tag: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("Expected both template versions, got %d", len(versions))
+	}
+	if err := storage.DeleteTemplate(template.ID, 2); err != nil {
+		t.Fatalf("Failed to delete template version: %v", err)
+	}
+	if tags, _ := storage.GetTemplateTags(template.ID); len(tags) != 1 {
+		t.Errorf("Expected template tags to remain, got %d", len(tags))
+	}
+
+	// Deleting a tag unlinks it
+	if err := storage.DeleteTag(draft.ID); err != nil {
+		t.Fatalf("Failed to delete tag: %v", err)
+	}
+	if tags, _ := storage.GetPromptTags(prompts[0].ID); len(tags) != 1 || tags[0].ID != work.ID {
+		t.Errorf("Expected only the work tag after deleting draft, got %v", tags)
+	}
+
+	// Folders
+	projects := &models.Folder{Name: "Projects", ProfileID: profile.ID}
+	if err := storage.CreateFolder(projects); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	client := &models.Folder{Name: "Client", ParentID: &projects.ID, ProfileID: profile.ID}
+	if err := storage.CreateFolder(client); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	projects.ParentID = &client.ID
+	if err := storage.UpdateFolder(projects); err != models.ErrFolderCycle {
+		t.Errorf("Expected ErrFolderCycle moving a folder into its subfolder, got %v", err)
+	}
+	projects.ParentID = nil
+
+	if err := storage.MovePrompt(prompts[1].ID, &client.ID); err != nil {
+		t.Fatalf("Failed to move prompt: %v", err)
+	}
+	if err := storage.MoveTemplate(template.ID, &client.ID); err != nil {
+		t.Fatalf("Failed to move template: %v", err)
+	}
+	version, err := storage.CreateTemplateVersion(template)
+	if err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+	if version.FolderID == nil || *version.FolderID != client.ID {
+		t.Error("Expected a new template version to stay in its template's folder")
+	}
+
+	// Updates keep an item in its folder
+	prompts[1].FolderID = nil
+	updated, err := storage.Update(prompts[1])
+	if err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+	if updated.FolderID == nil || *updated.FolderID != client.ID {
+		t.Error("Expected updating a prompt to keep its folder")
+	}
+
+	listed, _, err = storage.ListPrompts(models.ListOptions{FolderID: client.ID})
+	if err != nil {
+		t.Fatalf("Failed to list prompts by folder: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != prompts[1].ID {
+		t.Errorf("Expected only the moved prompt, got %d prompts", len(listed))
+	}
+
+	// Deleting a folder deletes its subfolders and keeps what they held
+	if err := storage.DeleteFolder(projects.ID); err != nil {
+		t.Fatalf("Failed to delete folder: %v", err)
+	}
+	if _, err := storage.GetFolder(client.ID); err == nil {
+		t.Error("Expected the subfolder to be deleted")
+	}
+	prompt, err := storage.GetByID(prompts[1].ID)
+	if err != nil {
+		t.Fatalf("Expected the prompt to survive its folder: %v", err)
+	}
+	if prompt.FolderID != nil {
+		t.Error("Expected the prompt to be out of any folder")
+	}
+	if latest, _ := storage.GetTemplateByID(template.ID); latest.FolderID != nil {
+		t.Error("Expected the template to be out of any folder")
+	}
+
+	// Purging a deleted prompt unlinks its tags
+	if err := storage.Delete(prompts[0].ID); err != nil {
+		t.Fatalf("Failed to delete prompt: %v", err)
+	}
+	if _, err := storage.PurgeTrash(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	var links int
+	storage.db.QueryRow(`SELECT COUNT(*) FROM prompt_tags`).Scan(&links)
+	if links != 0 {
+		t.Errorf("Expected no prompt tags after deleting the prompt, got %d", links)
+	}
+}
+
+func TestSQLiteStorage_Usage(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	profile := &models.Profile{Name: "Test Profile"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	persona, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Developer", LLMRoleDisplay: "Reviewer", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	template, err := storage.CreateTemplate(&models.PromptTemplate{Name: "Review", PersonaID: persona.ID, Template: "Review", Variables: []string{}, ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	var prompts []*models.Prompt
+	for _, name := range []string{"one", "two", "three"} {
+		prompt, err := storage.Create(&models.Prompt{Name: name, TemplateID: template.ID, TemplateVersion: 1, Values: map[string]string{}, Content: name, ProfileID: profile.ID})
+		if err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+
+	usage, err := storage.GetUsage(models.UsagePrompt, prompts[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to get usage: %v", err)
+	}
+	if usage.RenderCount != 0 || usage.LastUsedAt != nil || usage.Starred {
+		t.Errorf("Expected no usage for an unused prompt, got %+v", usage)
+	}
+
+	// "two" is used three times, "three" once
+	for _, event := range []string{models.UsageRender, models.UsageCopy, models.UsageCopy} {
+		if usage, err = storage.RecordUsage(models.UsagePrompt, prompts[1].ID, event); err != nil {
+			t.Fatalf("Failed to record usage: %v", err)
+		}
+	}
+	if usage.RenderCount != 1 || usage.CopyCount != 2 || usage.LastUsedAt == nil {
+		t.Errorf("Expected 1 render and 2 copies, got %+v", usage)
+	}
+	if _, err := storage.RecordUsage(models.UsagePrompt, prompts[2].ID, models.UsageRender); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+	if _, err := storage.RecordUsage(models.UsagePrompt, prompts[2].ID, "print"); err == nil {
+		t.Error("Expected an error for an unknown event")
+	}
+
+	mostUsed, _, err := storage.ListPrompts(models.ListOptions{Sort: models.SortMostUsed, Desc: true, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to list prompts by use: %v", err)
+	}
+	if len(mostUsed) != 1 || mostUsed[0].ID != prompts[1].ID {
+		t.Fatalf("Expected the most used prompt first")
+	}
+	if mostUsed[0].Usage == nil || mostUsed[0].Usage.CopyCount != 2 {
+		t.Errorf("Expected lists to include usage, got %+v", mostUsed[0].Usage)
+	}
+
+	// Page through by use, which compares the cursor key as a number
+	opts := models.ListOptions{Sort: models.SortMostUsed, Desc: true, Limit: 1}
+	var order []string
+	for {
+		page, info, err := storage.ListPrompts(opts)
+		if err != nil {
+			t.Fatalf("Failed to list prompts by use: %v", err)
+		}
+		for _, p := range page {
+			order = append(order, p.Name)
+		}
+		if info.NextCursor == "" {
+			break
+		}
+		opts.Cursor = info.NextCursor
+	}
+	if strings.Join(order, ",") != "two,three,one" {
+		t.Errorf("Expected two,three,one by use, got %v", order)
+	}
+
+	// Starring
+	if usage, err = storage.SetStarred(models.UsagePrompt, prompts[0].ID, true); err != nil {
+		t.Fatalf("Failed to star prompt: %v", err)
+	}
+	if !usage.Starred || usage.StarredAt == nil {
+		t.Errorf("Expected the prompt to be starred, got %+v", usage)
+	}
+	starred, _, err := storage.ListPrompts(models.ListOptions{Sort: models.SortStarred, Desc: true})
+	if err != nil {
+		t.Fatalf("Failed to list prompts by star: %v", err)
+	}
+	if starred[0].ID != prompts[0].ID || !starred[0].Usage.Starred {
+		t.Error("Expected the starred prompt first")
+	}
+	if usage, err = storage.SetStarred(models.UsagePrompt, prompts[0].ID, false); err != nil {
+		t.Fatalf("Failed to unstar prompt: %v", err)
+	}
+	if usage.Starred {
+		t.Error("Expected the prompt to be unstarred")
+	}
+
+	// Template usage covers every version
+	if _, err := storage.RecordUsage(models.UsageTemplate, template.ID, models.UsageRender); err != nil {
+		t.Fatalf("Failed to record template usage: %v", err)
+	}
+	if _, err := storage.CreateTemplateVersion(template); err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+	templates, _, err := storage.ListTemplates(models.ListOptions{Sort: models.SortRecent, Desc: true})
+	if err != nil {
+		t.Fatalf("Failed to list templates by recent use: %v", err)
+	}
+	for _, tmpl := range templates {
+		if tmpl.Usage == nil || tmpl.Usage.RenderCount != 1 {
+			t.Errorf("Expected version %d to share the template's usage", tmpl.Version)
+		}
+	}
+
+	// Purging a deleted item forgets its usage
+	if err := storage.Delete(prompts[1].ID); err != nil {
+		t.Fatalf("Failed to delete prompt: %v", err)
+	}
+	if _, err := storage.PurgeTrash(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	var rows int
+	storage.db.QueryRow(`SELECT COUNT(*) FROM item_usage WHERE item_id = ?`, prompts[1].ID.String()).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("Expected the deleted prompt's usage to be removed, got %d rows", rows)
+	}
+}
+
+func TestSQLiteStorage_Trash(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	profile := &models.Profile{Name: "Test Profile"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	persona, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Developer", LLMRoleDisplay: "Reviewer", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	template, err := storage.CreateTemplate(&models.PromptTemplate{Name: "Review", PersonaID: persona.ID, Template: "Review", Variables: []string{}, ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	var prompts []*models.Prompt
+	for _, name := range []string{"one", "two"} {
+		prompt, err := storage.Create(&models.Prompt{Name: name, TemplateID: template.ID, TemplateVersion: 1, Values: map[string]string{}, Content: name, ProfileID: profile.ID})
+		if err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+
+	// Deleting a prompt hides it and puts it in the trash
+	if err := storage.Delete(prompts[0].ID); err != nil {
+		t.Fatalf("Failed to delete prompt: %v", err)
+	}
+	if _, err := storage.GetByID(prompts[0].ID); err == nil {
+		t.Error("Expected a deleted prompt to be hidden")
+	}
+	if err := storage.Delete(prompts[0].ID); err == nil {
+		t.Error("Expected deleting a deleted prompt to fail")
+	}
+	all, _ := storage.GetAll(profile.ID)
+	if len(all) != 1 {
+		t.Errorf("Expected 1 prompt left, got %d", len(all))
+	}
+
+	// Deleting the persona takes its template and remaining prompt along
+	if err := storage.DeletePersona(persona.ID); err != nil {
+		t.Fatalf("Failed to delete persona: %v", err)
+	}
+	if _, err := storage.GetTemplateByID(template.ID); err == nil {
+		t.Error("Expected the persona's template to be deleted with it")
+	}
+	if all, _ := storage.GetAll(profile.ID); len(all) != 0 {
+		t.Errorf("Expected no prompts left, got %d", len(all))
+	}
+	listed, page, err := storage.ListTemplates(models.ListOptions{ProfileID: profile.ID})
+	if err != nil || len(listed) != 0 || page.Total != 0 {
+		t.Errorf("Expected deleted templates to be left out of lists, got %d (%v)", len(listed), err)
+	}
+	if results, _ := storage.Search("review", profile.ID, nil, 10); len(results) != 0 {
+		t.Errorf("Expected deleted items to be left out of search, got %d results", len(results))
+	}
+
+	trash, err := storage.GetTrash(profile.ID)
+	if err != nil {
+		t.Fatalf("Failed to get trash: %v", err)
+	}
+	if len(trash) != 2 {
+		t.Fatalf("Expected 2 trash items, got %d", len(trash))
+	}
+	personaItem, promptItem := trash[0], trash[1]
+	if personaItem.Type != models.TrashPersona || personaItem.ItemID != persona.ID || personaItem.Name != "Developer" || personaItem.Cascaded != 2 {
+		t.Errorf("Unexpected persona trash item: %+v", personaItem)
+	}
+	if promptItem.Type != models.TrashPrompt || promptItem.ItemID != prompts[0].ID || promptItem.Cascaded != 0 {
+		t.Errorf("Unexpected prompt trash item: %+v", promptItem)
+	}
+
+	// A prompt can't come back before its template
+	if err := storage.RestoreTrash(promptItem.ID); err != models.ErrRestoreConflict {
+		t.Errorf("Expected ErrRestoreConflict, got %v", err)
+	}
+
+	// Restoring the persona restores what was deleted with it, only
+	if err := storage.RestoreTrash(personaItem.ID); err != nil {
+		t.Fatalf("Failed to restore persona: %v", err)
+	}
+	if _, err := storage.GetTemplateByID(template.ID); err != nil {
+		t.Errorf("Expected the template to be restored: %v", err)
+	}
+	if all, _ := storage.GetAll(profile.ID); len(all) != 1 || all[0].ID != prompts[1].ID {
+		t.Errorf("Expected only the cascaded prompt to be restored, got %d prompts", len(all))
+	}
+	if err := storage.RestoreTrash(personaItem.ID); err == nil {
+		t.Error("Expected restoring twice to fail")
+	}
+
+	// Deleting a template version takes its prompts along; a new version
+	// doesn't reuse its number
+	if err := storage.DeleteTemplate(template.ID, 1); err != nil {
+		t.Fatalf("Failed to delete template version: %v", err)
+	}
+	if _, err := storage.CreateTemplateVersion(template); err == nil {
+		t.Error("Expected versioning a fully deleted template to fail")
+	}
+	if _, err := storage.GetByID(prompts[1].ID); err == nil {
+		t.Error("Expected the version's prompt to be deleted with it")
+	}
+
+	// Purging removes items for good, along with prompts of the purged
+	// template version that were trashed separately
+	purged, err := storage.PurgeTrash(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 purged items, got %d", purged)
+	}
+	var rows int
+	storage.db.QueryRow(`SELECT COUNT(*) FROM prompts`).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("Expected purged prompts to be gone, got %d rows", rows)
+	}
+	if trash, _ := storage.GetTrash(profile.ID); len(trash) != 0 {
+		t.Errorf("Expected an empty trash, got %d items", len(trash))
+	}
+	if err := storage.PurgeTrashItem(personaItem.ID); err == nil {
+		t.Error("Expected purging a missing trash item to fail")
+	}
+
+	// Items newer than the cutoff stay
+	if err := storage.DeletePersona(persona.ID); err != nil {
+		t.Fatalf("Failed to delete persona: %v", err)
+	}
+	if purged, _ := storage.PurgeTrash(time.Now().Add(-time.Hour)); purged != 0 {
+		t.Errorf("Expected nothing purged before the cutoff, got %d", purged)
+	}
+}
+
+func TestSQLiteStorage_Dependents(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	profile := &models.Profile{Name: "Test Profile"}
+	if err := storage.CreateProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	persona, err := storage.CreatePersona(&models.Persona{UserRoleDisplay: "Developer", LLMRoleDisplay: "Reviewer", ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create persona: %v", err)
+	}
+	template, err := storage.CreateTemplate(&models.PromptTemplate{Name: "Review", PersonaID: persona.ID, Template: "Review", Variables: []string{}, ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	next := *template
+	if _, err := storage.CreateTemplateVersion(&next); err != nil {
+		t.Fatalf("Failed to create template version: %v", err)
+	}
+	var prompts []*models.Prompt
+	for _, version := range []int{1, 2, 2} {
+		prompt, err := storage.Create(&models.Prompt{Name: "p", TemplateID: template.ID, TemplateVersion: version, Values: map[string]string{}, Content: "p", ProfileID: profile.ID})
+		if err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+	if err := storage.Delete(prompts[2].ID); err != nil {
+		t.Fatalf("Failed to delete prompt: %v", err)
+	}
+
+	deps, err := storage.GetPersonaDependents(persona.ID)
+	if err != nil {
+		t.Fatalf("Failed to get persona dependents: %v", err)
+	}
+	if deps.TemplateCount != 2 || deps.Templates[0].Version != 1 || deps.Templates[1].Version != 2 {
+		t.Errorf("Expected both template versions, got %+v", deps.Templates)
+	}
+	if deps.PromptCount != 2 || deps.PromptIDs[0] != prompts[0].ID || deps.PromptIDs[1] != prompts[1].ID {
+		t.Errorf("Expected the two live prompts, got %v", deps.PromptIDs)
+	}
+
+	deps, err = storage.GetTemplateDependents(template.ID, 2)
+	if err != nil {
+		t.Fatalf("Failed to get template dependents: %v", err)
+	}
+	if deps.TemplateCount != 0 || deps.PromptCount != 1 || deps.PromptIDs[0] != prompts[1].ID {
+		t.Errorf("Unexpected template dependents: %+v", deps)
+	}
+
+	if err := storage.DeleteTemplate(template.ID, 1); err != nil {
+		t.Fatalf("Failed to delete template version: %v", err)
+	}
+	if _, err := storage.GetTemplateDependents(template.ID, 1); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Expected not found for a deleted template version, got %v", err)
+	}
+	if deps, _ := storage.GetPersonaDependents(persona.ID); deps.TemplateCount != 1 || deps.PromptCount != 1 || deps.Empty() {
+		t.Errorf("Expected deleted items to be left out, got %+v", deps)
+	}
+	if _, err := storage.GetPersonaDependents(uuid.New()); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Expected not found for a missing persona, got %v", err)
+	}
+}
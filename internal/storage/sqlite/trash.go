@@ -0,0 +1,229 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// trashStep soft-deletes the live rows of a table matching a condition.
+type trashStep struct {
+	table, where string
+	args         []interface{}
+}
+
+// moveToTrash records a delete in the trash and soft-deletes the rows each
+// step matches under it, in order, so later steps can find the rows earlier
+// ones deleted by their deletion_id. lookup selects the deleted item's id,
+// version, name and profile, and finding nothing fails with notFound.
+// Callers hold s.mu.
+func (s *SQLiteStorage) moveToTrash(deletion, itemType, notFound, lookup string, lookupArgs []interface{}, steps ...trashStep) error {
+	tx, err := s.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var itemID string
+	var version int
+	var name, profileID sql.NullString
+	err = tx.QueryRow(lookup, lookupArgs...).Scan(&itemID, &version, &name, &profileID)
+	if err == sql.ErrNoRows {
+		return errors.New(notFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", itemType, err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO trash (id, item_type, item_id, version, name, profile_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		deletion, itemType, itemID, sql.NullInt64{Int64: int64(version), Valid: version > 0}, name, profileID)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", itemType, err)
+	}
+
+	for _, step := range steps {
+		query := fmt.Sprintf(`UPDATE %s SET deleted_at = (SELECT deleted_at FROM trash WHERE id = ?), deletion_id = ?
+			WHERE deleted_at IS NULL AND %s`, step.table, step.where)
+		if _, err := tx.Exec(query, append([]interface{}{deletion, deletion}, step.args...)...); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", itemType, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTrash lists trashed items, most recently deleted first. Items of the
+// default profile show in every profile's trash, as its personas show in
+// every profile.
+func (s *SQLiteStorage) GetTrash(profileID string) ([]*models.TrashItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT t.id, t.item_type, t.item_id, t.version, t.name, t.profile_id, t.deleted_at,
+			(SELECT COUNT(*) FROM personas WHERE deletion_id = t.id) +
+			(SELECT COUNT(*) FROM prompt_templates WHERE deletion_id = t.id) +
+			(SELECT COUNT(*) FROM prompts WHERE deletion_id = t.id) - 1
+		FROM trash t`
+	args := []interface{}{}
+
+	if profileID != "" {
+		query += " WHERE t.profile_id = ? OR t.profile_id = ?"
+		args = append(args, profileID, defaultProfileID)
+	}
+
+	query += " ORDER BY t.deleted_at DESC, t.rowid DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	items := []*models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		var id, itemID string
+		var version sql.NullInt64
+		var name, profileID sql.NullString
+		if err := rows.Scan(&id, &item.Type, &itemID, &version, &name, &profileID, &item.DeletedAt, &item.Cascaded); err != nil {
+			return nil, fmt.Errorf("failed to scan trash item: %w", err)
+		}
+		item.ID = uuid.MustParse(id)
+		item.ItemID = uuid.MustParse(itemID)
+		item.Version = int(version.Int64)
+		item.Name = name.String
+		item.ProfileID = profileID.String
+		items = append(items, &item)
+	}
+
+	return items, rows.Err()
+}
+
+// RestoreTrash restores every row a delete put in the trash. It fails with
+// models.ErrRestoreConflict while the persona or template version they
+// belong to is in the trash from an earlier delete.
+func (s *SQLiteStorage) RestoreTrash(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var conflict bool
+	err = tx.QueryRow(`SELECT
+		EXISTS (SELECT 1 FROM prompt_templates pt JOIN personas p ON p.id = pt.persona_id
+			WHERE pt.deletion_id = ?1 AND p.deletion_id <> pt.deletion_id)
+		OR EXISTS (SELECT 1 FROM prompts pr JOIN prompt_templates pt ON pt.id = pr.template_id AND pt.version = pr.template_version
+			WHERE pr.deletion_id = ?1 AND pt.deletion_id <> pr.deletion_id)
+		FROM trash WHERE id = ?1`, id.String()).Scan(&conflict)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("trash item not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+	if conflict {
+		return models.ErrRestoreConflict
+	}
+
+	for _, table := range []string{"personas", "prompt_templates", "prompts"} {
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET deleted_at = NULL, deletion_id = NULL WHERE deletion_id = ?`, table), id.String()); err != nil {
+			return fmt.Errorf("failed to restore: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM trash WHERE id = ?`, id.String()); err != nil {
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) PurgeTrashItem(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged, err := s.purge(`SELECT id FROM trash WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	if purged == 0 {
+		return fmt.Errorf("trash item not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) PurgeTrash(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purge(`SELECT id FROM trash WHERE deleted_at < ?`, before.UTC().Format(timestampFormat))
+}
+
+// purge permanently deletes the trash entries selected by query and their
+// rows, returning how many entries it purged. Rows of other entries that
+// belong to a purged persona or template version go too, as the foreign
+// keys would take them, and entries left with no rows are dropped.
+// Callers hold s.mu.
+func (s *SQLiteStorage) purge(query string, args ...interface{}) (int, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trash: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trash item: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query trash: %w", err)
+	}
+
+	statements := []string{
+		`DELETE FROM prompts WHERE deletion_id = ?1 OR (template_id, template_version) IN (
+			SELECT id, version FROM prompt_templates
+			WHERE deletion_id = ?1 OR persona_id IN (SELECT id FROM personas WHERE deletion_id = ?1))`,
+		`DELETE FROM prompt_templates WHERE deletion_id = ?1 OR persona_id IN (SELECT id FROM personas WHERE deletion_id = ?1)`,
+		`DELETE FROM personas WHERE deletion_id = ?1`,
+		`DELETE FROM trash WHERE id = ?1`,
+	}
+	for _, id := range ids {
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt, id); err != nil {
+				return 0, fmt.Errorf("failed to purge trash: %w", err)
+			}
+		}
+	}
+
+	if len(ids) > 0 {
+		_, err = tx.Exec(`DELETE FROM trash WHERE
+			NOT EXISTS (SELECT 1 FROM personas WHERE deletion_id = trash.id)
+			AND NOT EXISTS (SELECT 1 FROM prompt_templates WHERE deletion_id = trash.id)
+			AND NOT EXISTS (SELECT 1 FROM prompts WHERE deletion_id = trash.id)`)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge trash: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/rahulguha/promptly/internal/storage/store"
+)
+
+var _ store.Storage = (*SQLiteStorage)(nil)
+
+// querier runs statements, on the database or in a transaction.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// nestedSavepoint names the savepoints of work nested in a transaction.
+// Nested work is always released or rolled back before the work around it,
+// and SQLite resolves a name to its most recent savepoint, so one name
+// serves every level.
+const nestedSavepoint = "nested"
+
+// txn is a transaction begun by a storage method. Within WithTx it is a
+// savepoint in WithTx's transaction, which only WithTx commits.
+type txn struct {
+	*sql.Tx
+	nested bool
+	done   bool
+}
+
+// Commit commits the transaction, or releases the savepoint.
+func (t *txn) Commit() error {
+	if !t.nested {
+		return t.Tx.Commit()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Exec("RELEASE " + nestedSavepoint)
+	return err
+}
+
+// Rollback rolls the transaction back, or undoes the work since the
+// savepoint.
+func (t *txn) Rollback() error {
+	if !t.nested {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	if _, err := t.Exec("ROLLBACK TO " + nestedSavepoint); err != nil {
+		return err
+	}
+	_, err := t.Exec("RELEASE " + nestedSavepoint)
+	return err
+}
+
+// begin starts a transaction for a method writing several rows, nested in
+// WithTx's transaction when there is one.
+func (s *SQLiteStorage) begin() (*txn, error) {
+	if s.tx == nil {
+		tx, err := s.conn.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return &txn{Tx: tx}, nil
+	}
+	if _, err := s.tx.Exec("SAVEPOINT " + nestedSavepoint); err != nil {
+		return nil, err
+	}
+	return &txn{Tx: s.tx, nested: true}, nil
+}
+
+// WithTx runs fn as a unit of work. Everything fn writes through the
+// storage it is given is committed if fn returns nil and rolled back if it
+// returns an error or panics. fn must not use s itself, whose writes would
+// wait on the transaction. Nested calls share the outer transaction.
+func (s *SQLiteStorage) WithTx(fn func(tx store.Storage) error) error {
+	return s.withTx(func(tx *SQLiteStorage) error { return fn(tx) })
+}
+
+// withTx is WithTx for callers in this package, which want the storage
+// given to fn as its own type.
+func (s *SQLiteStorage) withTx(fn func(tx *SQLiteStorage) error) error {
+	t, err := s.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			t.Rollback()
+			panic(p)
+		}
+	}()
+
+	txStorage := s
+	if !t.nested {
+		txStorage = &SQLiteStorage{db: t.Tx, conn: s.conn, tx: t.Tx}
+	}
+	if err := fn(txStorage); err != nil {
+		t.Rollback()
+		return err
+	}
+	if err := t.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
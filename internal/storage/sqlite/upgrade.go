@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// v2AddedColumns lists columns introduced after their table was first
+// created, up to the initial schema. CREATE TABLE IF NOT EXISTS leaves
+// existing tables alone, so the backfill_columns migration adds any of these
+// that are missing. Like the other migrations' DDL it never changes.
+var v2AddedColumns = []struct {
+	table, column, definition string
+}{
+	{"prompt_templates", "variable_schema", "TEXT"},
+	{"personas", "meta_role_template", "TEXT"},
+	{"prompt_templates", "manual_meta_role", "INTEGER NOT NULL DEFAULT 0"},
+	{"prompt_templates", "intent", "TEXT"},
+	{"prompts", "intent", "TEXT"},
+	{"prompt_templates", "folder_id", "TEXT REFERENCES folders(id) ON DELETE SET NULL"},
+	{"prompts", "folder_id", "TEXT REFERENCES folders(id) ON DELETE SET NULL"},
+	{"personas", "deleted_at", "DATETIME"},
+	{"personas", "deletion_id", "TEXT"},
+	{"prompt_templates", "deleted_at", "DATETIME"},
+	{"prompt_templates", "deletion_id", "TEXT"},
+	{"prompts", "deleted_at", "DATETIME"},
+	{"prompts", "deletion_id", "TEXT"},
+}
+
+// execQueryer is satisfied by *sql.DB and *sql.Tx.
+type execQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// tableColumn is one row of PRAGMA table_info.
+type tableColumn struct {
+	name string
+	pk   int
+}
+
+// tableColumns returns the columns of table in declaration order.
+func tableColumns(q execQueryer, table string) ([]tableColumn, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		columns = append(columns, tableColumn{name: name, pk: pk})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	return columns, nil
+}
+
+// ensureColumn adds column to table unless it already exists.
+func ensureColumn(db execQueryer, table, column, definition string) error {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		if c.name == column {
+			return nil
+		}
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// upgradeTemplateKey rebuilds prompt_templates and prompts for databases
+// created when a template's id alone was its primary key. That key made it
+// impossible to store more than one version of a template, so the tables are
+// recreated with the (id, version) key from the initial schema and their rows
+// copied over.
+// Foreign keys must be off, as they are while migrating.
+func upgradeTemplateKey(tx *sql.Tx) error {
+	columns, err := tableColumns(tx, "prompt_templates")
+	if err != nil {
+		return err
+	}
+	keyColumns := 0
+	for _, c := range columns {
+		if c.pk > 0 {
+			keyColumns++
+		}
+	}
+	if keyColumns != 1 {
+		return nil
+	}
+
+	// Keep RENAME from rewriting references in other tables to point at the
+	// legacy copies we are about to drop.
+	if _, err := tx.Exec("PRAGMA legacy_alter_table = ON"); err != nil {
+		return err
+	}
+	defer tx.Exec("PRAGMA legacy_alter_table = OFF")
+
+	statements := []string{
+		"DROP INDEX IF EXISTS idx_templates_persona",
+		"DROP INDEX IF EXISTS idx_prompts_template",
+		"ALTER TABLE prompt_templates RENAME TO prompt_templates_legacy",
+		"ALTER TABLE prompts RENAME TO prompts_legacy",
+		v1InitialSchema,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"prompt_templates", "prompts"} {
+		legacy, err := tableColumns(tx, table+"_legacy")
+		if err != nil {
+			return err
+		}
+		names := make([]string, len(legacy))
+		for i, c := range legacy {
+			names[i] = c.name
+		}
+		list := strings.Join(names, ", ")
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s_legacy", table, list, list, table)); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", table, err)
+		}
+	}
+
+	// Prompts may point at a version that was overwritten in place; attach
+	// them to the version that now exists.
+	_, err = tx.Exec(`
+		UPDATE prompts
+		SET template_version = (SELECT MAX(version) FROM prompt_templates pt WHERE pt.id = prompts.template_id)
+		WHERE EXISTS (SELECT 1 FROM prompt_templates pt WHERE pt.id = prompts.template_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM prompt_templates pt
+			WHERE pt.id = prompts.template_id AND pt.version = prompts.template_version
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to repair prompt template versions: %w", err)
+	}
+
+	for _, stmt := range []string{"DROP TABLE prompts_legacy", "DROP TABLE prompt_templates_legacy"} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
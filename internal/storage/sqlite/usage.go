@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// usageColumns lists the item_usage columns usageFields scans, in order,
+// qualified with the alias lists join item_usage as.
+const usageColumns = `u.render_count, u.copy_count, u.last_used_at, u.starred_at`
+
+// usageFields holds an item_usage row as scanned, which is all NULL for
+// items with no row.
+type usageFields struct {
+	renderCount, copyCount sql.NullInt64
+	lastUsedAt, starredAt  sql.NullTime
+}
+
+func (f *usageFields) dest() []interface{} {
+	return []interface{}{&f.renderCount, &f.copyCount, &f.lastUsedAt, &f.starredAt}
+}
+
+func (f *usageFields) usage() *models.Usage {
+	usage := &models.Usage{
+		RenderCount: int(f.renderCount.Int64),
+		CopyCount:   int(f.copyCount.Int64),
+		Starred:     f.starredAt.Valid,
+	}
+	if f.lastUsedAt.Valid {
+		usage.LastUsedAt = &f.lastUsedAt.Time
+	}
+	if f.starredAt.Valid {
+		usage.StarredAt = &f.starredAt.Time
+	}
+	return usage
+}
+
+// usageRow scans an item's usage columns selected after its own, so the
+// item's scan function can be used unchanged.
+type usageRow struct {
+	row    rowScanner
+	fields *usageFields
+}
+
+func (r usageRow) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(dest, r.fields.dest()...)...)
+}
+
+// withUsage extends an item scan function to also read usageColumns.
+func withUsage[T any](scan func(rowScanner) (T, error), set func(T, *models.Usage)) func(rowScanner) (T, error) {
+	return func(row rowScanner) (T, error) {
+		var fields usageFields
+		item, err := scan(usageRow{row, &fields})
+		if err != nil {
+			return item, err
+		}
+		set(item, fields.usage())
+		return item, nil
+	}
+}
+
+// withUsageSorts adds the usage sorts to the sorts of a list joining
+// item_usage as u. Items without usage sort as never used or starred.
+func withUsageSorts(sorts map[string]string) map[string]string {
+	sorts[models.SortMostUsed] = "COALESCE(u.render_count + u.copy_count, 0)"
+	sorts[models.SortRecent] = "COALESCE(u.last_used_at, '')"
+	sorts[models.SortStarred] = "COALESCE(u.starred_at, '')"
+	return sorts
+}
+
+func validUsageType(itemType string) error {
+	if itemType != models.UsagePrompt && itemType != models.UsageTemplate {
+		return fmt.Errorf("unknown item type %q", itemType)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetUsage(itemType string, id uuid.UUID) (*models.Usage, error) {
+	if err := validUsageType(itemType); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fields usageFields
+	err := s.db.QueryRow(`SELECT `+usageColumns+` FROM item_usage u WHERE item_type = ? AND item_id = ?`, itemType, id.String()).Scan(fields.dest()...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return fields.usage(), nil
+}
+
+func (s *SQLiteStorage) RecordUsage(itemType string, id uuid.UUID, event string) (*models.Usage, error) {
+	if err := validUsageType(itemType); err != nil {
+		return nil, err
+	}
+	var renders, copies int
+	switch event {
+	case models.UsageRender:
+		renders = 1
+	case models.UsageCopy:
+		copies = 1
+	default:
+		return nil, fmt.Errorf("unknown usage event %q", event)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `INSERT INTO item_usage (item_type, item_id, render_count, copy_count, last_used_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (item_type, item_id) DO UPDATE SET
+			render_count = render_count + excluded.render_count,
+			copy_count = copy_count + excluded.copy_count,
+			last_used_at = excluded.last_used_at
+		RETURNING render_count, copy_count, last_used_at, starred_at`
+	var fields usageFields
+	if err := s.db.QueryRow(query, itemType, id.String(), renders, copies).Scan(fields.dest()...); err != nil {
+		return nil, fmt.Errorf("failed to record usage: %w", err)
+	}
+	return fields.usage(), nil
+}
+
+// SetStarred stars or unstars an item. Starring a starred item keeps when
+// it was first starred.
+func (s *SQLiteStorage) SetStarred(itemType string, id uuid.UUID, starred bool) (*models.Usage, error) {
+	if err := validUsageType(itemType); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	starredAt := "NULL"
+	if starred {
+		starredAt = "CURRENT_TIMESTAMP"
+	}
+	query := `INSERT INTO item_usage (item_type, item_id, starred_at)
+		VALUES (?, ?, ` + starredAt + `)
+		ON CONFLICT (item_type, item_id) DO UPDATE SET
+			starred_at = CASE WHEN excluded.starred_at IS NULL THEN NULL ELSE COALESCE(starred_at, excluded.starred_at) END
+		RETURNING render_count, copy_count, last_used_at, starred_at`
+	var fields usageFields
+	if err := s.db.QueryRow(query, itemType, id.String()).Scan(fields.dest()...); err != nil {
+		return nil, fmt.Errorf("failed to star: %w", err)
+	}
+	return fields.usage(), nil
+}
@@ -1,35 +1,7 @@
 package storage
 
-import (
-	"github.com/google/uuid"
-	"github.com/rahulguha/promptly/internal/models"
-)
+import "github.com/rahulguha/promptly/internal/storage/store"
 
-// Storage defines the interface for CRUD operations (used by HTTP handlers)
-type Storage interface {
-	// Persona operations
-	GetAllPersonas(profileID string) ([]*models.Persona, error)
-	GetPersonaByID(id uuid.UUID) (*models.Persona, error)
-	CreatePersona(persona *models.Persona) (*models.Persona, error)
-	UpdatePersona(persona *models.Persona) (*models.Persona, error)
-	DeletePersona(id uuid.UUID) error
-
-	// Template operations
-	GetAllTemplates(profileID string) ([]*models.PromptTemplate, error)
-	GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, error)
-	GetTemplatesByPersonaID(personaID uuid.UUID) ([]*models.PromptTemplate, error)
-	CreateTemplate(template *models.PromptTemplate) (*models.PromptTemplate, error)
-	UpdateTemplate(template *models.PromptTemplate) (*models.PromptTemplate, error)
-	CreateTemplateVersion(template *models.PromptTemplate) (*models.PromptTemplate, error)
-	DeleteTemplate(id uuid.UUID, version int) error
-
-	// Prompt operations
-	GetAll(profileID string) ([]*models.Prompt, error)
-	GetByID(id uuid.UUID) (*models.Prompt, error)
-	Create(prompt *models.Prompt) (*models.Prompt, error)
-	Update(prompt *models.Prompt) (*models.Prompt, error)
-	Delete(id uuid.UUID) error
-
-	// Cleanup
-	Close() error
-}
+// Storage defines the interface for CRUD operations (used by HTTP handlers).
+// It is declared in package store so the backends can implement WithTx.
+type Storage = store.Storage
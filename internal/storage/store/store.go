@@ -0,0 +1,50 @@
+// Package store defines the Storage interface apart from package storage,
+// which imports the backends, so that the backends can name it.
+package store
+
+import (
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// Storage defines the interface for CRUD operations (used by HTTP handlers)
+type Storage interface {
+	// Persona operations
+	GetAllPersonas(profileID string) ([]*models.Persona, error)
+	ListPersonas(opts models.ListOptions) ([]*models.Persona, *models.PageInfo, error)
+	GetPersonaByID(id uuid.UUID) (*models.Persona, error)
+	CreatePersona(persona *models.Persona) (*models.Persona, error)
+	UpdatePersona(persona *models.Persona) (*models.Persona, error)
+	DeletePersona(id uuid.UUID) error
+
+	// Template operations
+	GetAllTemplates(profileID string) ([]*models.PromptTemplate, error)
+	ListTemplates(opts models.ListOptions) ([]*models.PromptTemplate, *models.PageInfo, error)
+	GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, error)
+	GetTemplateVersion(id uuid.UUID, version int) (*models.PromptTemplate, error)
+	GetTemplateVersions(id uuid.UUID) ([]*models.PromptTemplate, error)
+	GetTemplatesByPersonaID(personaID uuid.UUID) ([]*models.PromptTemplate, error)
+	CreateTemplate(template *models.PromptTemplate) (*models.PromptTemplate, error)
+	UpdateTemplate(template *models.PromptTemplate) (*models.PromptTemplate, error)
+	CreateTemplateVersion(template *models.PromptTemplate) (*models.PromptTemplate, error)
+	DeleteTemplate(id uuid.UUID, version int) error
+
+	// Prompt operations
+	GetAll(profileID string) ([]*models.Prompt, error)
+	ListPrompts(opts models.ListOptions) ([]*models.Prompt, *models.PageInfo, error)
+	GetByID(id uuid.UUID) (*models.Prompt, error)
+	GetStalePrompts(profileID string) ([]*models.Prompt, error)
+	Create(prompt *models.Prompt) (*models.Prompt, error)
+	Update(prompt *models.Prompt) (*models.Prompt, error)
+	Delete(id uuid.UUID) error
+
+	// WithTx runs fn as a unit of work: everything fn writes through the
+	// Storage it is given happens, or nothing does if fn returns an error
+	// or panics. The Storage given to fn is the same kind as the receiver,
+	// so it can be asserted to the other storage interfaces it implements.
+	// fn must not use the receiver itself.
+	WithTx(fn func(tx Storage) error) error
+
+	// Cleanup
+	Close() error
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// TagStorage defines the interface for tags and the prompts and templates
+// they label. Template tags apply to every version of a template.
+type TagStorage interface {
+	GetAllTags(profileID string) ([]*models.Tag, error)
+	GetTag(id uuid.UUID) (*models.Tag, error)
+	CreateTag(tag *models.Tag) error
+	UpdateTag(tag *models.Tag) error
+	// DeleteTag removes the tag from every prompt and template it labels.
+	DeleteTag(id uuid.UUID) error
+
+	GetPromptTags(promptID uuid.UUID) ([]*models.Tag, error)
+	// SetPromptTags replaces the tags of a prompt.
+	SetPromptTags(promptID uuid.UUID, tagIDs []uuid.UUID) ([]*models.Tag, error)
+	GetTemplateTags(templateID uuid.UUID) ([]*models.Tag, error)
+	// SetTemplateTags replaces the tags of a template.
+	SetTemplateTags(templateID uuid.UUID, tagIDs []uuid.UUID) ([]*models.Tag, error)
+}
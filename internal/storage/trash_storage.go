@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// TrashStorage defines the interface for soft-deleted items. Deletes in
+// storage that implement it move items to the trash instead of removing
+// them.
+type TrashStorage interface {
+	// GetTrash returns trashed items, most recently deleted first.
+	GetTrash(profileID string) ([]*models.TrashItem, error)
+	// RestoreTrash undoes a delete, restoring everything it deleted.
+	RestoreTrash(id uuid.UUID) error
+	// PurgeTrashItem permanently deletes one item in the trash.
+	PurgeTrashItem(id uuid.UUID) error
+	// PurgeTrash permanently deletes items trashed before a time, returning
+	// how many it purged.
+	PurgeTrash(before time.Time) (int, error)
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"github.com/rahulguha/promptly/internal/models"
+)
+
+// UsageStorage defines the interface for the local ledger of how prompts
+// and templates are used. itemType is models.UsagePrompt or
+// models.UsageTemplate.
+type UsageStorage interface {
+	// GetUsage returns zero usage for items never used or starred.
+	GetUsage(itemType string, id uuid.UUID) (*models.Usage, error)
+	// RecordUsage counts one use of an item, models.UsageRender or
+	// models.UsageCopy, and marks it last used now.
+	RecordUsage(itemType string, id uuid.UUID, event string) (*models.Usage, error)
+	SetStarred(itemType string, id uuid.UUID, starred bool) (*models.Usage, error)
+}
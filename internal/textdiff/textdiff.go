@@ -0,0 +1,81 @@
+// Package textdiff computes line-based differences between two texts.
+package textdiff
+
+import "strings"
+
+// Op says whether a line is shared by both texts or only appears in one.
+type Op string
+
+const (
+	OpEqual  Op = "equal"
+	OpInsert Op = "insert"
+	OpDelete Op = "delete"
+)
+
+// Line is one line of a diff.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the shortest edit turning a into b, one entry per line.
+// Deleted lines come before the lines inserted in their place.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := make([]Line, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, Line{Op: OpEqual, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, Line{Op: OpDelete, Text: x[i]})
+			i++
+		default:
+			diff = append(diff, Line{Op: OpInsert, Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, Line{Op: OpDelete, Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, Line{Op: OpInsert, Text: y[j]})
+	}
+	return diff
+}
+
+// Changed reports whether diff contains any insertions or deletions.
+func Changed(diff []Line) bool {
+	for _, l := range diff {
+		if l.Op != OpEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,66 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{
+			name: "identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []Line{{OpEqual, "one"}, {OpEqual, "two"}},
+		},
+		{
+			name: "empty to text",
+			a:    "",
+			b:    "one",
+			want: []Line{{OpInsert, "one"}},
+		},
+		{
+			name: "text to empty",
+			a:    "one",
+			b:    "",
+			want: []Line{{OpDelete, "one"}},
+		},
+		{
+			name: "changed middle line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Line{{OpEqual, "one"}, {OpDelete, "two"}, {OpInsert, "2"}, {OpEqual, "three"}},
+		},
+		{
+			name: "appended line",
+			a:    "one\n",
+			b:    "one\ntwo\n",
+			want: []Line{{OpEqual, "one"}, {OpInsert, "two"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	if Changed(Lines("a\nb", "a\nb")) {
+		t.Error("Expected identical texts to be unchanged")
+	}
+	if !Changed(Lines("a", "b")) {
+		t.Error("Expected different texts to be changed")
+	}
+	if Changed(nil) {
+		t.Error("Expected an empty diff to be unchanged")
+	}
+}